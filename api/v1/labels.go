@@ -0,0 +1,63 @@
+package v1
+
+// Well-known labels the controller stamps on resources it renders for a Session.
+const (
+	// SessionNameLabel carries the owning Session's name. It is set on every child
+	// resource and pod, so the API server can find them without knowing the
+	// controller's name prefix.
+	SessionNameLabel = "codespace.dev/session"
+)
+
+// Labels on VolumeSnapshots taken of a Session's volumes, by the API server or
+// by the controller's snapshot schedule. SessionNameLabel is set as well.
+const (
+	// SnapshotVolumeLabel names the snapshotted volume ("home" or "scratch").
+	SnapshotVolumeLabel = "codespace.dev/volume"
+	// SnapshotTriggerLabel records why the snapshot was taken.
+	SnapshotTriggerLabel = "codespace.dev/snapshot-trigger"
+
+	SnapshotTriggerManual    = "manual"
+	SnapshotTriggerScheduled = "scheduled"
+	SnapshotTriggerClone     = "clone"
+)
+
+// Restoring the home volume is requested by annotating the Session; the
+// controller suspends it, recreates <name>-home from the snapshot, resumes it
+// and removes the annotations again.
+const (
+	// RestoreSnapshotAnnotation names the VolumeSnapshot to restore from.
+	RestoreSnapshotAnnotation = "codespace.dev/restore-snapshot"
+	// RestoreRequestAnnotation identifies the request, so restoring from the same
+	// snapshot twice is two restores. It is copied onto the recreated claim.
+	RestoreRequestAnnotation = "codespace.dev/restore-request"
+)
+
+// ResizeRestartAnnotation is set on a claim when the controller restarted the
+// Session pod to finish an offline filesystem resize (PVCSpec.RestartOnResize).
+// It holds the requested size, so the pod is restarted once per resize.
+const ResizeRestartAnnotation = "codespace.dev/resize-restarted-for"
+
+// RestartAnnotation requests a restart of the Session pods. The controller copies
+// it onto the pod template, so each new value rolls the Deployment once.
+const RestartAnnotation = "codespace.dev/restarted-at"
+
+// RetentionPolicyAnnotation carries PVCSpec.RetentionPolicy on the claim, so it
+// is still known after the volume was removed from the Session spec.
+const RetentionPolicyAnnotation = "codespace.dev/retention-policy"
+
+// The API server reverse-proxies each Session under ProxyPath, for clusters
+// without an ingress controller.
+const (
+	// BaseURLEnv is set on the IDE container to the path the IDE is served
+	// under: "/" when the Session is published on a host, its ProxyPath otherwise.
+	// Arguments can refer to it as $(CODESPACE_BASE_URL).
+	BaseURLEnv = "CODESPACE_BASE_URL"
+	// BasePathAnnotation is set on the Session Service when the IDE serves under
+	// its ProxyPath itself; otherwise the proxy strips the prefix.
+	BasePathAnnotation = "codespace.dev/base-path"
+)
+
+// ProxyPath is the path the API server serves a Session under, with a trailing slash.
+func ProxyPath(namespace, name string) string {
+	return "/s/" + namespace + "/" + name + "/"
+}
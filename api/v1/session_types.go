@@ -1,6 +1,7 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -12,6 +13,14 @@ type ProfileSpec struct {
 	Cmd   []string `json:"cmd,omitempty"`
 }
 
+// IDEPort is the port the IDE container listens on: 8080 for vscode, 8888 otherwise.
+func IDEPort(ide string) int32 {
+	if ide == "vscode" {
+		return 8080
+	}
+	return 8888
+}
+
 type OIDCRef struct {
 	// +kubebuilder:validation:Pattern=`^https?://`
 	IssuerURL       string `json:"issuerURL"`
@@ -26,12 +35,64 @@ type AuthSpec struct {
 	OIDC *OIDCRef `json:"oidc,omitempty"`
 }
 
+// +kubebuilder:validation:XValidation:rule="quantity(self.size).compareTo(quantity(oldSelf.size)) >= 0",message="volume size cannot be decreased"
+// +kubebuilder:validation:XValidation:rule="!has(self.ephemeral) || !self.ephemeral || !has(self.snapshots)",message="snapshots are not supported for ephemeral volumes"
+// +kubebuilder:validation:XValidation:rule="!has(self.ephemeral) || !self.ephemeral || !has(self.retentionPolicy) || self.retentionPolicy == 'Delete'",message="ephemeral volumes cannot be retained"
 type PVCSpec struct {
+	// Size of the claim. It can be increased on a running Session if the
+	// StorageClass allows volume expansion; it can never be decreased.
 	// +kubebuilder:validation:Pattern=`^\d+(Gi|Mi)$`
 	Size             string `json:"size"`
 	StorageClassName string `json:"storageClassName,omitempty"`
 	// +kubebuilder:validation:MinLength=1
 	MountPath string `json:"mountPath"`
+	// AccessModes of the claim; defaults to ReadWriteOnce. Sessions with several
+	// replicas spread over nodes need ReadWriteMany. Fixed once the claim exists.
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// VolumeMode is Filesystem (default) or Block. A Block volume is attached to
+	// the IDE container as a raw device at MountPath. Fixed once the claim exists.
+	// +kubebuilder:validation:Enum=Filesystem;Block
+	VolumeMode *corev1.PersistentVolumeMode `json:"volumeMode,omitempty"`
+	// Ephemeral uses a generic ephemeral volume, created with the pod and deleted
+	// with it, instead of a long-lived claim. Only supported for scratch.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// DataSource pre-populates a new volume from a PersistentVolumeClaim (clone)
+	// or a VolumeSnapshot in the same namespace. It only applies when the claim
+	// is first created. The server only sets it when cloning a Session.
+	DataSource *corev1.TypedLocalObjectReference `json:"dataSource,omitempty"`
+	// Snapshots takes VolumeSnapshots of the volume on a schedule.
+	Snapshots *SnapshotPolicy `json:"snapshots,omitempty"`
+	// RestartOnResize restarts the Session pod when the storage driver can only
+	// grow the filesystem while the volume is not in use (FileSystemResizePending).
+	RestartOnResize bool `json:"restartOnResize,omitempty"`
+	// RetentionPolicy decides what happens to the claim when the volume is
+	// removed from the spec or the Session is deleted: Delete it, or Retain it
+	// as a standalone claim that is no longer owned by the Session. Unset, the
+	// claim is deleted with the Session, but a home claim is kept when only the
+	// volume is removed from the spec, so its data is never pruned without an
+	// explicit Delete.
+	// +kubebuilder:validation:Enum=Delete;Retain
+	RetentionPolicy string `json:"retentionPolicy,omitempty"`
+}
+
+// Volume retention policies (PVCSpec.RetentionPolicy).
+const (
+	VolumeRetentionDelete = "Delete"
+	VolumeRetentionRetain = "Retain"
+)
+
+// SnapshotPolicy schedules VolumeSnapshots of a Session volume.
+type SnapshotPolicy struct {
+	// Schedule in cron format, e.g. "0 3 * * *" for every night at 03:00 UTC.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+	// Retain is how many scheduled snapshots to keep; older ones are deleted.
+	// Manual snapshots are never pruned.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	Retain int32 `json:"retain,omitempty"`
+	// VolumeSnapshotClassName to use; empty uses the cluster default.
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
 }
 
 type NetSpec struct {
@@ -40,6 +101,72 @@ type NetSpec struct {
 	Annotations   map[string]string `json:"annotations,omitempty"`
 }
 
+// PortSpec exposes an additional port of the IDE container, e.g. a dev web app
+// or TensorBoard. It gets its own Service port and, with a path or subdomain,
+// an Ingress rule behind the same authentication as the IDE. Apps published
+// under a path must serve under that path (TensorBoard: --path_prefix).
+// +kubebuilder:validation:XValidation:rule="!has(self.path) || !has(self.subdomain)",message="path and subdomain are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="self.containerPort < 4180 || self.containerPort > 4199",message="ports 4180-4199 are reserved for the auth proxy"
+// +kubebuilder:validation:XValidation:rule="self.containerPort != 80",message="port 80 is the IDE's Service port"
+type PortSpec struct {
+	// Name of the Service port; "http" is taken by the IDE.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:XValidation:rule="self != 'http'",message="http is reserved for the IDE"
+	Name string `json:"name"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+	// Path publishes the port under this prefix of the Session host, e.g. "/tensorboard".
+	// +kubebuilder:validation:Pattern=`^/[^/\s]+(/[^/\s]+)*$`
+	Path string `json:"path,omitempty"`
+	// Subdomain publishes the port on <subdomain>.<host>.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Subdomain string `json:"subdomain,omitempty"`
+}
+
+// SidecarSpec is a container that runs next to the IDE in the Session pod, e.g.
+// a development database, a Docker-in-Docker daemon or a VNC desktop. It shares
+// the pod network with the IDE, so it is reached on localhost.
+// +kubebuilder:validation:XValidation:rule="self.name != 'ide' && !self.name.startsWith('oauth2-proxy')",message="ide and oauth2-proxy* are reserved container names"
+type SidecarSpec struct {
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinLength=1
+	Image   string   `json:"image"`
+	Command []string `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// +kubebuilder:validation:MaxItems=64
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// +kubebuilder:validation:MaxItems=16
+	Ports     []corev1.ContainerPort       `json:"ports,omitempty"`
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// SecurityContext of the sidecar, e.g. privileged for Docker-in-Docker.
+	// Through the server, privileged or host-level settings need the
+	// "privileged" permission.
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	ReadinessProbe  *corev1.Probe           `json:"readinessProbe,omitempty"`
+	// VolumeMounts share the Session's volumes with the sidecar.
+	// +kubebuilder:validation:MaxItems=4
+	VolumeMounts []SidecarVolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// SidecarVolumeMount mounts a Session volume into a sidecar. Block volumes are
+// attached as a device at MountPath instead.
+type SidecarVolumeMount struct {
+	// +kubebuilder:validation:Enum=home;scratch
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinLength=1
+	MountPath string `json:"mountPath"`
+	SubPath   string `json:"subPath,omitempty"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:rule="!has(self.home) || !has(self.home.ephemeral) || !self.home.ephemeral",message="home cannot be ephemeral"
+// +kubebuilder:validation:XValidation:rule="!has(self.ports) || self.ports.all(p, p.containerPort != (self.profile.ide == 'vscode' ? 8080 : 8888))",message="ports cannot use the IDE's port (8080 for vscode, 8888 otherwise)"
+// +kubebuilder:validation:XValidation:rule="!has(self.ports) || self.ports.all(p, self.ports.exists_one(q, q.containerPort == p.containerPort))",message="ports must have unique containerPorts"
+// +kubebuilder:validation:XValidation:rule="!has(self.sidecars) || self.sidecars.all(s, !has(s.volumeMounts) || s.volumeMounts.all(m, m.name == 'home' ? has(self.home) : has(self.scratch)))",message="sidecars can only mount volumes the session has"
 type SessionSpec struct {
 	Profile    ProfileSpec `json:"profile"`
 	Auth       AuthSpec    `json:"auth,omitempty"`
@@ -47,12 +174,152 @@ type SessionSpec struct {
 	Scratch    *PVCSpec    `json:"scratch,omitempty"`
 	Networking *NetSpec    `json:"networking,omitempty"`
 	Replicas   *int32      `json:"replicas,omitempty"`
+	// ImagePullSecrets for private registries, in addition to the controller's
+	// image_pull_secrets. They are attached to the Session's ServiceAccount.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Ports of the IDE container to expose besides the IDE itself. Their
+	// containerPorts must be unique and differ from the IDE's port and 80.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=16
+	Ports []PortSpec `json:"ports,omitempty"`
+	// Sidecars run next to the IDE container in the Session pod.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=8
+	Sidecars []SidecarSpec `json:"sidecars,omitempty"`
+}
+
+// Session phases reported in SessionStatus.Phase.
+const (
+	SessionPhasePending   = "Pending"
+	SessionPhaseReady     = "Ready"
+	SessionPhaseSuspended = "Suspended"
+	SessionPhaseError     = "Error"
+)
+
+// Restore phases reported in RestoreStatus.Phase.
+const (
+	RestorePhaseInProgress = "InProgress"
+	RestorePhaseCompleted  = "Completed"
+	RestorePhaseFailed     = "Failed"
+)
+
+// Rollout phases reported in RolloutStatus.Phase.
+const (
+	RolloutPhaseProgressing = "Progressing"
+	RolloutPhaseComplete    = "Complete"
+	RolloutPhaseFailed      = "Failed"
+)
+
+// RolloutStatus reports the progress of the Session Deployment's latest rollout.
+type RolloutStatus struct {
+	// Strategy of the Deployment: Recreate while the Session has ReadWriteOnce
+	// volumes, RollingUpdate otherwise.
+	Strategy string `json:"strategy"`
+	Phase    string `json:"phase"` // Progressing | Complete | Failed
+	Message  string `json:"message,omitempty"`
+	// Replicas, UpdatedReplicas and ReadyReplicas are the Deployment's counts.
+	Replicas        int32 `json:"replicas"`
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+	ReadyReplicas   int32 `json:"readyReplicas"`
+	// RestartedAt is the restart request the pod template carries, if any.
+	RestartedAt string `json:"restartedAt,omitempty"`
 }
 
+// RestoreStatus reports the latest restore of the home volume.
+type RestoreStatus struct {
+	Snapshot    string       `json:"snapshot"`
+	Phase       string       `json:"phase"` // InProgress | Completed | Failed
+	Message     string       `json:"message,omitempty"`
+	StartedAt   *metav1.Time `json:"startedAt,omitempty"`
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// Volume resize states reported in VolumeStatus.Resize. Empty means the claim
+// has the requested size.
+const (
+	VolumeResizePending                 = "Pending"
+	VolumeResizeInProgress              = "Resizing"
+	VolumeResizeFileSystemResizePending = "FileSystemResizePending"
+	VolumeResizeInfeasible              = "Infeasible"
+	VolumeResizeNotSupported            = "NotSupported"
+	VolumeResizeShrinkRejected          = "ShrinkRejected"
+)
+
+// VolumeStatus reports the state of a Session volume claim.
+type VolumeStatus struct {
+	Name      string `json:"name"` // home | scratch
+	ClaimName string `json:"claimName"`
+	Requested string `json:"requested,omitempty"`
+	Capacity  string `json:"capacity,omitempty"`
+	Resize    string `json:"resize,omitempty"` // Pending | Resizing | FileSystemResizePending | Infeasible | NotSupported | ShrinkRejected
+	Message   string `json:"message,omitempty"`
+}
+
+// Actions taken on drift, as reported in DriftStatus.Action.
+const (
+	DriftReverted = "Reverted"
+	DriftReported = "Reported"
+)
+
+// DriftStatus records fields of a child resource that another field manager
+// changed after the operator applied them.
+type DriftStatus struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Fields are the changed field paths, e.g. ".spec.replicas".
+	Fields []string `json:"fields,omitempty"`
+	// Managers are the field managers that changed them.
+	Managers   []string    `json:"managers,omitempty"`
+	Action     string      `json:"action"` // Reverted | Reported
+	DetectedAt metav1.Time `json:"detectedAt"`
+	// Stale is true when a reported drift keeps the operator from applying the
+	// object at all, so it no longer follows the Session spec.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// ContainerStatus reports a container of the Session pods, aggregated over the
+// running pods.
+type ContainerStatus struct {
+	Name string `json:"name"`
+	// Ready is true when the container is ready in every pod.
+	Ready        bool  `json:"ready"`
+	RestartCount int32 `json:"restartCount,omitempty"`
+	// State, Reason and Message describe the container in a pod where it is not
+	// ready: Waiting, Running or Terminated.
+	State   string `json:"state,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Condition types reported in SessionStatus.Conditions.
+const (
+	// ConditionImagePullSecretsReady is False while an image pull Secret of the
+	// Session does not exist.
+	ConditionImagePullSecretsReady = "ImagePullSecretsReady"
+	// ConditionOwned is False while the Session is orphaned: it has no instance
+	// label, or the server instance it belongs to no longer exists.
+	ConditionOwned = "Owned"
+	// ConditionDrifted is True while a child resource has external changes that
+	// the drift policy left in place; with reason NotReconciled a child is no
+	// longer updated from the Session spec.
+	ConditionDrifted = "Drifted"
+)
+
 type SessionStatus struct {
-	Phase  string `json:"phase,omitempty"` // Pending | Ready | Error
-	URL    string `json:"url,omitempty"`
-	Reason string `json:"reason,omitempty"`
+	Phase   string         `json:"phase,omitempty"` // Pending | Ready | Suspended | Error
+	URL     string         `json:"url,omitempty"`
+	Reason  string         `json:"reason,omitempty"`
+	Restore *RestoreStatus `json:"restore,omitempty"`
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+	Volumes []VolumeStatus `json:"volumes,omitempty"`
+	Drift   []DriftStatus  `json:"drift,omitempty"`
+	// Containers of the Session pods: the IDE, its sidecars and auth proxies.
+	Containers []ContainerStatus `json:"containers,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
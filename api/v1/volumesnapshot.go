@@ -0,0 +1,39 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// VolumeSnapshots are handled as unstructured objects, so neither the server
+// nor the controller depends on the external-snapshotter client, and both run
+// (without snapshot support) on clusters that lack the snapshot CRDs.
+const VolumeSnapshotGroup = "snapshot.storage.k8s.io"
+
+var VolumeSnapshotGVK = schema.GroupVersionKind{Group: VolumeSnapshotGroup, Version: "v1", Kind: "VolumeSnapshot"}
+
+// NewVolumeSnapshot builds a VolumeSnapshot of the claim pvcName. An empty
+// class uses the cluster's default VolumeSnapshotClass.
+func NewVolumeSnapshot(namespace, name, pvcName, class string, labels map[string]string) *unstructured.Unstructured {
+	snap := &unstructured.Unstructured{}
+	snap.SetGroupVersionKind(VolumeSnapshotGVK)
+	snap.SetNamespace(namespace)
+	snap.SetName(name)
+	snap.SetLabels(labels)
+	spec := map[string]any{
+		"source": map[string]any{"persistentVolumeClaimName": pvcName},
+	}
+	if class != "" {
+		spec["volumeSnapshotClassName"] = class
+	}
+	snap.Object["spec"] = spec
+	return snap
+}
+
+// VolumeSnapshotDataSource refers to the named VolumeSnapshot as the dataSource
+// of a claim.
+func VolumeSnapshotDataSource(name string) *corev1.TypedLocalObjectReference {
+	group := VolumeSnapshotGroup
+	return &corev1.TypedLocalObjectReference{APIGroup: &group, Kind: "VolumeSnapshot", Name: name}
+}
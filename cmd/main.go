@@ -1,20 +1,25 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -71,6 +76,28 @@ func main() {
 		"Prefix for generated session resource names")
 	rootCmd.Flags().String("field-owner", "codespace-operator",
 		"Field manager name for server-side apply operations")
+	rootCmd.Flags().String("oauth2-proxy-image", "quay.io/oauth2-proxy/oauth2-proxy:v7.6.0",
+		"Image of the oauth2-proxy sidecar")
+	rootCmd.Flags().String("ingress-class-name", "",
+		"IngressClass for session Ingresses (empty uses the cluster default)")
+	rootCmd.Flags().String("ingress-domain", "",
+		"Domain of session hosts; sessions without a host get {name}-{namespace}.<domain>")
+	rootCmd.Flags().String("ingress-host-template", "",
+		"Host of sessions without one, with {name}, {namespace} and {domain} placeholders")
+	rootCmd.Flags().String("cert-manager-issuer", "",
+		"cert-manager ClusterIssuer (or Issuer, see cert_manager_issuer_kind) for session TLS secrets")
+	rootCmd.Flags().Duration("requeue-interval", 2*time.Minute,
+		"How often sessions are reconciled without changes")
+	rootCmd.Flags().String("drift-policy", "revert",
+		"What to do with external changes to session resources: revert or report")
+	rootCmd.Flags().StringSlice("image-pull-secrets", nil,
+		"Image pull secrets attached to every session's ServiceAccount")
+	rootCmd.Flags().String("instance-id", "",
+		"Only reconcile sessions of this server instance ID; \"auto\" derives it like the server does")
+	rootCmd.Flags().StringSlice("watch-namespaces", nil,
+		"Only watch these namespaces (namespace-scoped mode)")
+	rootCmd.Flags().String("watch-namespace-selector", "",
+		"Only watch namespaces matching this label selector (namespace-scoped mode)")
 	rootCmd.Flags().Bool("debug", false, "Enable debug logging")
 	rootCmd.Flags().String("log-level", "info", "Log level (debug, info, warn, error)")
 	// Add zap flags to a separate FlagSet that we can bind
@@ -108,61 +135,10 @@ func runController(cmd *cobra.Command, args []string) {
 	}
 
 	// Override config with command line flags
-	if cmd.Flags().Changed("metrics-bind-address") {
-		addr, _ := cmd.Flags().GetString("metrics-bind-address")
-		cfg.MetricsAddr = addr
-	}
-	if cmd.Flags().Changed("health-probe-bind-address") {
-		addr, _ := cmd.Flags().GetString("health-probe-bind-address")
-		cfg.ProbeAddr = addr
-	}
-	if cmd.Flags().Changed("leader-elect") {
-		enable, _ := cmd.Flags().GetBool("leader-elect")
-		cfg.EnableLeaderElection = enable
-	}
-	if cmd.Flags().Changed("metrics-secure") {
-		secure, _ := cmd.Flags().GetBool("metrics-secure")
-		cfg.SecureMetrics = secure
-	}
-	if cmd.Flags().Changed("webhook-cert-path") {
-		path, _ := cmd.Flags().GetString("webhook-cert-path")
-		cfg.WebhookCertPath = path
-	}
-	if cmd.Flags().Changed("webhook-cert-name") {
-		name, _ := cmd.Flags().GetString("webhook-cert-name")
-		cfg.WebhookCertName = name
-	}
-	if cmd.Flags().Changed("webhook-cert-key") {
-		key, _ := cmd.Flags().GetString("webhook-cert-key")
-		cfg.WebhookCertKey = key
-	}
-	if cmd.Flags().Changed("metrics-cert-path") {
-		path, _ := cmd.Flags().GetString("metrics-cert-path")
-		cfg.MetricsCertPath = path
-	}
-	if cmd.Flags().Changed("metrics-cert-name") {
-		name, _ := cmd.Flags().GetString("metrics-cert-name")
-		cfg.MetricsCertName = name
-	}
-	if cmd.Flags().Changed("metrics-cert-key") {
-		key, _ := cmd.Flags().GetString("metrics-cert-key")
-		cfg.MetricsCertKey = key
-	}
-	if cmd.Flags().Changed("enable-http2") {
-		enable, _ := cmd.Flags().GetBool("enable-http2")
-		cfg.EnableHTTP2 = enable
-	}
-	if cmd.Flags().Changed("session-name-prefix") {
-		prefix, _ := cmd.Flags().GetString("session-name-prefix")
-		cfg.SessionNamePrefix = prefix
-	}
-	if cmd.Flags().Changed("field-owner") {
-		owner, _ := cmd.Flags().GetString("field-owner")
-		cfg.FieldOwner = owner
-	}
-	if cmd.Flags().Changed("debug") {
-		debug, _ := cmd.Flags().GetBool("debug")
-		cfg.Debug = debug
+	applyFlagOverrides(cmd, cfg)
+	if err := cfg.Validate(); err != nil {
+		setupLog.Error(err, "Invalid configuration")
+		os.Exit(1)
 	}
 
 	// Setup logging - create new zap options and configure from flags
@@ -256,14 +232,45 @@ func runController(cmd *cobra.Command, args []string) {
 		})
 	}
 
+	// Resolve the instance to shard on before the manager (and its cache) exists
+	restCfg := ctrl.GetConfigOrDie()
+	setupClient, err := client.New(restCfg, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "Unable to create setup client")
+		os.Exit(1)
+	}
+	instanceID, err := controller.ResolveInstanceID(context.Background(), setupClient, cfg.InstanceID, cfg.ServerAppName)
+	if err != nil {
+		setupLog.Error(err, "Unable to resolve instance ID")
+		os.Exit(1)
+	}
+	if instanceID != "" {
+		setupLog.Info("Reconciling sessions of one instance only", "instanceID", instanceID)
+	}
+	watchNamespaces, err := controller.ResolveWatchNamespaces(context.Background(), setupClient,
+		cfg.WatchNamespaces, cfg.WatchNamespaceSelector)
+	if err != nil {
+		setupLog.Error(err, "Unable to resolve watched namespaces")
+		os.Exit(1)
+	}
+	if len(watchNamespaces) > 0 {
+		setupLog.Info("Running namespace-scoped", "namespaces", watchNamespaces)
+	}
+
 	// Create manager
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	mgr, err := ctrl.NewManager(restCfg, ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: cfg.ProbeAddr,
 		LeaderElection:         cfg.EnableLeaderElection,
 		LeaderElectionID:       cfg.LeaderElectionID,
+		Cache:                  controller.CacheOptions(instanceID, watchNamespaces),
+		// Only the server instance ConfigMaps are read, so do not cache all of them.
+		// StorageClasses are cluster-scoped and may not be readable at all.
+		Client: client.Options{Cache: &client.CacheOptions{
+			DisableFor: []client.Object{&corev1.ConfigMap{}, &storagev1.StorageClass{}},
+		}},
 	})
 	if err != nil {
 		setupLog.Error(err, "Unable to start session-controller")
@@ -271,14 +278,43 @@ func runController(cmd *cobra.Command, args []string) {
 	}
 
 	// Setup controller with configuration
-	if err := (&controller.SessionReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	reconciler := &controller.SessionReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("session-controller"),
+		Options:       controller.OptionsFromConfig(cfg),
+		InstanceID:    instanceID,
+		ServerAppName: cfg.ServerAppName,
+	}
+	if err := reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "Unable to create controller", "controller", "Session")
 		os.Exit(1)
 	}
 
+	// Reload the session settings when the config file changes
+	watching, err := controller.WatchControllerConfig(func(next *controller.ControllerConfig, err error) {
+		if err != nil {
+			setupLog.Error(err, "Failed to reload configuration; keeping the current one")
+			return
+		}
+		applyFlagOverrides(cmd, next)
+		if err := next.Validate(); err != nil {
+			setupLog.Error(err, "Invalid configuration; keeping the current one")
+			return
+		}
+		if next.SessionNamePrefix != cfg.SessionNamePrefix || next.FieldOwner != cfg.FieldOwner {
+			setupLog.Info("session_name_prefix and field_owner only take effect after a restart")
+		}
+		reconciler.SetOptions(controller.OptionsFromConfig(next))
+		setupLog.Info("Configuration reloaded")
+	})
+	if err != nil {
+		setupLog.Error(err, "Unable to watch configuration")
+		os.Exit(1)
+	} else if watching {
+		setupLog.Info("Watching configuration file for changes")
+	}
+
 	// Add certificate watchers to manager
 	if metricsCertWatcher != nil {
 		setupLog.Info("Adding metrics certificate watcher to session-controller")
@@ -305,10 +341,22 @@ func runController(cmd *cobra.Command, args []string) {
 		setupLog.Error(err, "Unable to set up ready check")
 		os.Exit(1)
 	}
-
-	// Update global configuration for controller
-	os.Setenv("SESSION_NAME_PREFIX", cfg.SessionNamePrefix)
-	os.Setenv("FIELD_OWNER", cfg.FieldOwner)
+	if len(watchNamespaces) > 0 {
+		monitor := &controller.NamespaceMonitor{
+			Reader:     mgr.GetAPIReader(),
+			Names:      cfg.WatchNamespaces,
+			Selector:   cfg.WatchNamespaceSelector,
+			Namespaces: watchNamespaces,
+		}
+		if err := mgr.Add(monitor); err != nil {
+			setupLog.Error(err, "Unable to add namespace monitor")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("watch-namespaces", monitor.Check); err != nil {
+			setupLog.Error(err, "Unable to set up namespace check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("Starting session-controller")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -316,3 +364,108 @@ func runController(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 }
+
+// applyFlagOverrides lets command line flags take precedence over the config
+// file and environment. It is applied again when the config file is reloaded.
+func applyFlagOverrides(cmd *cobra.Command, cfg *controller.ControllerConfig) {
+	if cmd.Flags().Changed("metrics-bind-address") {
+		addr, _ := cmd.Flags().GetString("metrics-bind-address")
+		cfg.MetricsAddr = addr
+	}
+	if cmd.Flags().Changed("health-probe-bind-address") {
+		addr, _ := cmd.Flags().GetString("health-probe-bind-address")
+		cfg.ProbeAddr = addr
+	}
+	if cmd.Flags().Changed("leader-elect") {
+		enable, _ := cmd.Flags().GetBool("leader-elect")
+		cfg.EnableLeaderElection = enable
+	}
+	if cmd.Flags().Changed("metrics-secure") {
+		secure, _ := cmd.Flags().GetBool("metrics-secure")
+		cfg.SecureMetrics = secure
+	}
+	if cmd.Flags().Changed("webhook-cert-path") {
+		path, _ := cmd.Flags().GetString("webhook-cert-path")
+		cfg.WebhookCertPath = path
+	}
+	if cmd.Flags().Changed("webhook-cert-name") {
+		name, _ := cmd.Flags().GetString("webhook-cert-name")
+		cfg.WebhookCertName = name
+	}
+	if cmd.Flags().Changed("webhook-cert-key") {
+		key, _ := cmd.Flags().GetString("webhook-cert-key")
+		cfg.WebhookCertKey = key
+	}
+	if cmd.Flags().Changed("metrics-cert-path") {
+		path, _ := cmd.Flags().GetString("metrics-cert-path")
+		cfg.MetricsCertPath = path
+	}
+	if cmd.Flags().Changed("metrics-cert-name") {
+		name, _ := cmd.Flags().GetString("metrics-cert-name")
+		cfg.MetricsCertName = name
+	}
+	if cmd.Flags().Changed("metrics-cert-key") {
+		key, _ := cmd.Flags().GetString("metrics-cert-key")
+		cfg.MetricsCertKey = key
+	}
+	if cmd.Flags().Changed("enable-http2") {
+		enable, _ := cmd.Flags().GetBool("enable-http2")
+		cfg.EnableHTTP2 = enable
+	}
+	if cmd.Flags().Changed("session-name-prefix") {
+		prefix, _ := cmd.Flags().GetString("session-name-prefix")
+		cfg.SessionNamePrefix = prefix
+	}
+	if cmd.Flags().Changed("field-owner") {
+		owner, _ := cmd.Flags().GetString("field-owner")
+		cfg.FieldOwner = owner
+	}
+	if cmd.Flags().Changed("oauth2-proxy-image") {
+		image, _ := cmd.Flags().GetString("oauth2-proxy-image")
+		cfg.OAuth2ProxyImage = image
+	}
+	if cmd.Flags().Changed("ingress-class-name") {
+		class, _ := cmd.Flags().GetString("ingress-class-name")
+		cfg.IngressClassName = class
+	}
+	if cmd.Flags().Changed("ingress-domain") {
+		domain, _ := cmd.Flags().GetString("ingress-domain")
+		cfg.IngressDomain = domain
+	}
+	if cmd.Flags().Changed("ingress-host-template") {
+		tmpl, _ := cmd.Flags().GetString("ingress-host-template")
+		cfg.IngressHostTemplate = tmpl
+	}
+	if cmd.Flags().Changed("cert-manager-issuer") {
+		issuer, _ := cmd.Flags().GetString("cert-manager-issuer")
+		cfg.CertManagerIssuer = issuer
+	}
+	if cmd.Flags().Changed("requeue-interval") {
+		interval, _ := cmd.Flags().GetDuration("requeue-interval")
+		cfg.RequeueInterval = interval
+	}
+	if cmd.Flags().Changed("drift-policy") {
+		policy, _ := cmd.Flags().GetString("drift-policy")
+		cfg.DriftPolicy = policy
+	}
+	if cmd.Flags().Changed("image-pull-secrets") {
+		secrets, _ := cmd.Flags().GetStringSlice("image-pull-secrets")
+		cfg.ImagePullSecrets = secrets
+	}
+	if cmd.Flags().Changed("instance-id") {
+		id, _ := cmd.Flags().GetString("instance-id")
+		cfg.InstanceID = id
+	}
+	if cmd.Flags().Changed("watch-namespaces") {
+		namespaces, _ := cmd.Flags().GetStringSlice("watch-namespaces")
+		cfg.WatchNamespaces = namespaces
+	}
+	if cmd.Flags().Changed("watch-namespace-selector") {
+		selector, _ := cmd.Flags().GetString("watch-namespace-selector")
+		cfg.WatchNamespaceSelector = selector
+	}
+	if cmd.Flags().Changed("debug") {
+		debug, _ := cmd.Flags().GetBool("debug")
+		cfg.Debug = debug
+	}
+}
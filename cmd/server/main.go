@@ -69,13 +69,14 @@ func main() {
 	rootCmd.Flags().String("config", "", "Path to config directory or file (highest precedence among files)")
 	rootCmd.Flags().IntP("port", "p", 8080, "Server port")
 	rootCmd.Flags().String("host", "", "Server host (empty for all interfaces)")
-	rootCmd.Flags().String("allow-origin", "", "CORS allow origin")
+	rootCmd.Flags().String("allow-origin", "", "CORS allowed origins (comma-separated)")
 	rootCmd.Flags().String("log-level", "info", "Log level (debug, info, warn, error)")
 	rootCmd.Flags().Float32("kube-qps", 50.0, "Kubernetes client QPS")
 	rootCmd.Flags().Int("kube-burst", 100, "Kubernetes client burst")
 	rootCmd.Flags().Bool("cluster-scope", false, "Cluster-scoped mode")
 	rootCmd.Flags().String("app-name", DEFAULT_APP_NAME, "Application name")
 	rootCmd.Flags().Bool("developer-mode", false, "Developer mode (relaxes cookies)")
+	rootCmd.Flags().String("metrics-addr", ":9464", "Metrics listener address (empty serves /metrics on the server port, behind a token)")
 
 	// RBAC files
 	rootCmd.Flags().String("rbac-model-path", "", "Casbin model.conf")
@@ -143,6 +144,7 @@ func loadConfigWithOverrides(cmd *cobra.Command) *server.ServerConfig {
 	ovBool(&cfg.DeveloperMode, "developer-mode")
 	ovF32(&cfg.KubeQPS, "kube-qps")
 	ovInt(&cfg.KubeBurst, "kube-burst")
+	ovStr(&cfg.MetricsAddr, "metrics-addr")
 
 	// rbac
 	ovStr(&cfg.RBACModelPath, "rbac-model-path")
@@ -30,3 +30,20 @@ ingress:
 YAML
 
 helm upgrade --install loki grafana/loki-distributed -n monitoring --create-namespace -f loki-values.yaml
+
+# Grafana with the dashboard sidecar, so ConfigMaps labelled grafana_dashboard=1 are picked up
+cat > grafana-values.yaml <<'YAML'
+sidecar:
+  dashboards:
+    enabled: true
+    label: grafana_dashboard
+    labelValue: "1"
+    searchNamespace: ALL
+YAML
+
+helm upgrade --install grafana grafana/grafana -n monitoring --create-namespace -f grafana-values.yaml
+
+# Session fleet dashboard (codespace_* metrics from the session-controller /metrics endpoint)
+kubectl -n monitoring create configmap codespace-sessions-dashboard \
+  --from-file=codespace-sessions.json=contrib/manifests/grafana/sessions-dashboard.json \
+  --dry-run=client -o yaml | kubectl label --local -f - grafana_dashboard=1 -o yaml | kubectl apply -f -
@@ -1,9 +1,13 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/codespace-operator/common/common/pkg/common"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
@@ -30,9 +34,32 @@ type ControllerConfig struct {
 	SecureMetrics bool `mapstructure:"secure_metrics"`
 	EnableHTTP2   bool `mapstructure:"enable_http2"`
 
-	// Session settings
-	SessionNamePrefix string `mapstructure:"session_name_prefix"`
-	FieldOwner        string `mapstructure:"field_owner"`
+	// Session settings. All but the prefix and field owner are reloaded when
+	// the config file changes.
+	SessionNamePrefix string            `mapstructure:"session_name_prefix"`
+	FieldOwner        string            `mapstructure:"field_owner"`
+	ImagePullSecrets  []string          `mapstructure:"image_pull_secrets"`
+	DefaultImages     map[string]string `mapstructure:"default_images"`
+	OAuth2ProxyImage  string            `mapstructure:"oauth2_proxy_image"`
+	IngressClassName  string            `mapstructure:"ingress_class_name"`
+	// Default hosts, annotations and TLS for Session Ingresses
+	IngressHostTemplate   string            `mapstructure:"ingress_host_template"`
+	IngressDomain         string            `mapstructure:"ingress_domain"`
+	IngressAnnotations    map[string]string `mapstructure:"ingress_annotations"`
+	CertManagerIssuer     string            `mapstructure:"cert_manager_issuer"`
+	CertManagerIssuerKind string            `mapstructure:"cert_manager_issuer_kind"`
+	RequeueInterval       time.Duration     `mapstructure:"requeue_interval"`
+	DriftPolicy           string            `mapstructure:"drift_policy"`
+
+	// Sharding: "" reconciles all Sessions, "auto" derives the instance ID like
+	// the server does, any other value is the server instance ID to serve.
+	InstanceID    string `mapstructure:"instance_id"`
+	ServerAppName string `mapstructure:"server_app_name"`
+
+	// Namespace-scoped mode: watch only these namespaces and/or the namespaces
+	// matching the selector. Both empty watches the whole cluster.
+	WatchNamespaces        []string `mapstructure:"watch_namespaces"`
+	WatchNamespaceSelector string   `mapstructure:"watch_namespace_selector"`
 
 	// Logging
 	Debug bool `mapstructure:"debug"`
@@ -44,6 +71,31 @@ type ControllerConfig struct {
 
 // LoadControllerConfig reads controller-config.yaml + env (CODESPACE_CONTROLLER_*) into ControllerConfig.
 func LoadControllerConfig() (*ControllerConfig, error) {
+	v, err := newControllerViper()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalControllerConfig(v)
+}
+
+// WatchControllerConfig calls onChange with the reloaded configuration whenever
+// the config file changes. It reports false if no config file is in use.
+func WatchControllerConfig(onChange func(*ControllerConfig, error)) (bool, error) {
+	v, err := newControllerViper()
+	if err != nil {
+		return false, err
+	}
+	if v.ConfigFileUsed() == "" {
+		return false, nil
+	}
+	v.OnConfigChange(func(fsnotify.Event) {
+		onChange(unmarshalControllerConfig(v))
+	})
+	v.WatchConfig()
+	return true, nil
+}
+
+func newControllerViper() (*viper.Viper, error) {
 	v := viper.New()
 
 	// Defaults (unchanged from previous)
@@ -65,13 +117,56 @@ func LoadControllerConfig() (*ControllerConfig, error) {
 
 	v.SetDefault("session_name_prefix", "cs-")
 	v.SetDefault("field_owner", "codespace-operator")
+	v.SetDefault("image_pull_secrets", []string{})
+	v.SetDefault("default_images", map[string]string{})
+	v.SetDefault("oauth2_proxy_image", "quay.io/oauth2-proxy/oauth2-proxy:v7.6.0")
+	v.SetDefault("ingress_class_name", "")
+	v.SetDefault("ingress_host_template", "")
+	v.SetDefault("ingress_domain", "")
+	v.SetDefault("ingress_annotations", map[string]string{})
+	v.SetDefault("cert_manager_issuer", "")
+	v.SetDefault("cert_manager_issuer_kind", "ClusterIssuer")
+	v.SetDefault("requeue_interval", "2m")
+	v.SetDefault("drift_policy", "revert")
+	v.SetDefault("instance_id", "")
+	v.SetDefault("server_app_name", "codespace-server")
+	v.SetDefault("watch_namespaces", []string{})
+	v.SetDefault("watch_namespace_selector", "")
 
 	v.SetDefault("debug", false)
 	// Auth config file path - must have a default for viper to recognize the env var
 	v.SetDefault("auth_config_path", "")
 
 	common.SetupViper(v, "CODESPACE_CONTROLLER", "controller-config")
+	// SetupViper only reads an explicit file; look in the search paths too
+	if v.ConfigFileUsed() == "" {
+		var notFound viper.ConfigFileNotFoundError
+		if err := v.ReadInConfig(); err != nil && !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to read controller config: %w", err)
+		}
+	}
+	return v, nil
+}
+
+// Validate checks the settings that cannot be fixed up with a default.
+func (c *ControllerConfig) Validate() error {
+	switch c.DriftPolicy {
+	case DriftPolicyRevert, DriftPolicyReport:
+	default:
+		return fmt.Errorf("invalid drift_policy %q (want %s or %s)", c.DriftPolicy, DriftPolicyRevert, DriftPolicyReport)
+	}
+	if strings.Contains(c.IngressHostTemplate, "{domain}") && c.IngressDomain == "" {
+		return errors.New("ingress_host_template uses {domain} but ingress_domain is not set")
+	}
+	switch c.CertManagerIssuerKind {
+	case "", "Issuer", "ClusterIssuer":
+	default:
+		return fmt.Errorf("invalid cert_manager_issuer_kind %q (want Issuer or ClusterIssuer)", c.CertManagerIssuerKind)
+	}
+	return nil
+}
 
+func unmarshalControllerConfig(v *viper.Viper) (*ControllerConfig, error) {
 	var cfg ControllerConfig
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal controller config: %w", err)
@@ -18,11 +18,9 @@ package controller
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	appsv1apply "k8s.io/client-go/applyconfigurations/apps/v1"
 	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 	metav1apply "k8s.io/client-go/applyconfigurations/meta/v1"
@@ -31,81 +29,44 @@ import (
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
 )
 
-func (r *SessionReconciler) reconcileDeployment(ctx context.Context, sess *codespacev1.Session, name string, labels map[string]string) (*appsv1.Deployment, error) {
+func (r *SessionReconciler) reconcileDeployment(ctx context.Context, sess *codespacev1.Session, opts *Options, name string, labels map[string]string) (*appsv1.Deployment, error) {
 	ns := sess.Namespace
 	port := r.determinePort(sess)
-	vols, mounts := r.buildVolumesAndMounts(sess, name)
-
-	// Convert mounts/volumes to apply configurations
-	var acMounts []*corev1apply.VolumeMountApplyConfiguration
-	for _, m := range mounts {
-		acMounts = append(acMounts, corev1apply.VolumeMount().WithName(m.Name).WithMountPath(m.MountPath))
-	}
-	var acVols []*corev1apply.VolumeApplyConfiguration
-	for _, v := range vols {
-		acVols = append(acVols, corev1apply.Volume().
-			WithName(v.Name).
-			WithPersistentVolumeClaim(corev1apply.PersistentVolumeClaimVolumeSource().
-				WithClaimName(v.PersistentVolumeClaim.ClaimName)))
-	}
+	vols, mounts, devices := r.buildVolumesAndMounts(sess, name)
 
 	mainC := corev1apply.Container().
 		WithName("ide").
 		WithImage(sess.Spec.Profile.Image).
 		WithArgs(sess.Spec.Profile.Cmd...).
+		WithEnv(corev1apply.EnvVar().WithName(codespacev1.BaseURLEnv).WithValue(baseURL(sess))).
 		WithPorts(corev1apply.ContainerPort().WithContainerPort(port)).
-		WithVolumeMounts(acMounts...)
+		WithVolumeMounts(mounts...).
+		WithVolumeDevices(devices...)
 
-	containers := []*corev1apply.ContainerApplyConfiguration{mainC}
-	if sess.Spec.Auth.Mode == "oauth2proxy" && sess.Spec.Networking != nil && sess.Spec.Networking.Host != "" {
-		sidecar := corev1apply.Container().
-			WithName("oauth2-proxy").
-			WithImage("quay.io/oauth2-proxy/oauth2-proxy:v7.6.0").
-			WithArgs(
-				"--provider=oidc",
-				"--oidc-issuer-url=$(OIDC_ISSUER_URL)",
-				"--client-id=$(OIDC_CLIENT_ID)",
-				"--client-secret=$(OIDC_CLIENT_SECRET)",
-				fmt.Sprintf("--upstream=http://127.0.0.1:%d", port),
-				"--http-address=0.0.0.0:4180",
-				"--reverse-proxy=true",
-				"--email-domain=*",
-			).
-			WithPorts(corev1apply.ContainerPort().WithContainerPort(4180))
-		if sess.Spec.Auth.OIDC != nil {
-			sidecar = sidecar.
-				WithEnv(
-					corev1apply.EnvVar().WithName("OIDC_ISSUER_URL").WithValue(sess.Spec.Auth.OIDC.IssuerURL),
-					corev1apply.EnvVar().WithName("OIDC_CLIENT_ID").WithValueFrom(
-						corev1apply.EnvVarSource().WithSecretKeyRef(
-							corev1apply.SecretKeySelector().WithName(sess.Spec.Auth.OIDC.ClientIDSecret).WithKey("clientID"),
-						)),
-					corev1apply.EnvVar().WithName("OIDC_CLIENT_SECRET").WithValueFrom(
-						corev1apply.EnvVarSource().WithSecretKeyRef(
-							corev1apply.SecretKeySelector().WithName(sess.Spec.Auth.OIDC.ClientSecretRef).WithKey("clientSecret"),
-						)),
-				)
-		}
-		containers = append(containers, sidecar)
+	for _, p := range sess.Spec.Ports {
+		mainC.WithPorts(corev1apply.ContainerPort().WithName(p.Name).WithContainerPort(p.ContainerPort))
+	}
+
+	sidecars, err := sidecarContainers(sess)
+	if err != nil {
+		return nil, err
+	}
+	containers := append([]*corev1apply.ContainerApplyConfiguration{mainC}, sidecars...)
+	if authProxyEnabled(sess) {
+		containers = append(containers, r.authProxyContainers(sess, opts, port)...)
 	}
 
-	dep := appsv1apply.Deployment(name, ns).
-		WithLabels(labels).
+	template := corev1apply.PodTemplateSpec().
+		WithLabels(r.podLabels(sess, labels)).
 		WithSpec(
-			appsv1apply.DeploymentSpec().
-				WithSelector(metav1apply.LabelSelector().WithMatchLabels(labels)).
-				WithReplicas(*sess.Spec.Replicas).
-				WithTemplate(
-					corev1apply.PodTemplateSpec().
-						WithLabels(labels).
-						WithSpec(
-							corev1apply.PodSpec().
-								WithServiceAccountName(name).
-								WithVolumes(acVols...).
-								WithContainers(containers...),
-						),
-				),
+			corev1apply.PodSpec().
+				WithServiceAccountName(name).
+				WithVolumes(vols...).
+				WithContainers(containers...),
 		)
+	if restartedAt := sess.Annotations[codespacev1.RestartAnnotation]; restartedAt != "" {
+		template.WithAnnotations(map[string]string{codespacev1.RestartAnnotation: restartedAt})
+	}
 
 	owner := metav1apply.OwnerReference().
 		WithAPIVersion(codespacev1.GroupVersion.String()).
@@ -114,17 +75,36 @@ func (r *SessionReconciler) reconcileDeployment(ctx context.Context, sess *codes
 		WithUID(sess.UID).
 		WithController(true).
 		WithBlockOwnerDeletion(true)
-	dep.WithOwnerReferences(owner)
+	deployment := func(strategy appsv1.DeploymentStrategyType) *appsv1apply.DeploymentApplyConfiguration {
+		return appsv1apply.Deployment(name, ns).
+			WithLabels(r.podLabels(sess, labels)).
+			WithOwnerReferences(owner).
+			WithSpec(
+				appsv1apply.DeploymentSpec().
+					WithSelector(metav1apply.LabelSelector().WithMatchLabels(labels)).
+					WithReplicas(*sess.Spec.Replicas).
+					WithStrategy(strategyConfig(strategy)).
+					WithTemplate(template),
+			)
+	}
 
-	data, err := json.Marshal(dep)
-	if err != nil {
-		return nil, err
+	strategy := deploymentStrategy(sess)
+	if strategy == appsv1.RecreateDeploymentStrategyType {
+		// The API server defaulted the rolling update parameters of Deployments
+		// created without a strategy, and Recreate forbids them. Own them first,
+		// so the Recreate apply below removes them.
+		var live appsv1.Deployment
+		err := r.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &live)
+		switch {
+		case err == nil && live.Spec.Strategy.RollingUpdate != nil:
+			if err := r.applyDeployment(ctx, sess, opts, deployment(appsv1.RollingUpdateDeploymentStrategyType)); err != nil {
+				return nil, err
+			}
+		case client.IgnoreNotFound(err) != nil:
+			return nil, err
+		}
 	}
-	if err := r.Patch(ctx,
-		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}},
-		client.RawPatch(types.ApplyPatchType, data),
-		client.FieldOwner(ssaFieldOwner),
-	); err != nil {
+	if err := r.applyDeployment(ctx, sess, opts, deployment(strategy)); err != nil {
 		return nil, err
 	}
 
@@ -135,3 +115,13 @@ func (r *SessionReconciler) reconcileDeployment(ctx context.Context, sess *codes
 	}
 	return out, nil
 }
+
+// applyDeployment server-side applies the Session Deployment.
+func (r *SessionReconciler) applyDeployment(ctx context.Context, sess *codespacev1.Session, opts *Options, dep *appsv1apply.DeploymentApplyConfiguration) error {
+	data, err := json.Marshal(dep)
+	if err != nil {
+		return err
+	}
+	return r.apply(ctx, sess, opts, "Deployment",
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: *dep.GetName(), Namespace: *dep.GetNamespace()}}, data)
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// Drift policies: what to do when another field manager changed a field the
+// operator applies to a child resource.
+const (
+	// DriftPolicyRevert takes the fields back (apply with force) and records it.
+	DriftPolicyRevert = "revert"
+	// DriftPolicyReport leaves the changed fields in place and reports them in
+	// the Session status until they go away; the rest of the object is still
+	// applied.
+	DriftPolicyReport = "report"
+)
+
+// conflictManager extracts the field manager from a server-side apply conflict
+// cause, e.g. `conflict with "kubectl-edit" using apps/v1`.
+var conflictManager = regexp.MustCompile(`^conflict with "([^"]*)"`)
+
+// apply server-side applies data to obj and handles drift according to the
+// drift policy. A conflict means another manager changed fields the operator
+// owns: with "revert" they are re-applied with force, with "report" the rest of
+// data is applied without them. If that is not possible the object is left as
+// it is and reported as not reconciled. Either way obj holds the live object
+// afterwards.
+func (r *SessionReconciler) apply(ctx context.Context, sess *codespacev1.Session, opts *Options, kind string, obj client.Object, data []byte) error {
+	err := r.Patch(ctx, obj, client.RawPatch(types.ApplyPatchType, data), client.FieldOwner(opts.FieldOwner))
+	fields, managers := applyConflicts(err)
+	if len(fields) == 0 {
+		if err == nil {
+			r.clearDrift(sess, kind, obj.GetName())
+		}
+		return err
+	}
+
+	d := codespacev1.DriftStatus{
+		Kind:       kind,
+		Name:       obj.GetName(),
+		Fields:     fields,
+		Managers:   managers,
+		DetectedAt: metav1.Now(),
+	}
+	if opts.DriftPolicy == DriftPolicyReport {
+		d.Action = codespacev1.DriftReported
+		if rest, ok := withoutFields(data, fields); ok {
+			err := r.Patch(ctx, obj, client.RawPatch(types.ApplyPatchType, rest), client.FieldOwner(opts.FieldOwner))
+			if err == nil {
+				r.recordDrift(sess, d)
+				return nil
+			}
+			if !apierrors.IsConflict(err) {
+				return err
+			}
+		}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			return err
+		}
+		d.Stale = true
+		r.recordDrift(sess, d)
+		return nil
+	}
+	if err := r.Patch(ctx, obj, client.RawPatch(types.ApplyPatchType, data),
+		client.FieldOwner(opts.FieldOwner), client.ForceOwnership); err != nil {
+		return err
+	}
+	d.Action = codespacev1.DriftReverted
+	r.recordDrift(sess, d)
+	return nil
+}
+
+// applyConflicts returns the conflicting fields and their managers when err is
+// a server-side apply conflict.
+func applyConflicts(err error) (fields, managers []string) {
+	var status apierrors.APIStatus
+	if !apierrors.IsConflict(err) || !errors.As(err, &status) || status.Status().Details == nil {
+		return nil, nil
+	}
+	for _, c := range status.Status().Details.Causes {
+		if c.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		fields = append(fields, c.Field)
+		if m := conflictManager.FindStringSubmatch(c.Message); m != nil && !slices.Contains(managers, m[1]) {
+			managers = append(managers, m[1])
+		}
+	}
+	return fields, managers
+}
+
+// recordDrift stores d in the Session status, replacing the previous record for
+// the same object, and emits an event when the drift is new.
+func (r *SessionReconciler) recordDrift(sess *codespacev1.Session, d codespacev1.DriftStatus) {
+	i := slices.IndexFunc(sess.Status.Drift, func(o codespacev1.DriftStatus) bool {
+		return o.Kind == d.Kind && o.Name == d.Name
+	})
+	if i < 0 {
+		sess.Status.Drift = append(sess.Status.Drift, d)
+	} else {
+		prev := sess.Status.Drift[i]
+		// A reported drift is seen on every reconcile; it is not new
+		if prev.Action == d.Action && d.Action == codespacev1.DriftReported && prev.Stale == d.Stale && slices.Equal(prev.Fields, d.Fields) {
+			return
+		}
+		sess.Status.Drift[i] = d
+	}
+	countDrift(d.Kind, d.Action)
+
+	by := strings.Join(d.Managers, ", ")
+	if d.Action == codespacev1.DriftReverted {
+		r.event(sess, corev1.EventTypeNormal, reasonDriftReverted,
+			"Reverted changes by %s to %s %s: %s", by, d.Kind, d.Name, strings.Join(d.Fields, ", "))
+	} else if d.Stale {
+		r.event(sess, corev1.EventTypeWarning, reasonDriftDetected,
+			"%s %s was changed by %s: %s; it is no longer reconciled", d.Kind, d.Name, by, strings.Join(d.Fields, ", "))
+	} else {
+		r.event(sess, corev1.EventTypeWarning, reasonDriftDetected,
+			"%s %s was changed by %s: %s", d.Kind, d.Name, by, strings.Join(d.Fields, ", "))
+	}
+	r.setDriftCondition(sess)
+}
+
+// clearDrift drops a reported drift once the object applies cleanly again.
+// Reverted drift stays on record until the object drifts again.
+func (r *SessionReconciler) clearDrift(sess *codespacev1.Session, kind, name string) {
+	n := len(sess.Status.Drift)
+	sess.Status.Drift = slices.DeleteFunc(sess.Status.Drift, func(d codespacev1.DriftStatus) bool {
+		return d.Kind == kind && d.Name == name && d.Action == codespacev1.DriftReported
+	})
+	if len(sess.Status.Drift) != n {
+		r.setDriftCondition(sess)
+	}
+}
+
+// forgetDrift drops any drift record of an object that no longer exists.
+func forgetDrift(sess *codespacev1.Session, kind, name string) {
+	sess.Status.Drift = slices.DeleteFunc(sess.Status.Drift, func(d codespacev1.DriftStatus) bool {
+		return d.Kind == kind && d.Name == name
+	})
+}
+
+// setDriftCondition sets the Drifted condition from the reported drift.
+func (r *SessionReconciler) setDriftCondition(sess *codespacev1.Session) {
+	cond := metav1.Condition{
+		Type:               codespacev1.ConditionDrifted,
+		Status:             metav1.ConditionFalse,
+		Reason:             "InSync",
+		Message:            "Child resources match the Session",
+		ObservedGeneration: sess.Generation,
+	}
+	var drifted, stale []string
+	for _, d := range sess.Status.Drift {
+		switch {
+		case d.Action != codespacev1.DriftReported:
+		case d.Stale:
+			stale = append(stale, d.Kind+" "+d.Name)
+		default:
+			drifted = append(drifted, d.Kind+" "+d.Name)
+		}
+	}
+	if len(drifted) > 0 {
+		cond.Status, cond.Reason = metav1.ConditionTrue, "DriftReported"
+		cond.Message = fmt.Sprintf("Changed outside the operator: %s", strings.Join(drifted, ", "))
+	}
+	if len(stale) > 0 {
+		cond.Status, cond.Reason = metav1.ConditionTrue, "NotReconciled"
+		cond.Message = fmt.Sprintf("Changed outside the operator and no longer reconciled: %s", strings.Join(stale, ", "))
+		if len(drifted) > 0 {
+			cond.Message += fmt.Sprintf("; changed outside the operator: %s", strings.Join(drifted, ", "))
+		}
+	}
+	meta.SetStatusCondition(&sess.Status.Conditions, cond)
+}
+
+// withoutFields removes the field paths of an apply conflict from the apply
+// configuration data, e.g. ".spec.replicas" or
+// `.spec.template.spec.containers[name="ide"].image`. It reports false if a
+// path cannot be resolved.
+func withoutFields(data []byte, fields []string) ([]byte, bool) {
+	var obj any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, false
+	}
+	for _, f := range fields {
+		var ok bool
+		if obj, ok = removePath(obj, f); !ok {
+			return nil, false
+		}
+	}
+	out, err := json.Marshal(obj)
+	return out, err == nil
+}
+
+// removePath removes the field at path from v and returns the updated v. A
+// path is a sequence of ".field", "[key=value,...]" (list item by its keys)
+// and "[=value]" (set item) elements, values being JSON.
+func removePath(v any, path string) (any, bool) {
+	switch {
+	case strings.HasPrefix(path, "."):
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v, false
+		}
+		// Field names may contain dots, e.g. annotation keys: take the longest
+		// one present that ends at an element boundary.
+		name := ""
+		for k := range m {
+			if len(k) > len(name) && strings.HasPrefix(path[1:], k) {
+				if r := path[1+len(k):]; r == "" || r[0] == '.' || r[0] == '[' {
+					name = k
+				}
+			}
+		}
+		if name == "" {
+			return v, false
+		}
+		rest := path[1+len(name):]
+		if rest == "" {
+			delete(m, name)
+			return m, true
+		}
+		m[name], ok = removePath(m[name], rest)
+		return m, ok
+	case strings.HasPrefix(path, "["):
+		list, ok := v.([]any)
+		if !ok {
+			return v, false
+		}
+		match, rest, ok := parseListSelector(path)
+		if !ok {
+			return v, false
+		}
+		for i, item := range list {
+			if !match(item) {
+				continue
+			}
+			if rest == "" {
+				return slices.Delete(list, i, i+1), true
+			}
+			list[i], ok = removePath(item, rest)
+			return list, ok
+		}
+	}
+	return v, false
+}
+
+// parseListSelector parses the "[...]" element at the start of path into a
+// matcher of list items, and returns the rest of path.
+func parseListSelector(path string) (match func(any) bool, rest string, ok bool) {
+	s := path[1:]
+	want := map[string]any{}
+	var value any
+	isValue := strings.HasPrefix(s, "=")
+	for {
+		var key string
+		if isValue {
+			s = s[1:]
+		} else {
+			i := strings.IndexByte(s, '=')
+			if i <= 0 {
+				return nil, "", false
+			}
+			key, s = s[:i], s[i+1:]
+		}
+		dec := json.NewDecoder(strings.NewReader(s))
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, "", false
+		}
+		s = s[dec.InputOffset():]
+		if isValue {
+			value = v
+		} else {
+			want[key] = v
+		}
+		if strings.HasPrefix(s, "]") {
+			break
+		}
+		if isValue || !strings.HasPrefix(s, ",") {
+			return nil, "", false
+		}
+		s = s[1:]
+	}
+	if isValue {
+		return func(item any) bool { return reflect.DeepEqual(item, value) }, s[1:], true
+	}
+	return func(item any) bool {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range want {
+			if !reflect.DeepEqual(m[k], v) {
+				return false
+			}
+		}
+		return true
+	}, s[1:], true
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWithoutFields(t *testing.T) {
+	const dep = `{"metadata":{"annotations":{"codespace.dev/restarted-at":"now","a":"b"}},
+		"spec":{"replicas":1,"template":{"spec":{"containers":[
+			{"name":"ide","image":"jupyter","ports":[{"containerPort":8888,"protocol":"TCP","name":"http"}]},
+			{"name":"db","image":"postgres"}]}},
+		"finalizers":["x","y"]}}`
+	for _, tc := range []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		{"field", []string{".spec.replicas"}, `{"metadata":{"annotations":{"codespace.dev/restarted-at":"now","a":"b"}},
+			"spec":{"template":{"spec":{"containers":[
+				{"name":"ide","image":"jupyter","ports":[{"containerPort":8888,"protocol":"TCP","name":"http"}]},
+				{"name":"db","image":"postgres"}]}},
+			"finalizers":["x","y"]}}`},
+		{"dotted key", []string{".metadata.annotations.codespace.dev/restarted-at"}, `{"metadata":{"annotations":{"a":"b"}},
+			"spec":{"replicas":1,"template":{"spec":{"containers":[
+				{"name":"ide","image":"jupyter","ports":[{"containerPort":8888,"protocol":"TCP","name":"http"}]},
+				{"name":"db","image":"postgres"}]}},
+			"finalizers":["x","y"]}}`},
+		{"keyed item field", []string{`.spec.template.spec.containers[name="db"].image`, `.spec.template.spec.containers[name="ide"].ports[containerPort=8888,protocol="TCP"].name`},
+			`{"metadata":{"annotations":{"codespace.dev/restarted-at":"now","a":"b"}},
+			"spec":{"replicas":1,"template":{"spec":{"containers":[
+				{"name":"ide","image":"jupyter","ports":[{"containerPort":8888,"protocol":"TCP"}]},
+				{"name":"db"}]}},
+			"finalizers":["x","y"]}}`},
+		{"set item", []string{`.spec.finalizers[="x"]`, `.spec.template.spec.containers[name="db"]`}, `{"metadata":{"annotations":{"codespace.dev/restarted-at":"now","a":"b"}},
+			"spec":{"replicas":1,"template":{"spec":{"containers":[
+				{"name":"ide","image":"jupyter","ports":[{"containerPort":8888,"protocol":"TCP","name":"http"}]}]}},
+			"finalizers":["y"]}}`},
+		{"missing", []string{".spec.paused"}, ""},
+		{"missing item", []string{`.spec.template.spec.containers[name="sidecar"].image`}, ""},
+		{"index", []string{".spec.template.spec.containers[0].image"}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := withoutFields([]byte(dep), tc.fields)
+			if ok != (tc.want != "") {
+				t.Fatalf("withoutFields() ok = %v", ok)
+			}
+			if !ok {
+				return
+			}
+			var g, w any
+			if err := json.Unmarshal(got, &g); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &w); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g, w) {
+				t.Fatalf("withoutFields() = %s", got)
+			}
+		})
+	}
+}
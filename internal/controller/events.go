@@ -0,0 +1,94 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// Event reasons recorded against a Session. They show up in `kubectl describe session`
+// and are served by the API server under /api/v1/server/sessions/{ns}/{name}/events.
+const (
+	reasonServiceAccountFailed = "ServiceAccountFailed"
+	reasonPVCFailed            = "PersistentVolumeClaimFailed"
+	reasonDeploymentFailed     = "DeploymentFailed"
+	reasonServiceFailed        = "ServiceFailed"
+	reasonIngressFailed        = "IngressFailed"
+	reasonStatusUpdateFailed   = "StatusUpdateFailed"
+	reasonSnapshotFailed       = "SnapshotFailed"
+	reasonRestoreFailed        = "RestoreFailed"
+	reasonVolumeResizeFailed   = "VolumeResizeFailed"
+	reasonPruneFailed          = "PruneFailed"
+	reasonCertificateFailed    = "CertificateFailed"
+
+	reasonImagePullSecretMissing = "ImagePullSecretMissing"
+	reasonOrphaned               = "Orphaned"
+	reasonDriftDetected          = "DriftDetected"
+
+	reasonSnapshotCreated  = "SnapshotCreated"
+	reasonSnapshotPruned   = "SnapshotPruned"
+	reasonRestoreStarted   = "RestoreStarted"
+	reasonRestoreCompleted = "RestoreCompleted"
+
+	reasonVolumeResizing      = "VolumeResizing"
+	reasonVolumeResizeRestart = "VolumeResizeRestart"
+	reasonDriftReverted       = "DriftReverted"
+	reasonPruned              = "Pruned"
+	reasonVolumeRetained      = "VolumeRetained"
+
+	reasonPhaseChanged = "PhaseChanged"
+	reasonSuspended    = "Suspended"
+	reasonResumed      = "Resumed"
+
+	reasonDeleting               = "Deleting"
+	reasonFinalizerRemoved       = "FinalizerRemoved"
+	reasonFinalizerRemovalFailed = "FinalizerRemovalFailed"
+)
+
+// event records a Kubernetes Event for the Session. It is a no-op when no recorder
+// is wired (e.g. in unit tests that build the reconciler by hand).
+func (r *SessionReconciler) event(sess *codespacev1.Session, eventType, reason, messageFmt string, args ...any) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(sess, eventType, reason, messageFmt, args...)
+}
+
+// recordPhaseChange emits events for a phase transition, including the
+// suspend/resume transitions caused by scaling a Session to or from zero.
+func (r *SessionReconciler) recordPhaseChange(sess *codespacev1.Session, from, to string) {
+	if from == to {
+		return
+	}
+	eventType := corev1.EventTypeNormal
+	if to == codespacev1.SessionPhaseError {
+		eventType = corev1.EventTypeWarning
+	}
+	if from == "" {
+		r.event(sess, eventType, reasonPhaseChanged, "Session entered phase %s", to)
+	} else {
+		r.event(sess, eventType, reasonPhaseChanged, "Session phase changed from %s to %s", from, to)
+	}
+
+	switch {
+	case to == codespacev1.SessionPhaseSuspended:
+		r.event(sess, corev1.EventTypeNormal, reasonSuspended, "Session scaled to zero replicas")
+	case from == codespacev1.SessionPhaseSuspended:
+		r.event(sess, corev1.EventTypeNormal, reasonResumed, "Session resumed with %d replica(s)", *sess.Spec.Replicas)
+	}
+}
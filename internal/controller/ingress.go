@@ -26,44 +26,66 @@ import (
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
 	netv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *SessionReconciler) reconcileIngress(ctx context.Context, sess *codespacev1.Session, name, svcName string) error {
+func (r *SessionReconciler) reconcileIngress(ctx context.Context, sess *codespacev1.Session, opts *Options, name, svcName string) error {
 	if sess.Spec.Networking == nil || sess.Spec.Networking.Host == "" {
 		return nil
 	}
 	ns := sess.Namespace
 	host := sess.Spec.Networking.Host
 
-	pt := netv1.PathTypePrefix
-	path := netv1apply.HTTPIngressPath().
-		WithPath("/").
-		WithPathType(pt).
-		WithBackend(
-			netv1apply.IngressBackend().
-				WithService(
-					netv1apply.IngressServiceBackend().
-						WithName(svcName).
-						WithPort(netv1apply.ServiceBackendPort().WithNumber(80)),
-				),
-		)
+	backend := func(port *netv1apply.ServiceBackendPortApplyConfiguration) *netv1apply.IngressBackendApplyConfiguration {
+		return netv1apply.IngressBackend().
+			WithService(
+				netv1apply.IngressServiceBackend().
+					WithName(svcName).
+					WithPort(port),
+			)
+	}
+	route := func(path string, port *netv1apply.ServiceBackendPortApplyConfiguration) *netv1apply.HTTPIngressPathApplyConfiguration {
+		return netv1apply.HTTPIngressPath().
+			WithPath(path).
+			WithPathType(netv1.PathTypePrefix).
+			WithBackend(backend(port))
+	}
+
+	// The IDE at "/", extra ports under their path on the same host or on their own subdomain
+	paths := []*netv1apply.HTTPIngressPathApplyConfiguration{
+		route("/", netv1apply.ServiceBackendPort().WithNumber(80)),
+	}
+	var subdomainRules []*netv1apply.IngressRuleApplyConfiguration
+	for _, p := range sess.Spec.Ports {
+		port := netv1apply.ServiceBackendPort().WithName(p.Name)
+		switch {
+		case p.Path != "":
+			paths = append(paths, route(p.Path, port))
+		case p.Subdomain != "":
+			subdomainRules = append(subdomainRules, netv1apply.IngressRule().
+				WithHost(portHost(sess, p)).
+				WithHTTP(netv1apply.HTTPIngressRuleValue().WithPaths(route("/", port))))
+		}
+	}
 
 	ing := netv1apply.Ingress(name, ns).
-		WithAnnotations(sess.Spec.Networking.Annotations).
+		WithLabels(r.childLabels(sess)).
+		WithAnnotations(r.ingressAnnotations(sess, opts)).
 		WithSpec(
 			netv1apply.IngressSpec().
 				WithRules(
 					netv1apply.IngressRule().
 						WithHost(host).
-						WithHTTP(netv1apply.HTTPIngressRuleValue().WithPaths(path)),
-				),
+						WithHTTP(netv1apply.HTTPIngressRuleValue().WithPaths(paths...)),
+				).
+				WithRules(subdomainRules...),
 		)
 
+	if class := opts.IngressClassName; class != "" {
+		ing.Spec.WithIngressClassName(class)
+	}
 	if tls := sess.Spec.Networking.TLSSecretName; tls != "" {
 		ing.Spec.WithTLS(netv1apply.IngressTLS().
-			WithHosts(host).
+			WithHosts(publicHosts(sess)...).
 			WithSecretName(tls))
 	}
 
@@ -80,9 +102,6 @@ func (r *SessionReconciler) reconcileIngress(ctx context.Context, sess *codespac
 	if err != nil {
 		return err
 	}
-	return r.Patch(ctx,
-		&netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}},
-		client.RawPatch(types.ApplyPatchType, data),
-		client.FieldOwner(ssaFieldOwner),
-	)
+	return r.apply(ctx, sess, opts, "Ingress",
+		&netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}}, data)
 }
@@ -0,0 +1,184 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codespace-operator/common/common/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get
+//+kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get
+
+// InstanceIDAuto makes the controller serve the server running in its
+// namespace, reading the ID the server recorded.
+const InstanceIDAuto = "auto"
+
+// serverIDConfigMapPrefix matches the ConfigMap the server records its ID in.
+const serverIDConfigMapPrefix = "codespace-server-id"
+
+// instanceIndexTTL is how long the list of known server instances is reused.
+const instanceIndexTTL = time.Minute
+
+// ResolveInstanceID turns the configured instance_id into the ID to shard on:
+// empty reconciles every Session, "auto" reads the ID of the server installed
+// alongside the controller, anything else is used as is.
+//
+// With "auto", the server's ConfigMap is found by name when both share a
+// Helm/Argo release, as the name derives from it; otherwise it must be the only
+// ConfigMap of a server named serverAppName in the controller's namespace. An
+// error is returned when there is none or several: computing an ID instead
+// would silently shard onto Sessions no server creates.
+func ResolveInstanceID(ctx context.Context, cl client.Client, configured, serverAppName string) (string, error) {
+	switch configured {
+	case "":
+		return "", nil
+	case InstanceIDAuto:
+	default:
+		if errs := validation.IsValidLabelValue(configured); len(errs) > 0 {
+			return "", fmt.Errorf("invalid instance_id %q: %s", configured, strings.Join(errs, "; "))
+		}
+		return configured, nil
+	}
+
+	anchor, _, _ := common.GetSelfAnchorMeta(ctx, cl)
+	var cm corev1.ConfigMap
+	key := client.ObjectKey{
+		Namespace: anchor.Namespace,
+		Name:      fmt.Sprintf("%s-%s", serverIDConfigMapPrefix, common.K8sHexHash(anchor.String(), 10)),
+	}
+	if err := cl.Get(ctx, key, &cm); err == nil && cm.Data["id"] != "" {
+		return cm.Data["id"], nil
+	} else if err != nil && !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("read server instance ConfigMap: %w", err)
+	}
+
+	var cms corev1.ConfigMapList
+	if err := cl.List(ctx, &cms, client.InNamespace(anchor.Namespace),
+		client.MatchingLabels{"app.kubernetes.io/managed-by": serverAppName}); err != nil {
+		return "", fmt.Errorf("list server instance ConfigMaps: %w", err)
+	}
+	var ids []string
+	for _, cm := range cms.Items {
+		if id := cm.Data["id"]; strings.HasPrefix(cm.Name, serverIDConfigMapPrefix) && id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	switch len(ids) {
+	case 1:
+		return ids[0], nil
+	case 0:
+		return "", fmt.Errorf("instance_id %q: no instance ConfigMap of server %q in namespace %s; start the server first, or set instance_id to its ID",
+			configured, serverAppName, anchor.Namespace)
+	default:
+		return "", fmt.Errorf("instance_id %q: servers %s all run in namespace %s; set instance_id to one of them",
+			configured, strings.Join(ids, ", "), anchor.Namespace)
+	}
+}
+
+// CacheOptions configures the manager cache for a sharded and/or namespace-scoped
+// controller: the Session cache is restricted to one instance's Sessions, so
+// installations sharing a cluster never see (let alone apply) each other's, and
+// all namespaced objects are only watched in the given namespaces.
+func CacheOptions(instanceID string, namespaces []string) cache.Options {
+	// Pods are only watched for container statuses, so only Session pods are cached
+	sessionPods, _ := labels.NewRequirement(codespacev1.SessionNameLabel, selection.Exists, nil)
+	opts := cache.Options{ByObject: map[client.Object]cache.ByObject{
+		&corev1.Pod{}: {Label: labels.NewSelector().Add(*sessionPods)},
+	}}
+	if len(namespaces) > 0 {
+		opts.DefaultNamespaces = map[string]cache.Config{}
+		for _, ns := range namespaces {
+			opts.DefaultNamespaces[ns] = cache.Config{}
+		}
+	}
+	if instanceID != "" {
+		opts.ByObject[&codespacev1.Session{}] = cache.ByObject{
+			Label: labels.SelectorFromSet(labels.Set{common.InstanceIDLabel: instanceID}),
+		}
+	}
+	return opts
+}
+
+// ownsSession reports whether this controller reconciles sess.
+func (r *SessionReconciler) ownsSession(obj client.Object) bool {
+	return r.InstanceID == "" || obj.GetLabels()[common.InstanceIDLabel] == r.InstanceID
+}
+
+// instanceIndex caches the IDs of the server instances in the cluster.
+type instanceIndex struct {
+	mu      sync.Mutex
+	ids     map[string]bool
+	fetched time.Time
+}
+
+// knownInstance reports whether a server instance with the ID exists. ok is false
+// when no instance could be listed at all (e.g. missing RBAC), so nothing is known.
+func (r *SessionReconciler) knownInstance(ctx context.Context, id string) (known, ok bool) {
+	r.instances.mu.Lock()
+	defer r.instances.mu.Unlock()
+	if r.instances.ids == nil || time.Since(r.instances.fetched) > instanceIndexTTL {
+		r.instances.ids = map[string]bool{}
+		for k := range common.BuildInstanceMetaIndex(ctx, r.Client, r.ServerAppName) {
+			r.instances.ids[k] = true
+		}
+		r.instances.fetched = time.Now()
+	}
+	return r.instances.ids[id], len(r.instances.ids) > 0
+}
+
+// reconcileOwnership sets the Owned condition. A Session is orphaned when it has
+// no instance label, or the server instance it names no longer exists; it keeps
+// being reconciled, and can be taken over with the server's adopt endpoint.
+func (r *SessionReconciler) reconcileOwnership(ctx context.Context, sess *codespacev1.Session) {
+	id := sess.Labels[common.InstanceIDLabel]
+	cond := metav1.Condition{
+		Type:               codespacev1.ConditionOwned,
+		Status:             metav1.ConditionTrue,
+		Reason:             "InstanceFound",
+		Message:            "Managed by instance " + id,
+		ObservedGeneration: sess.Generation,
+	}
+	if id == "" {
+		cond.Status, cond.Reason = metav1.ConditionFalse, "Orphaned"
+		cond.Message = "Session has no instance label; adopt it through the server"
+	} else if known, ok := r.knownInstance(ctx, id); !ok {
+		return
+	} else if !known {
+		cond.Status, cond.Reason = metav1.ConditionFalse, "Orphaned"
+		cond.Message = fmt.Sprintf("Instance %s no longer exists; adopt the session through the server", id)
+	}
+	if meta.SetStatusCondition(&sess.Status.Conditions, cond) && cond.Status == metav1.ConditionFalse {
+		r.event(sess, corev1.EventTypeWarning, reasonOrphaned, "%s", cond.Message)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/codespace-operator/common/common/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveInstanceIDAuto(t *testing.T) {
+	ctx := context.Background()
+	anchor, _, _ := common.GetSelfAnchorMeta(ctx, fake.NewClientBuilder().Build())
+	serverCM := func(name, id string, labels map[string]string) client.Object {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: anchor.Namespace, Labels: labels},
+			Data:       map[string]string{"id": id},
+		}
+	}
+	byServer := map[string]string{"app.kubernetes.io/managed-by": "codespace-server"}
+	sameRelease := fmt.Sprintf("%s-%s", serverIDConfigMapPrefix, common.K8sHexHash(anchor.String(), 10))
+
+	for _, tc := range []struct {
+		name    string
+		objs    []client.Object
+		want    string
+		wantErr bool
+	}{
+		{"same release", []client.Object{serverCM(sameRelease, "abc", nil), serverCM(serverIDConfigMapPrefix+"-x", "def", byServer)}, "abc", false},
+		{"only server", []client.Object{serverCM(serverIDConfigMapPrefix+"-x", "def", byServer)}, "def", false},
+		{"other app", []client.Object{serverCM(serverIDConfigMapPrefix+"-x", "def", map[string]string{"app.kubernetes.io/managed-by": "other"})}, "", true},
+		{"none", nil, "", true},
+		{"several", []client.Object{
+			serverCM(serverIDConfigMapPrefix+"-x", "def", byServer),
+			serverCM(serverIDConfigMapPrefix+"-y", "ghi", byServer),
+		}, "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tc.objs...).Build()
+			got, err := ResolveInstanceID(ctx, cl, InstanceIDAuto, "codespace-server")
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Fatalf("ResolveInstanceID() = %q, %v; want %q (error %v)", got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
@@ -17,30 +17,30 @@ package controller
 
 import (
 	"context"
-	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
 )
 
-func (r *SessionReconciler) applyDefaults(sess *codespacev1.Session) {
+func (r *SessionReconciler) applyDefaults(sess *codespacev1.Session, opts *Options) {
 	if sess.Spec.Profile.IDE == "" {
 		sess.Spec.Profile.IDE = "jupyterlab"
 	}
 	if sess.Spec.Profile.Image == "" {
-		switch sess.Spec.Profile.IDE {
-		case "jupyterlab":
-			sess.Spec.Profile.Image = "jupyter/minimal-notebook:latest"
-			if len(sess.Spec.Profile.Cmd) == 0 {
-				sess.Spec.Profile.Cmd = []string{"start-notebook.sh", "--NotebookApp.token="}
-			}
-		case "vscode":
-			sess.Spec.Profile.Image = "codercom/code-server:latest"
-			if len(sess.Spec.Profile.Cmd) == 0 {
+		sess.Spec.Profile.Image = opts.DefaultImages[sess.Spec.Profile.IDE]
+		if len(sess.Spec.Profile.Cmd) == 0 {
+			switch sess.Spec.Profile.IDE {
+			case "jupyterlab":
+				sess.Spec.Profile.Cmd = []string{"start-notebook.sh", "--NotebookApp.token=",
+					"--ServerApp.base_url=$(" + codespacev1.BaseURLEnv + ")"}
+			case "vscode":
 				sess.Spec.Profile.Cmd = []string{"--bind-addr", "0.0.0.0:8080", "--auth", "none"}
 			}
 		}
@@ -53,8 +53,22 @@ func (r *SessionReconciler) applyDefaults(sess *codespacev1.Session) {
 }
 
 func (r *SessionReconciler) handleDelete(ctx context.Context, sess *codespacev1.Session) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(sess, sessionFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	if err := r.releaseRetainedClaims(ctx, sess); err != nil {
+		r.event(sess, corev1.EventTypeWarning, reasonPruneFailed, "Failed to retain volumes: %v", err)
+		return ctrl.Result{}, err
+	}
+	r.event(sess, corev1.EventTypeNormal, reasonDeleting, "Session is being deleted; child resources will be garbage collected")
+	sessionReadyTimer.forget(sess.UID)
 	controllerutil.RemoveFinalizer(sess, sessionFinalizer)
-	return ctrl.Result{}, r.Update(ctx, sess)
+	if err := r.Update(ctx, sess); err != nil {
+		r.event(sess, corev1.EventTypeWarning, reasonFinalizerRemovalFailed, "Failed to remove finalizer: %v", err)
+		return ctrl.Result{}, err
+	}
+	r.event(sess, corev1.EventTypeNormal, reasonFinalizerRemoved, "Finalizer removed")
+	return ctrl.Result{}, nil
 }
 
 func (r *SessionReconciler) ensureFinalizer(ctx context.Context, sess *codespacev1.Session) error {
@@ -63,76 +77,89 @@ func (r *SessionReconciler) ensureFinalizer(ctx context.Context, sess *codespace
 	}
 	return nil
 }
-func (r *SessionReconciler) updateStatus(ctx context.Context, sess *codespacev1.Session, dep *appsv1.Deployment) error {
-	url := ""
-	if sess.Spec.Networking != nil && sess.Spec.Networking.Host != "" {
-		url = "https://" + sess.Spec.Networking.Host
+func (r *SessionReconciler) updateStatus(ctx context.Context, sess *codespacev1.Session, opts *Options, dep *appsv1.Deployment) error {
+	name, _ := r.desiredNamesLabels(sess, opts)
+	sess.Status.URL = sessionURL(sess, name)
+
+	phase := codespacev1.SessionPhasePending
+	switch {
+	case sess.Spec.Replicas != nil && *sess.Spec.Replicas == 0:
+		phase = codespacev1.SessionPhaseSuspended
+	case dep != nil && dep.Status.ReadyReplicas > 0:
+		phase = codespacev1.SessionPhaseReady
+	}
+	sessionReadyTimer.observe(sess, sess.Status.Phase, phase)
+	r.recordPhaseChange(sess, sess.Status.Phase, phase)
+	sess.Status.Phase = phase
+	sess.Status.Reason = ""
+	if dep != nil {
+		sess.Status.Rollout = rolloutStatus(dep)
 	}
-	sess.Status.URL = url
-	if dep != nil && dep.Status.ReadyReplicas > 0 {
-		sess.Status.Phase = "Ready"
+	// Keep the last known containers if the pods cannot be listed
+	if containers, err := r.containerStatuses(ctx, sess); err == nil {
+		sess.Status.Containers = containers
 	} else {
-		sess.Status.Phase = "Pending"
+		log.FromContext(ctx).Error(err, "failed to list session pods")
 	}
 	return r.Status().Update(ctx, sess)
 }
-func (r *SessionReconciler) desiredNamesLabels(sess *codespacev1.Session) (string, map[string]string) {
-	name := namePrefix + sess.Name
+func (r *SessionReconciler) desiredNamesLabels(sess *codespacev1.Session, opts *Options) (string, map[string]string) {
+	name := opts.NamePrefix + sess.Name
 	return name, map[string]string{"app": name}
 }
 
-func (r *SessionReconciler) determinePort(sess *codespacev1.Session) int32 {
-	if sess.Spec.Profile.IDE == "vscode" {
-		return 8080
+// podLabels extends the selector labels with labels that are safe to add to object
+// metadata and the pod template, but not to selectors (the Deployment selector is immutable).
+func (r *SessionReconciler) podLabels(sess *codespacev1.Session, labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
 	}
-	return 8888
+	out[codespacev1.SessionNameLabel] = sess.Name
+	return out
 }
 
-func (r *SessionReconciler) buildVolumesAndMounts(sess *codespacev1.Session, name string) ([]corev1.Volume, []corev1.VolumeMount) {
-	var vols []corev1.Volume
-	var mounts []corev1.VolumeMount
-	if sess.Spec.Home != nil {
-		vols = append(vols, corev1.Volume{
-			Name: "home", VolumeSource: corev1.VolumeSource{
-				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name + "-home"},
-			},
-		})
-		mounts = append(mounts, corev1.VolumeMount{Name: "home", MountPath: sess.Spec.Home.MountPath})
-	}
-	if sess.Spec.Scratch != nil {
-		vols = append(vols, corev1.Volume{
-			Name: "scratch", VolumeSource: corev1.VolumeSource{
-				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name + "-scratch"},
-			},
-		})
-		mounts = append(mounts, corev1.VolumeMount{Name: "scratch", MountPath: sess.Spec.Scratch.MountPath})
-	}
-	return vols, mounts
+// childLabels are set on every child resource so they can be found by session name.
+func (r *SessionReconciler) childLabels(sess *codespacev1.Session) map[string]string {
+	return map[string]string{codespacev1.SessionNameLabel: sess.Name}
 }
 
-func (r *SessionReconciler) buildContainers(sess *codespacev1.Session, port int32, mainC corev1.Container) []corev1.Container {
-	containers := []corev1.Container{mainC}
-	if sess.Spec.Auth.Mode == "oauth2proxy" && sess.Spec.Networking != nil && sess.Spec.Networking.Host != "" {
-		sidecar := corev1.Container{
-			Name:  "oauth2-proxy",
-			Image: "quay.io/oauth2-proxy/oauth2-proxy:v7.6.0",
-			Args: []string{
-				"--provider=oidc",
-				"--oidc-issuer-url=$(OIDC_ISSUER_URL)",
-				"--client-id=$(OIDC_CLIENT_ID)",
-				"--client-secret=$(OIDC_CLIENT_SECRET)",
-				"--upstream=http://127.0.0.1:" + fmt.Sprint(port),
-				"--http-address=0.0.0.0:4180",
-				"--reverse-proxy=true",
-				"--email-domain=*",
-			},
-			Ports: []corev1.ContainerPort{{ContainerPort: 4180}},
+func (r *SessionReconciler) determinePort(sess *codespacev1.Session) int32 {
+	return codespacev1.IDEPort(sess.Spec.Profile.IDE)
+}
+
+// buildVolumesAndMounts renders the pod volumes for home and scratch and how the
+// IDE container uses them: Filesystem volumes are mounted, Block volumes are
+// attached as devices at MountPath.
+func (r *SessionReconciler) buildVolumesAndMounts(sess *codespacev1.Session, name string) (
+	[]*corev1apply.VolumeApplyConfiguration, []*corev1apply.VolumeMountApplyConfiguration, []*corev1apply.VolumeDeviceApplyConfiguration) {
+	var vols []*corev1apply.VolumeApplyConfiguration
+	var mounts []*corev1apply.VolumeMountApplyConfiguration
+	var devices []*corev1apply.VolumeDeviceApplyConfiguration
+	for _, v := range []struct {
+		volume string
+		spec   *codespacev1.PVCSpec
+	}{{"home", sess.Spec.Home}, {"scratch", sess.Spec.Scratch}} {
+		if v.spec == nil {
+			continue
+		}
+		vol := corev1apply.Volume().WithName(v.volume)
+		if v.spec.Ephemeral {
+			vol = vol.WithEphemeral(corev1apply.EphemeralVolumeSource().
+				WithVolumeClaimTemplate(corev1apply.PersistentVolumeClaimTemplate().
+					WithLabels(r.childLabels(sess)).
+					WithSpec(claimSpec(v.spec, resource.MustParse(v.spec.Size), v.spec.AccessModes, v.spec.VolumeMode, v.spec.DataSource))))
+		} else {
+			vol = vol.WithPersistentVolumeClaim(corev1apply.PersistentVolumeClaimVolumeSource().
+				WithClaimName(name + "-" + v.volume))
 		}
-		// Only set issuer env if present to avoid nil deref
-		if sess.Spec.Auth.OIDC != nil {
-			sidecar.Env = append(sidecar.Env, corev1.EnvVar{Name: "OIDC_ISSUER_URL", Value: sess.Spec.Auth.OIDC.IssuerURL})
+		vols = append(vols, vol)
+
+		if v.spec.VolumeMode != nil && *v.spec.VolumeMode == corev1.PersistentVolumeBlock {
+			devices = append(devices, corev1apply.VolumeDevice().WithName(v.volume).WithDevicePath(v.spec.MountPath))
+		} else {
+			mounts = append(mounts, corev1apply.VolumeMount().WithName(v.volume).WithMountPath(v.spec.MountPath))
 		}
-		containers = append(containers, sidecar)
 	}
-	return containers
+	return vols, mounts, devices
 }
@@ -0,0 +1,234 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+const metricsNamespace = "codespace"
+
+var (
+	sessionTimeToReady = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "session_time_to_ready_seconds",
+		Help:      "Time from a Session being created or resumed until its IDE pod is ready.",
+		Buckets:   []float64{5, 10, 20, 30, 45, 60, 90, 120, 180, 300, 600, 1200},
+	}, []string{"ide", "trigger"})
+
+	sessionReconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "session_reconcile_errors_total",
+		Help:      "Reconcile errors by the child resource that failed.",
+	}, []string{"resource"})
+
+	watchedNamespaceReadable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "controller_watched_namespace_readable",
+		Help:      "Whether the controller can read Sessions in a watched namespace (namespace-scoped mode).",
+	}, []string{"namespace"})
+
+	sessionDrift = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "session_drift_total",
+		Help:      "Child resources found changed by another field manager, by kind and action taken.",
+	}, []string{"kind", "action"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(sessionTimeToReady, sessionReconcileErrors, watchedNamespaceReadable, sessionDrift)
+}
+
+// reasonResources maps failure event reasons to the child resource label of
+// codespace_session_reconcile_errors_total.
+var reasonResources = map[string]string{
+	reasonServiceAccountFailed: "serviceaccount",
+	reasonPVCFailed:            "persistentvolumeclaim",
+	reasonDeploymentFailed:     "deployment",
+	reasonServiceFailed:        "service",
+	reasonIngressFailed:        "ingress",
+	reasonStatusUpdateFailed:   "status",
+	reasonSnapshotFailed:       "volumesnapshot",
+	reasonRestoreFailed:        "volumesnapshot",
+	reasonCertificateFailed:    "certificate",
+}
+
+func recordReconcileError(reason string) {
+	res, ok := reasonResources[reason]
+	if !ok {
+		res = "other"
+	}
+	sessionReconcileErrors.WithLabelValues(res).Inc()
+}
+
+func countDrift(kind, action string) {
+	sessionDrift.WithLabelValues(strings.ToLower(kind), strings.ToLower(action)).Inc()
+}
+
+// readyTimer remembers when a Session started waiting for its pod so the
+// time-to-ready histogram can be observed on the transition to Ready. It is
+// in-memory only: transitions in flight across a controller restart are not observed.
+type readyTimer struct {
+	mu    sync.Mutex
+	start map[types.UID]readyStart
+	now   func() time.Time
+}
+
+type readyStart struct {
+	at      time.Time
+	trigger string
+}
+
+var sessionReadyTimer = &readyTimer{start: map[types.UID]readyStart{}, now: time.Now}
+
+// observe tracks a phase transition of sess.
+func (t *readyTimer) observe(sess *codespacev1.Session, from, to string) {
+	if from == to {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case from == "":
+		t.start[sess.UID] = readyStart{at: sess.CreationTimestamp.Time, trigger: "create"}
+	case from == codespacev1.SessionPhaseSuspended:
+		t.start[sess.UID] = readyStart{at: t.now(), trigger: "resume"}
+	}
+
+	switch to {
+	case codespacev1.SessionPhaseReady:
+		if s, ok := t.start[sess.UID]; ok && !s.at.IsZero() {
+			sessionTimeToReady.WithLabelValues(sess.Spec.Profile.IDE, s.trigger).Observe(t.now().Sub(s.at).Seconds())
+		}
+		delete(t.start, sess.UID)
+	case codespacev1.SessionPhaseSuspended:
+		delete(t.start, sess.UID)
+	}
+}
+
+func (t *readyTimer) forget(uid types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.start, uid)
+}
+
+var (
+	sessionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "sessions"),
+		"Number of Sessions by namespace, phase and IDE.",
+		[]string{"namespace", "phase", "ide"}, nil,
+	)
+	sessionsStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "sessions_state"),
+		"Number of Sessions that are running (replicas > 0) or suspended (replicas = 0).",
+		[]string{"state"}, nil,
+	)
+	sessionInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "session_info"),
+		"Information about a Session; always 1.",
+		[]string{"namespace", "session", "phase", "ide"}, nil,
+	)
+	sessionStorageDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "session_storage_requested_bytes"),
+		"Storage requested by a Session's PersistentVolumeClaims.",
+		[]string{"namespace", "session", "volume"}, nil,
+	)
+)
+
+// sessionCollector computes fleet gauges from the manager cache at scrape time,
+// so the values never drift from the Sessions actually in the cluster.
+type sessionCollector struct {
+	reader client.Reader
+}
+
+func (c *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sessionsDesc
+	ch <- sessionsStateDesc
+	ch <- sessionInfoDesc
+	ch <- sessionStorageDesc
+}
+
+func (c *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var list codespacev1.SessionList
+	if err := c.reader.List(ctx, &list); err != nil {
+		ch <- prometheus.NewInvalidMetric(sessionsDesc, err)
+		return
+	}
+
+	type key struct{ ns, phase, ide string }
+	counts := map[key]float64{}
+	running, suspended := 0.0, 0.0
+
+	for i := range list.Items {
+		s := &list.Items[i]
+		phase := s.Status.Phase
+		if phase == "" {
+			phase = codespacev1.SessionPhasePending
+		}
+		ide := s.Spec.Profile.IDE
+		counts[key{s.Namespace, phase, ide}]++
+
+		if s.Spec.Replicas != nil && *s.Spec.Replicas == 0 {
+			suspended++
+		} else {
+			running++
+		}
+
+		ch <- prometheus.MustNewConstMetric(sessionInfoDesc, prometheus.GaugeValue, 1, s.Namespace, s.Name, phase, ide)
+		for volume, spec := range map[string]*codespacev1.PVCSpec{"home": s.Spec.Home, "scratch": s.Spec.Scratch} {
+			if spec == nil {
+				continue
+			}
+			q, err := resource.ParseQuantity(spec.Size)
+			if err != nil {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(sessionStorageDesc, prometheus.GaugeValue, q.AsApproximateFloat64(), s.Namespace, s.Name, volume)
+		}
+	}
+
+	for k, n := range counts {
+		ch <- prometheus.MustNewConstMetric(sessionsDesc, prometheus.GaugeValue, n, k.ns, k.phase, k.ide)
+	}
+	ch <- prometheus.MustNewConstMetric(sessionsStateDesc, prometheus.GaugeValue, running, "running")
+	ch <- prometheus.MustNewConstMetric(sessionsStateDesc, prometheus.GaugeValue, suspended, "suspended")
+}
+
+// registerSessionCollector adds the cache-backed collector to the controller-runtime
+// registry. Registering twice (e.g. several managers in one test binary) is not an error.
+func registerSessionCollector(reader client.Reader) error {
+	err := metrics.Registry.Register(&sessionCollector{reader: reader})
+	if are := (prometheus.AlreadyRegisteredError{}); errors.As(err, &are) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// timeToReadyText is the exposition of a single time-to-ready observation.
+func timeToReadyText(trigger string, seconds float64) string {
+	var b strings.Builder
+	b.WriteString("# HELP codespace_session_time_to_ready_seconds Time from a Session being created or resumed until its IDE pod is ready.\n")
+	b.WriteString("# TYPE codespace_session_time_to_ready_seconds histogram\n")
+	labels := fmt.Sprintf(`ide="jupyterlab",trigger=%q`, trigger)
+	for _, le := range []float64{5, 10, 20, 30, 45, 60, 90, 120, 180, 300, 600, 1200} {
+		n := 0
+		if seconds <= le {
+			n = 1
+		}
+		fmt.Fprintf(&b, "codespace_session_time_to_ready_seconds_bucket{%s,le=\"%g\"} %d\n", labels, le, n)
+	}
+	fmt.Fprintf(&b, "codespace_session_time_to_ready_seconds_bucket{%s,le=\"+Inf\"} 1\n", labels)
+	fmt.Fprintf(&b, "codespace_session_time_to_ready_seconds_sum{%s} %g\n", labels, seconds)
+	fmt.Fprintf(&b, "codespace_session_time_to_ready_seconds_count{%s} 1\n", labels)
+	return b.String()
+}
+
+func TestReadyTimerObserve(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		after    time.Duration
+		from, to string
+	}
+	const (
+		pending   = codespacev1.SessionPhasePending
+		ready     = codespacev1.SessionPhaseReady
+		suspended = codespacev1.SessionPhaseSuspended
+		failed    = codespacev1.SessionPhaseError
+	)
+	for _, tc := range []struct {
+		name  string
+		steps []step
+		want  string
+	}{
+		{"create", []step{{0, "", pending}, {15 * time.Second, pending, ready}}, timeToReadyText("create", 15)},
+		{"create through an error", []step{{time.Second, "", pending}, {10 * time.Second, pending, failed}, {25 * time.Second, failed, ready}},
+			timeToReadyText("create", 25)},
+		{"resume", []step{{0, ready, suspended}, {100 * time.Second, suspended, pending}, {130 * time.Second, pending, ready}},
+			timeToReadyText("resume", 30)},
+		{"suspended before ready", []step{{0, "", pending}, {50 * time.Second, pending, suspended},
+			{100 * time.Second, suspended, pending}, {110 * time.Second, pending, ready}}, timeToReadyText("resume", 10)},
+		{"suspended and not resumed", []step{{0, "", pending}, {50 * time.Second, pending, suspended}}, ""},
+		{"no start seen", []step{{0, pending, ready}}, ""},
+		{"unchanged phase", []step{{0, ready, ready}}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sessionTimeToReady.Reset()
+			now := created
+			timer := &readyTimer{start: map[types.UID]readyStart{}, now: func() time.Time { return now }}
+			sess := &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{UID: "uid", CreationTimestamp: metav1.NewTime(created)}}
+			sess.Spec.Profile.IDE = "jupyterlab"
+
+			for _, s := range tc.steps {
+				now = created.Add(s.after)
+				timer.observe(sess, s.from, s.to)
+			}
+			if err := testutil.CollectAndCompare(sessionTimeToReady, strings.NewReader(tc.want)); err != nil {
+				t.Fatal(err)
+			}
+			if len(timer.start) != 0 {
+				t.Fatalf("start = %v, want it cleared", timer.start)
+			}
+		})
+	}
+}
+
+func TestSessionCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := codespacev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	session := func(ns, name, phase string, replicas *int32, home, scratch *codespacev1.PVCSpec) client.Object {
+		s := &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+		s.Spec.Profile.IDE = "jupyterlab"
+		s.Spec.Replicas = replicas
+		s.Spec.Home = home
+		s.Spec.Scratch = scratch
+		s.Status.Phase = phase
+		return s
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		session("team", "a", codespacev1.SessionPhaseReady, nil, &codespacev1.PVCSpec{Size: "10Gi"}, nil),
+		session("team", "b", codespacev1.SessionPhaseReady, ptr.To[int32](1), nil,
+			&codespacev1.PVCSpec{Size: "500Mi", Ephemeral: true}),
+		session("team", "c", codespacev1.SessionPhaseSuspended, ptr.To[int32](0),
+			&codespacev1.PVCSpec{Size: "1Gi"}, &codespacev1.PVCSpec{Size: "2Gi"}),
+		session("other", "d", "", nil, &codespacev1.PVCSpec{Size: "not a size"}, nil),
+	).Build()
+
+	const want = `
+# HELP codespace_sessions Number of Sessions by namespace, phase and IDE.
+# TYPE codespace_sessions gauge
+codespace_sessions{ide="jupyterlab",namespace="other",phase="Pending"} 1
+codespace_sessions{ide="jupyterlab",namespace="team",phase="Ready"} 2
+codespace_sessions{ide="jupyterlab",namespace="team",phase="Suspended"} 1
+# HELP codespace_sessions_state Number of Sessions that are running (replicas > 0) or suspended (replicas = 0).
+# TYPE codespace_sessions_state gauge
+codespace_sessions_state{state="running"} 3
+codespace_sessions_state{state="suspended"} 1
+# HELP codespace_session_storage_requested_bytes Storage requested by a Session's PersistentVolumeClaims.
+# TYPE codespace_session_storage_requested_bytes gauge
+codespace_session_storage_requested_bytes{namespace="team",session="a",volume="home"} 1.073741824e+10
+codespace_session_storage_requested_bytes{namespace="team",session="b",volume="scratch"} 5.24288e+08
+codespace_session_storage_requested_bytes{namespace="team",session="c",volume="home"} 1.073741824e+09
+codespace_session_storage_requested_bytes{namespace="team",session="c",volume="scratch"} 2.147483648e+09
+`
+	if err := testutil.CollectAndCompare(&sessionCollector{reader: c}, strings.NewReader(want),
+		"codespace_sessions", "codespace_sessions_state", "codespace_session_storage_requested_bytes"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=list
+
+// ResolveWatchNamespaces returns the namespaces the controller is limited to:
+// the configured names plus the namespaces matching selector. nil means the
+// controller watches the whole cluster. Listing namespaces for a selector needs
+// cluster-wide read access to namespaces; a plain list does not.
+func ResolveWatchNamespaces(ctx context.Context, reader client.Reader, names []string, selector string) ([]string, error) {
+	set := map[string]bool{}
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			set[n] = true
+		}
+	}
+	if selector != "" {
+		sel, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid watch_namespace_selector %q: %w", selector, err)
+		}
+		var nsl corev1.NamespaceList
+		if err := reader.List(ctx, &nsl, client.MatchingLabelsSelector{Selector: sel}); err != nil {
+			return nil, fmt.Errorf("list namespaces for %q: %w", selector, err)
+		}
+		for _, ns := range nsl.Items {
+			set[ns.Name] = true
+		}
+		if len(set) == 0 {
+			return nil, fmt.Errorf("no namespace matches watch_namespace_selector %q", selector)
+		}
+	}
+	if len(set) == 0 {
+		return nil, nil
+	}
+	out := make([]string, 0, len(set))
+	for n := range set {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// NamespaceMonitor keeps an eye on the watched namespaces of a namespace-scoped
+// controller. It is a readiness check that fails while a namespace cannot be
+// read (e.g. its RoleBinding was removed), and, when the namespaces come from a
+// selector, it stops the manager once the matching set changes so that the
+// controller restarts with a cache for the new set.
+type NamespaceMonitor struct {
+	// Reader should bypass the cache, so lost access is noticed.
+	Reader     client.Reader
+	Names      []string
+	Selector   string
+	Namespaces []string
+	Interval   time.Duration
+
+	mu         sync.Mutex
+	unreadable map[string]string
+}
+
+// Start implements manager.Runnable.
+func (m *NamespaceMonitor) Start(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		m.check(ctx)
+		if m.Selector != "" {
+			current, err := ResolveWatchNamespaces(ctx, m.Reader, m.Names, m.Selector)
+			if err != nil {
+				log.FromContext(ctx).Error(err, "Failed to re-resolve watched namespaces")
+			} else if !slices.Equal(current, m.Namespaces) {
+				return fmt.Errorf("watched namespaces changed from %v to %v; restarting", m.Namespaces, current)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable; every replica
+// reports its own readiness.
+func (m *NamespaceMonitor) NeedLeaderElection() bool { return false }
+
+func (m *NamespaceMonitor) check(ctx context.Context) {
+	unreadable := map[string]string{}
+	for _, ns := range m.Namespaces {
+		var sl codespacev1.SessionList
+		if err := m.Reader.List(ctx, &sl, client.InNamespace(ns), client.Limit(1)); err != nil {
+			unreadable[ns] = err.Error()
+			watchedNamespaceReadable.WithLabelValues(ns).Set(0)
+			continue
+		}
+		watchedNamespaceReadable.WithLabelValues(ns).Set(1)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ns, msg := range unreadable {
+		if _, known := m.unreadable[ns]; !known {
+			log.FromContext(ctx).Info("Watched namespace is not readable", "namespace", ns, "err", msg)
+		}
+	}
+	m.unreadable = unreadable
+}
+
+// Check implements healthz.Checker.
+func (m *NamespaceMonitor) Check(_ *http.Request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.unreadable) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(m.unreadable))
+	for ns := range m.unreadable {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("cannot read watched namespaces: %s", strings.Join(names, ", "))
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+
+// Certificates are handled as unstructured objects, like VolumeSnapshots, so the
+// operator does not depend on cert-manager being installed.
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// defaultNetworking fills in the host of a Session without one from the
+// controller's host template, and the TLS secret when cert-manager issues it.
+// It only changes the in-memory Session, after the finalizer update, so the
+// defaults follow the controller configuration instead of being stored in the
+// spec. It reports whether the TLS secret is to be requested from cert-manager.
+func (r *SessionReconciler) defaultNetworking(sess *codespacev1.Session, opts *Options, name string) (bool, error) {
+	net := sess.Spec.Networking
+	if (net == nil || net.Host == "") && opts.IngressHostTemplate != "" {
+		host := strings.ToLower(strings.NewReplacer(
+			"{name}", sess.Name,
+			"{namespace}", sess.Namespace,
+			"{domain}", opts.IngressDomain,
+		).Replace(opts.IngressHostTemplate))
+		if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+			return false, fmt.Errorf("host %q from the host template: %s", host, strings.Join(errs, "; "))
+		}
+		if net == nil {
+			net = &codespacev1.NetSpec{}
+		} else {
+			net = net.DeepCopy()
+		}
+		net.Host = host
+		sess.Spec.Networking = net
+	}
+	if net == nil || net.Host == "" || net.TLSSecretName != "" || opts.CertManagerIssuer == "" {
+		return false, nil
+	}
+	net = net.DeepCopy()
+	net.TLSSecretName = name + "-tls"
+	sess.Spec.Networking = net
+	return true, nil
+}
+
+// publicHosts returns the hosts the Session is published on.
+func publicHosts(sess *codespacev1.Session) []string {
+	if sess.Spec.Networking == nil || sess.Spec.Networking.Host == "" {
+		return nil
+	}
+	hosts := []string{sess.Spec.Networking.Host}
+	for _, p := range sess.Spec.Ports {
+		if p.Subdomain != "" {
+			hosts = append(hosts, portHost(sess, p))
+		}
+	}
+	return hosts
+}
+
+// reconcileCertificate requests the TLS secret of the Session from cert-manager,
+// or deletes the Certificate once it is no longer wanted.
+func (r *SessionReconciler) reconcileCertificate(ctx context.Context, sess *codespacev1.Session, opts *Options, name string, want bool) error {
+	if !want {
+		cert := &unstructured.Unstructured{}
+		cert.SetGroupVersionKind(certificateGVK)
+		err := r.Get(ctx, client.ObjectKey{Namespace: sess.Namespace, Name: name}, cert)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !metav1.IsControlledBy(cert, sess) {
+			return nil
+		}
+		return r.pruneChild(ctx, sess, "Certificate", cert)
+	}
+
+	kind := opts.CertManagerIssuerKind
+	if kind == "" {
+		kind = "ClusterIssuer"
+	}
+	cert := map[string]any{
+		"apiVersion": certificateGVK.GroupVersion().String(),
+		"kind":       certificateGVK.Kind,
+		"metadata": map[string]any{
+			"name":      name,
+			"namespace": sess.Namespace,
+			"labels":    r.childLabels(sess),
+			"ownerReferences": []any{map[string]any{
+				"apiVersion":         codespacev1.GroupVersion.String(),
+				"kind":               "Session",
+				"name":               sess.Name,
+				"uid":                string(sess.UID),
+				"controller":         true,
+				"blockOwnerDeletion": true,
+			}},
+		},
+		"spec": map[string]any{
+			"secretName": sess.Spec.Networking.TLSSecretName,
+			"dnsNames":   publicHosts(sess),
+			"issuerRef": map[string]any{
+				"group": certificateGVK.Group,
+				"kind":  kind,
+				"name":  opts.CertManagerIssuer,
+			},
+		},
+	}
+	data, err := json.Marshal(cert)
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(certificateGVK)
+	obj.SetNamespace(sess.Namespace)
+	obj.SetName(name)
+	if err := r.apply(ctx, sess, opts, "Certificate", obj, data); err != nil {
+		if meta.IsNoMatchError(err) {
+			return fmt.Errorf("cert-manager is not installed: %w", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// sessionURL is where the Session is reached: its public host, or else the
+// in-cluster address of its Service.
+func sessionURL(sess *codespacev1.Session, name string) string {
+	if sess.Spec.Networking != nil && sess.Spec.Networking.Host != "" {
+		return "https://" + sess.Spec.Networking.Host
+	}
+	return fmt.Sprintf("http://%s.%s.svc", name, sess.Namespace)
+}
+
+// baseURL is the path the IDE is served under, see codespacev1.BaseURLEnv.
+func baseURL(sess *codespacev1.Session) string {
+	if sess.Spec.Networking != nil && sess.Spec.Networking.Host != "" {
+		return "/"
+	}
+	return codespacev1.ProxyPath(sess.Namespace, sess.Name)
+}
+
+// serviceAnnotations tells the API server's proxy whether the IDE serves under
+// the proxy path itself, which is the case when its arguments use the base URL.
+func serviceAnnotations(sess *codespacev1.Session) map[string]string {
+	base := baseURL(sess)
+	if base == "/" {
+		return nil
+	}
+	for _, arg := range sess.Spec.Profile.Cmd {
+		if strings.Contains(arg, "$("+codespacev1.BaseURLEnv+")") {
+			return map[string]string{codespacev1.BasePathAnnotation: base}
+		}
+	}
+	return nil
+}
+
+// ingressAnnotations merges the controller's default annotations with the
+// Session's own, which take precedence.
+func (r *SessionReconciler) ingressAnnotations(sess *codespacev1.Session, opts *Options) map[string]string {
+	out := maps.Clone(opts.IngressAnnotations)
+	if out == nil {
+		out = map[string]string{}
+	}
+	maps.Copy(out, sess.Spec.Networking.Annotations)
+	return out
+}
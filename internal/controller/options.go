@@ -0,0 +1,151 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// Options are the settings a SessionReconciler works with. They are built from
+// ControllerConfig (see OptionsFromConfig) and can be replaced at runtime with
+// SetOptions, except NamePrefix and FieldOwner: changing those would orphan the
+// existing child resources, so they are fixed for the life of the reconciler.
+// Zero fields take the value from DefaultOptions.
+type Options struct {
+	// NamePrefix is prepended to the Session name for child resource names.
+	NamePrefix string
+	// FieldOwner is the server-side apply field manager.
+	FieldOwner string
+	// DefaultImages is the image per IDE for Sessions that do not set one.
+	DefaultImages map[string]string
+	// OAuth2ProxyImage is the image of the oauth2-proxy sidecar.
+	OAuth2ProxyImage string
+	// IngressClassName is set on Session Ingresses; empty uses the cluster default.
+	IngressClassName string
+	// IngressHostTemplate names the host of Sessions without networking.host,
+	// with the placeholders {name}, {namespace} and {domain}. Empty leaves such
+	// Sessions unpublished.
+	IngressHostTemplate string
+	// IngressDomain is the {domain} of IngressHostTemplate.
+	IngressDomain string
+	// IngressAnnotations are set on every Session Ingress; the Session's own
+	// annotations take precedence.
+	IngressAnnotations map[string]string
+	// CertManagerIssuer, if set, issues the TLS secret of Sessions without one
+	// through a cert-manager Certificate.
+	CertManagerIssuer string
+	// CertManagerIssuerKind is Issuer or ClusterIssuer (the default).
+	CertManagerIssuerKind string
+	// RequeueInterval is how often a Session is reconciled without changes.
+	RequeueInterval time.Duration
+	// ImagePullSecrets are attached to every Session's ServiceAccount.
+	ImagePullSecrets []string
+	// DriftPolicy is DriftPolicyRevert or DriftPolicyReport.
+	DriftPolicy string
+}
+
+// defaultIngressHostTemplate is used when only an ingress domain is configured.
+const defaultIngressHostTemplate = "{name}-{namespace}.{domain}"
+
+// DefaultOptions returns the built-in defaults.
+func DefaultOptions() Options {
+	return Options{
+		NamePrefix: "cs-",
+		FieldOwner: "codespace-operator",
+		DefaultImages: map[string]string{
+			"jupyterlab": "jupyter/minimal-notebook:latest",
+			"vscode":     "codercom/code-server:latest",
+		},
+		OAuth2ProxyImage: "quay.io/oauth2-proxy/oauth2-proxy:v7.6.0",
+		RequeueInterval:  2 * time.Minute,
+		DriftPolicy:      DriftPolicyRevert,
+	}
+}
+
+// OptionsFromConfig maps the controller configuration onto Options.
+func OptionsFromConfig(cfg *ControllerConfig) Options {
+	return Options{
+		NamePrefix:       cfg.SessionNamePrefix,
+		FieldOwner:       cfg.FieldOwner,
+		DefaultImages:    cfg.DefaultImages,
+		OAuth2ProxyImage: cfg.OAuth2ProxyImage,
+		IngressClassName: cfg.IngressClassName,
+
+		IngressHostTemplate:   cfg.IngressHostTemplate,
+		IngressDomain:         cfg.IngressDomain,
+		IngressAnnotations:    cfg.IngressAnnotations,
+		CertManagerIssuer:     cfg.CertManagerIssuer,
+		CertManagerIssuerKind: cfg.CertManagerIssuerKind,
+
+		RequeueInterval:  cfg.RequeueInterval,
+		ImagePullSecrets: cfg.ImagePullSecrets,
+		DriftPolicy:      cfg.DriftPolicy,
+	}
+}
+
+// withDefaults returns a copy of o with zero fields set from DefaultOptions.
+func (o Options) withDefaults() *Options {
+	d := DefaultOptions()
+	if o.NamePrefix == "" {
+		o.NamePrefix = d.NamePrefix
+	}
+	if o.FieldOwner == "" {
+		o.FieldOwner = d.FieldOwner
+	}
+	// Configured images override the built-in ones per IDE
+	images := d.DefaultImages
+	maps.Copy(images, o.DefaultImages)
+	o.DefaultImages = images
+	if o.OAuth2ProxyImage == "" {
+		o.OAuth2ProxyImage = d.OAuth2ProxyImage
+	}
+	if o.IngressHostTemplate == "" && o.IngressDomain != "" {
+		o.IngressHostTemplate = defaultIngressHostTemplate
+	}
+	o.IngressAnnotations = maps.Clone(o.IngressAnnotations)
+	if o.CertManagerIssuerKind == "" {
+		o.CertManagerIssuerKind = "ClusterIssuer"
+	}
+	if o.RequeueInterval <= 0 {
+		o.RequeueInterval = d.RequeueInterval
+	}
+	o.ImagePullSecrets = slices.Clone(o.ImagePullSecrets)
+	if o.DriftPolicy != DriftPolicyReport {
+		o.DriftPolicy = d.DriftPolicy
+	}
+	return &o
+}
+
+// opts returns the options in effect. Reconcile reads them once and passes them
+// down, so a concurrent SetOptions does not apply halfway through a reconcile.
+func (r *SessionReconciler) opts() *Options {
+	if o := r.current.Load(); o != nil {
+		return o
+	}
+	r.current.CompareAndSwap(nil, r.Options.withDefaults())
+	return r.current.Load()
+}
+
+// SetOptions replaces the options in effect, e.g. after the controller-config
+// file changed. NamePrefix and FieldOwner keep their current values.
+func (r *SessionReconciler) SetOptions(o Options) {
+	cur := r.opts()
+	next := o.withDefaults()
+	next.NamePrefix, next.FieldOwner = cur.NamePrefix, cur.FieldOwner
+	r.current.Store(next)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// The oauth2-proxy sidecar in front of the IDE listens on authProxyPort. It also
+// serves the ports published under a path, as extra upstreams. Ports published
+// on a subdomain get a proxy of their own, listening from authProxyPort+1 on.
+const authProxyPort = 4180
+
+// authProxyEnabled reports whether the Session is served through oauth2-proxy.
+func authProxyEnabled(sess *codespacev1.Session) bool {
+	return sess.Spec.Auth.Mode == "oauth2proxy" && sess.Spec.Networking != nil && sess.Spec.Networking.Host != ""
+}
+
+// portTargets maps each extra port to the container port its Service port
+// targets: the port itself, or the auth proxy serving it.
+func portTargets(sess *codespacev1.Session) map[string]int32 {
+	auth := authProxyEnabled(sess)
+	next := int32(authProxyPort + 1)
+	targets := map[string]int32{}
+	for _, p := range sess.Spec.Ports {
+		switch {
+		case auth && p.Path != "":
+			targets[p.Name] = authProxyPort
+		case auth && p.Subdomain != "":
+			targets[p.Name] = next
+			next++
+		default:
+			targets[p.Name] = p.ContainerPort
+		}
+	}
+	return targets
+}
+
+// portHost is the host a port is published on; empty if it is not published.
+func portHost(sess *codespacev1.Session, p codespacev1.PortSpec) string {
+	if sess.Spec.Networking == nil || sess.Spec.Networking.Host == "" {
+		return ""
+	}
+	switch {
+	case p.Subdomain != "":
+		return p.Subdomain + "." + sess.Spec.Networking.Host
+	case p.Path != "":
+		return sess.Spec.Networking.Host
+	}
+	return ""
+}
+
+// authProxyContainers returns the oauth2-proxy sidecars: one in front of the IDE
+// and the path-published ports, and one per subdomain-published port.
+func (r *SessionReconciler) authProxyContainers(sess *codespacev1.Session, opts *Options, idePort int32) []*corev1apply.ContainerApplyConfiguration {
+	upstreams := []string{fmt.Sprintf("http://127.0.0.1:%d", idePort)}
+	for _, p := range sess.Spec.Ports {
+		if p.Path != "" {
+			upstreams = append(upstreams, fmt.Sprintf("http://127.0.0.1:%d%s/", p.ContainerPort, p.Path))
+		}
+	}
+	proxies := []*corev1apply.ContainerApplyConfiguration{
+		r.authProxyContainer(sess, opts, "oauth2-proxy", authProxyPort, upstreams...),
+	}
+	targets := portTargets(sess)
+	for _, p := range sess.Spec.Ports {
+		if p.Subdomain != "" {
+			proxies = append(proxies, r.authProxyContainer(sess, opts, "oauth2-proxy-"+p.Name, targets[p.Name],
+				fmt.Sprintf("http://127.0.0.1:%d", p.ContainerPort)))
+		}
+	}
+	return proxies
+}
+
+func (r *SessionReconciler) authProxyContainer(sess *codespacev1.Session, opts *Options, name string, listen int32,
+	upstreams ...string) *corev1apply.ContainerApplyConfiguration {
+	args := []string{
+		"--provider=oidc",
+		"--oidc-issuer-url=$(OIDC_ISSUER_URL)",
+		"--client-id=$(OIDC_CLIENT_ID)",
+		"--client-secret=$(OIDC_CLIENT_SECRET)",
+	}
+	for _, u := range upstreams {
+		args = append(args, "--upstream="+u)
+	}
+	args = append(args,
+		fmt.Sprintf("--http-address=0.0.0.0:%d", listen),
+		"--reverse-proxy=true",
+		"--email-domain=*",
+	)
+	sidecar := corev1apply.Container().
+		WithName(name).
+		WithImage(opts.OAuth2ProxyImage).
+		WithArgs(args...).
+		WithPorts(corev1apply.ContainerPort().WithContainerPort(listen))
+	if sess.Spec.Auth.OIDC != nil {
+		sidecar = sidecar.
+			WithEnv(
+				corev1apply.EnvVar().WithName("OIDC_ISSUER_URL").WithValue(sess.Spec.Auth.OIDC.IssuerURL),
+				corev1apply.EnvVar().WithName("OIDC_CLIENT_ID").WithValueFrom(
+					corev1apply.EnvVarSource().WithSecretKeyRef(
+						corev1apply.SecretKeySelector().WithName(sess.Spec.Auth.OIDC.ClientIDSecret).WithKey("clientID"),
+					)),
+				corev1apply.EnvVar().WithName("OIDC_CLIENT_SECRET").WithValueFrom(
+					corev1apply.EnvVarSource().WithSecretKeyRef(
+						corev1apply.SecretKeySelector().WithName(sess.Spec.Auth.OIDC.ClientSecretRef).WithKey("clientSecret"),
+					)),
+			)
+	}
+	return sidecar
+}
+
+// servicePorts returns the Service ports: "http" for the IDE and one per extra port.
+func servicePorts(sess *codespacev1.Session, ideTarget int32) []*corev1apply.ServicePortApplyConfiguration {
+	ports := []*corev1apply.ServicePortApplyConfiguration{
+		corev1apply.ServicePort().
+			WithName("http").
+			WithPort(80).
+			WithTargetPort(intstr.FromInt32(ideTarget)),
+	}
+	targets := portTargets(sess)
+	for _, p := range sess.Spec.Ports {
+		ports = append(ports, corev1apply.ServicePort().
+			WithName(p.Name).
+			WithPort(p.ContainerPort).
+			WithTargetPort(intstr.FromInt32(targets[p.Name])))
+	}
+	return ports
+}
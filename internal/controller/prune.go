@@ -0,0 +1,136 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// pruneChildren removes child resources the Session spec no longer asks for:
+// the Ingress once networking is removed, and volume claims once their volume is
+// removed or made ephemeral. Claims with the Retain policy are released instead
+// of deleted, and the home claim is left alone unless its policy is Delete.
+// Only objects controlled by the Session are touched.
+func (r *SessionReconciler) pruneChildren(ctx context.Context, sess *codespacev1.Session, name string) error {
+	wantClaims := map[string]bool{}
+	for _, v := range []struct {
+		volume string
+		spec   *codespacev1.PVCSpec
+	}{{"home", sess.Spec.Home}, {"scratch", sess.Spec.Scratch}} {
+		if v.spec != nil && !v.spec.Ephemeral {
+			wantClaims[name+"-"+v.volume] = true
+		}
+	}
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(sess.Namespace), client.MatchingLabels(r.childLabels(sess))); err != nil {
+		return fmt.Errorf("list claims: %w", err)
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if wantClaims[pvc.Name] || !metav1.IsControlledBy(pvc, sess) || !pvc.DeletionTimestamp.IsZero() {
+			continue
+		}
+		switch policy := pvc.Annotations[codespacev1.RetentionPolicyAnnotation]; {
+		case policy == codespacev1.VolumeRetentionRetain:
+			if err := r.releaseClaim(ctx, sess, pvc); err != nil {
+				return err
+			}
+			continue
+		case policy == "" && pvc.Name == name+"-home":
+			// Kept, still owned: it comes back with the volume, or goes with the Session
+			continue
+		}
+		if err := r.pruneChild(ctx, sess, "PersistentVolumeClaim", pvc); err != nil {
+			return err
+		}
+	}
+
+	if sess.Spec.Networking != nil && sess.Spec.Networking.Host != "" {
+		return nil
+	}
+	var ingresses netv1.IngressList
+	if err := r.List(ctx, &ingresses, client.InNamespace(sess.Namespace), client.MatchingLabels(r.childLabels(sess))); err != nil {
+		return fmt.Errorf("list ingresses: %w", err)
+	}
+	for i := range ingresses.Items {
+		ing := &ingresses.Items[i]
+		if !metav1.IsControlledBy(ing, sess) || !ing.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.pruneChild(ctx, sess, "Ingress", ing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneChild deletes a child resource that is no longer desired.
+func (r *SessionReconciler) pruneChild(ctx context.Context, sess *codespacev1.Session, kind string, obj client.Object) error {
+	uid := obj.GetUID()
+	if err := r.Delete(ctx, obj, client.Preconditions{UID: &uid}); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("delete %s %s: %w", kind, obj.GetName(), err)
+	}
+	forgetDrift(sess, kind, obj.GetName())
+	r.event(sess, corev1.EventTypeNormal, reasonPruned, "Deleted %s %s, it is no longer in the spec", kind, obj.GetName())
+	return nil
+}
+
+// releaseClaim keeps a retained claim by dropping the Session's owner reference,
+// so it is not garbage collected with the Session.
+func (r *SessionReconciler) releaseClaim(ctx context.Context, sess *codespacev1.Session, pvc *corev1.PersistentVolumeClaim) error {
+	patch := client.MergeFrom(pvc.DeepCopy())
+	refs := pvc.OwnerReferences[:0]
+	for _, ref := range pvc.OwnerReferences {
+		if ref.UID != sess.UID {
+			refs = append(refs, ref)
+		}
+	}
+	pvc.OwnerReferences = refs
+	if err := r.Patch(ctx, pvc, patch); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("release claim %s: %w", pvc.Name, err)
+	}
+	forgetDrift(sess, "PersistentVolumeClaim", pvc.Name)
+	r.event(sess, corev1.EventTypeNormal, reasonVolumeRetained,
+		"Retained claim %s; it is no longer owned by the session", pvc.Name)
+	return nil
+}
+
+// releaseRetainedClaims releases the claims with the Retain policy before the
+// Session is deleted.
+func (r *SessionReconciler) releaseRetainedClaims(ctx context.Context, sess *codespacev1.Session) error {
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(sess.Namespace), client.MatchingLabels(r.childLabels(sess))); err != nil {
+		return fmt.Errorf("list claims: %w", err)
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if metav1.IsControlledBy(pvc, sess) &&
+			pvc.Annotations[codespacev1.RetentionPolicyAnnotation] == codespacev1.VolumeRetentionRetain {
+			if err := r.releaseClaim(ctx, sess, pvc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
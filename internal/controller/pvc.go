@@ -17,40 +17,103 @@ package controller
 
 import (
 	"context"
-
 	"encoding/json"
+	"fmt"
+	"time"
 
-	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
-	metav1apply "k8s.io/client-go/applyconfigurations/meta/v1"
-
-	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1apply "k8s.io/client-go/applyconfigurations/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
 )
 
-func (r *SessionReconciler) reconcilePVC(ctx context.Context, sess *codespacev1.Session, name, suffix string, spec *codespacev1.PVCSpec) error {
-	if spec == nil {
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=list;delete;deletecollection
+
+// offlineResizeGrace is how long a claim may sit in FileSystemResizePending before
+// RestartOnResize restarts the pod; drivers that expand online finish well within it.
+const offlineResizeGrace = time.Minute
+
+func (r *SessionReconciler) reconcilePVC(ctx context.Context, sess *codespacev1.Session, opts *Options, name, suffix string, spec *codespacev1.PVCSpec) error {
+	// Ephemeral volumes are part of the pod template, there is no claim to manage
+	if spec == nil || spec.Ephemeral {
+		setVolumeStatus(sess, codespacev1.VolumeStatus{Name: suffix}, true)
 		return nil
 	}
-	pvcName := name + "-" + suffix
+	return r.applyPVC(ctx, sess, opts, name+"-"+suffix, suffix, spec, nil, nil)
+}
 
-	reqs := map[corev1.ResourceName]resource.Quantity{
-		corev1.ResourceStorage: resource.MustParse(spec.Size),
+// applyPVC server-side applies a claim for spec and records its state in the
+// Session's volume status. A non-nil dataSource and the annotations are only
+// passed by restores, which recreate the claim from a snapshot.
+//
+// The claim is never shrunk, and only grown when its StorageClass allows volume
+// expansion; otherwise the current size is kept and the status says why.
+func (r *SessionReconciler) applyPVC(ctx context.Context, sess *codespacev1.Session, opts *Options, pvcName, volume string, spec *codespacev1.PVCSpec,
+	dataSource *corev1.TypedLocalObjectReference, annotations map[string]string) error {
+	var existing corev1.PersistentVolumeClaim
+	found := true
+	if err := r.Get(ctx, client.ObjectKey{Namespace: sess.Namespace, Name: pvcName}, &existing); apierrors.IsNotFound(err) {
+		found = false
+	} else if err != nil {
+		return err
 	}
 
-	pvcSpec := corev1apply.PersistentVolumeClaimSpec().
-		WithAccessModes(corev1.ReadWriteOnce).
-		WithResources(corev1apply.VolumeResourceRequirements().WithRequests(reqs))
+	size, err := resource.ParseQuantity(spec.Size)
+	if err != nil {
+		return fmt.Errorf("invalid size %q: %w", spec.Size, err)
+	}
+	st := codespacev1.VolumeStatus{Name: volume, ClaimName: pvcName, Requested: spec.Size}
+	if found {
+		current := existing.Spec.Resources.Requests[corev1.ResourceStorage]
+		switch size.Cmp(current) {
+		case -1:
+			st.Resize = codespacev1.VolumeResizeShrinkRejected
+			st.Message = fmt.Sprintf("volumes cannot shrink; keeping %s", current.String())
+			size = current
+		case 1:
+			ok, reason, err := r.canExpand(ctx, &existing)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				st.Resize = codespacev1.VolumeResizeNotSupported
+				st.Message = fmt.Sprintf("%s; keeping %s", reason, current.String())
+				size = current
+			}
+		}
+	}
 
-	if spec.StorageClassName != "" {
-		pvcSpec = pvcSpec.WithStorageClassName(spec.StorageClassName)
+	// Access modes, volume mode and dataSource are immutable: use the spec when
+	// creating the claim, afterwards keep applying what the claim was created with
+	// so the fields are never changed or dropped.
+	modes, volumeMode := spec.AccessModes, spec.VolumeMode
+	if found {
+		modes, volumeMode = existing.Spec.AccessModes, existing.Spec.VolumeMode
+	}
+	if dataSource == nil {
+		dataSource = spec.DataSource
+		if found {
+			dataSource = existing.Spec.DataSource
+		}
 	}
+	pvcSpec := claimSpec(spec, size, modes, volumeMode, dataSource)
 
 	cfg := corev1apply.PersistentVolumeClaim(pvcName, sess.Namespace).
+		WithLabels(r.childLabels(sess)).
 		WithSpec(pvcSpec)
+	if len(annotations) > 0 {
+		cfg.WithAnnotations(annotations)
+	}
+	if spec.RetentionPolicy != "" {
+		cfg.WithAnnotations(map[string]string{codespacev1.RetentionPolicyAnnotation: spec.RetentionPolicy})
+	}
 
 	owner := metav1apply.OwnerReference().
 		WithAPIVersion(codespacev1.GroupVersion.String()).
@@ -65,9 +128,176 @@ func (r *SessionReconciler) reconcilePVC(ctx context.Context, sess *codespacev1.
 	if err != nil {
 		return err
 	}
-	return r.Patch(ctx,
-		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: sess.Namespace}},
-		client.RawPatch(types.ApplyPatchType, data),
-		client.FieldOwner(ssaFieldOwner),
-	)
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: sess.Namespace}}
+	if err := r.apply(ctx, sess, opts, "PersistentVolumeClaim", pvc, data); err != nil {
+		return err
+	}
+
+	if st.Resize == "" {
+		resizeState(pvc, &st)
+	}
+	if q, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		st.Capacity = q.String()
+	}
+	if st.Resize == codespacev1.VolumeResizeFileSystemResizePending && spec.RestartOnResize {
+		if err := r.restartForResize(ctx, sess, pvc); err != nil {
+			return err
+		}
+	}
+	r.recordResize(sess, st)
+	setVolumeStatus(sess, st, false)
+	return nil
+}
+
+// claimSpec renders the spec of a Session volume claim, for both claims and
+// ephemeral volume claim templates. Access modes default to ReadWriteOnce.
+func claimSpec(spec *codespacev1.PVCSpec, size resource.Quantity, modes []corev1.PersistentVolumeAccessMode,
+	volumeMode *corev1.PersistentVolumeMode, dataSource *corev1.TypedLocalObjectReference) *corev1apply.PersistentVolumeClaimSpecApplyConfiguration {
+	if len(modes) == 0 {
+		modes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	pvcSpec := corev1apply.PersistentVolumeClaimSpec().
+		WithAccessModes(modes...).
+		WithResources(corev1apply.VolumeResourceRequirements().
+			WithRequests(corev1.ResourceList{corev1.ResourceStorage: size}))
+
+	if spec.StorageClassName != "" {
+		pvcSpec = pvcSpec.WithStorageClassName(spec.StorageClassName)
+	}
+	if volumeMode != nil {
+		pvcSpec = pvcSpec.WithVolumeMode(*volumeMode)
+	}
+	if dataSource != nil {
+		ds := corev1apply.TypedLocalObjectReference().
+			WithKind(dataSource.Kind).
+			WithName(dataSource.Name)
+		if dataSource.APIGroup != nil {
+			ds = ds.WithAPIGroup(*dataSource.APIGroup)
+		}
+		pvcSpec = pvcSpec.WithDataSource(ds)
+	}
+	return pvcSpec
+}
+
+// canExpand reports whether the claim's StorageClass allows volume expansion,
+// and if not, why.
+func (r *SessionReconciler) canExpand(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (bool, string, error) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, "claim has no StorageClass", nil
+	}
+	var sc storagev1.StorageClass
+	if err := r.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, &sc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, fmt.Sprintf("StorageClass %s not found", *pvc.Spec.StorageClassName), nil
+		}
+		// Namespace-scoped installations cannot read StorageClasses; let the API
+		// server decide whether the claim can grow
+		if apierrors.IsForbidden(err) {
+			return true, "", nil
+		}
+		return false, "", err
+	}
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		return false, fmt.Sprintf("StorageClass %s does not allow volume expansion", sc.Name), nil
+	}
+	return true, "", nil
+}
+
+// resizeState derives the resize progress of a claim from its conditions and
+// allocated resource statuses.
+func resizeState(pvc *corev1.PersistentVolumeClaim, st *codespacev1.VolumeStatus) {
+	switch pvc.Status.AllocatedResourceStatuses[corev1.ResourceStorage] {
+	case corev1.PersistentVolumeClaimControllerResizeInfeasible, corev1.PersistentVolumeClaimNodeResizeInfeasible:
+		st.Resize = codespacev1.VolumeResizeInfeasible
+	}
+	for _, c := range pvc.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		state := ""
+		switch c.Type {
+		case corev1.PersistentVolumeClaimFileSystemResizePending:
+			state = codespacev1.VolumeResizeFileSystemResizePending
+		case corev1.PersistentVolumeClaimResizing:
+			state = codespacev1.VolumeResizeInProgress
+		case corev1.PersistentVolumeClaimControllerResizeError, corev1.PersistentVolumeClaimNodeResizeError:
+			st.Message = c.Message
+			continue
+		default:
+			continue
+		}
+		if st.Resize == "" {
+			st.Resize = state
+		}
+		if st.Message == "" {
+			st.Message = c.Message
+		}
+	}
+	if st.Resize != "" {
+		return
+	}
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok && capacity.Cmp(requested) < 0 {
+		st.Resize = codespacev1.VolumeResizePending
+	}
+}
+
+// restartForResize deletes the Session's pods so a filesystem resize that the
+// driver can only do offline is finished when the volume is mounted again. It
+// waits offlineResizeGrace first and restarts at most once per requested size.
+func (r *SessionReconciler) restartForResize(ctx context.Context, sess *codespacev1.Session, pvc *corev1.PersistentVolumeClaim) error {
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if pvc.Annotations[codespacev1.ResizeRestartAnnotation] == requested.String() {
+		return nil
+	}
+	for _, c := range pvc.Status.Conditions {
+		if c.Type == corev1.PersistentVolumeClaimFileSystemResizePending && time.Since(c.LastTransitionTime.Time) < offlineResizeGrace {
+			return nil
+		}
+	}
+
+	if err := r.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace(sess.Namespace),
+		client.MatchingLabels{codespacev1.SessionNameLabel: sess.Name}); err != nil {
+		return fmt.Errorf("restart pods for resize: %w", err)
+	}
+	patch := client.MergeFrom(pvc.DeepCopy())
+	if pvc.Annotations == nil {
+		pvc.Annotations = map[string]string{}
+	}
+	pvc.Annotations[codespacev1.ResizeRestartAnnotation] = requested.String()
+	if err := r.Patch(ctx, pvc, patch); err != nil {
+		return err
+	}
+	r.event(sess, corev1.EventTypeNormal, reasonVolumeResizeRestart,
+		"Restarted the session to finish the filesystem resize of %s to %s", pvc.Name, requested.String())
+	return nil
+}
+
+// recordResize emits an event when a volume's resize state changes.
+func (r *SessionReconciler) recordResize(sess *codespacev1.Session, st codespacev1.VolumeStatus) {
+	for _, prev := range sess.Status.Volumes {
+		if prev.Name == st.Name && prev.Resize == st.Resize {
+			return
+		}
+	}
+	switch st.Resize {
+	case codespacev1.VolumeResizeShrinkRejected, codespacev1.VolumeResizeNotSupported, codespacev1.VolumeResizeInfeasible:
+		r.event(sess, corev1.EventTypeWarning, reasonVolumeResizeFailed, "Cannot resize %s to %s: %s", st.ClaimName, st.Requested, st.Message)
+	case codespacev1.VolumeResizePending, codespacev1.VolumeResizeInProgress, codespacev1.VolumeResizeFileSystemResizePending:
+		r.event(sess, corev1.EventTypeNormal, reasonVolumeResizing, "Resizing %s to %s (%s)", st.ClaimName, st.Requested, st.Resize)
+	}
+}
+
+// setVolumeStatus replaces (or removes) the status entry of a volume.
+func setVolumeStatus(sess *codespacev1.Session, st codespacev1.VolumeStatus, remove bool) {
+	vols := sess.Status.Volumes[:0]
+	for _, v := range sess.Status.Volumes {
+		if v.Name != st.Name {
+			vols = append(vols, v)
+		}
+	}
+	if !remove {
+		vols = append(vols, st)
+	}
+	sess.Status.Volumes = vols
 }
@@ -0,0 +1,107 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+func TestResizeState(t *testing.T) {
+	condition := func(typ corev1.PersistentVolumeClaimConditionType, status corev1.ConditionStatus, msg string) corev1.PersistentVolumeClaimCondition {
+		return corev1.PersistentVolumeClaimCondition{Type: typ, Status: status, Message: msg}
+	}
+	for _, tc := range []struct {
+		name        string
+		requested   string
+		capacity    string
+		allocated   corev1.ClaimResourceStatus
+		conditions  []corev1.PersistentVolumeClaimCondition
+		wantResize  string
+		wantMessage string
+	}{
+		{name: "resized", requested: "2Gi", capacity: "2Gi"},
+		{name: "not bound yet", requested: "2Gi"},
+		{name: "pending", requested: "2Gi", capacity: "1Gi", wantResize: codespacev1.VolumeResizePending},
+		{
+			name: "resizing", requested: "2Gi", capacity: "1Gi",
+			conditions: []corev1.PersistentVolumeClaimCondition{condition(corev1.PersistentVolumeClaimResizing, corev1.ConditionTrue, "")},
+			wantResize: codespacev1.VolumeResizeInProgress,
+		},
+		{
+			name: "filesystem resize pending", requested: "2Gi", capacity: "1Gi",
+			conditions: []corev1.PersistentVolumeClaimCondition{
+				condition(corev1.PersistentVolumeClaimFileSystemResizePending, corev1.ConditionTrue, "waiting for the pod to restart"),
+			},
+			wantResize: codespacev1.VolumeResizeFileSystemResizePending, wantMessage: "waiting for the pod to restart",
+		},
+		{
+			name: "false conditions are ignored", requested: "2Gi", capacity: "1Gi",
+			conditions: []corev1.PersistentVolumeClaimCondition{condition(corev1.PersistentVolumeClaimResizing, corev1.ConditionFalse, "done")},
+			wantResize: codespacev1.VolumeResizePending,
+		},
+		{
+			name: "resize error keeps the pending state", requested: "2Gi", capacity: "1Gi",
+			conditions: []corev1.PersistentVolumeClaimCondition{
+				condition(corev1.PersistentVolumeClaimControllerResizeError, corev1.ConditionTrue, "quota exceeded"),
+			},
+			wantResize: codespacev1.VolumeResizePending, wantMessage: "quota exceeded",
+		},
+		{
+			name: "error message wins over the state's", requested: "2Gi", capacity: "1Gi",
+			conditions: []corev1.PersistentVolumeClaimCondition{
+				condition(corev1.PersistentVolumeClaimNodeResizeError, corev1.ConditionTrue, "resize2fs failed"),
+				condition(corev1.PersistentVolumeClaimResizing, corev1.ConditionTrue, "resizing"),
+			},
+			wantResize: codespacev1.VolumeResizeInProgress, wantMessage: "resize2fs failed",
+		},
+		{
+			name: "infeasible", requested: "2Gi", capacity: "1Gi",
+			allocated:  corev1.PersistentVolumeClaimControllerResizeInfeasible,
+			conditions: []corev1.PersistentVolumeClaimCondition{condition(corev1.PersistentVolumeClaimResizing, corev1.ConditionTrue, "too big")},
+			wantResize: codespacev1.VolumeResizeInfeasible, wantMessage: "too big",
+		},
+		{
+			name: "node infeasible", requested: "2Gi", capacity: "1Gi",
+			allocated:  corev1.PersistentVolumeClaimNodeResizeInfeasible,
+			wantResize: codespacev1.VolumeResizeInfeasible,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pvc := &corev1.PersistentVolumeClaim{
+				Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(tc.requested)},
+				}},
+				Status: corev1.PersistentVolumeClaimStatus{Conditions: tc.conditions},
+			}
+			if tc.capacity != "" {
+				pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(tc.capacity)}
+			}
+			if tc.allocated != "" {
+				pvc.Status.AllocatedResourceStatuses = map[corev1.ResourceName]corev1.ClaimResourceStatus{corev1.ResourceStorage: tc.allocated}
+			}
+			var st codespacev1.VolumeStatus
+			resizeState(pvc, &st)
+			if st.Resize != tc.wantResize || st.Message != tc.wantMessage {
+				t.Fatalf("got (%q, %q), want (%q, %q)", st.Resize, st.Message, tc.wantResize, tc.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	appsv1apply "k8s.io/client-go/applyconfigurations/apps/v1"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// deploymentStrategy is Recreate when the Session has a claim a second pod may
+// not be able to attach: a rolling update would leave the new pod waiting in
+// ContainerCreating for the old one to let go of the volume. Ephemeral volumes
+// belong to their pod and do not count.
+func deploymentStrategy(sess *codespacev1.Session) appsv1.DeploymentStrategyType {
+	for _, spec := range []*codespacev1.PVCSpec{sess.Spec.Home, sess.Spec.Scratch} {
+		if spec == nil || spec.Ephemeral {
+			continue
+		}
+		// Claims default to ReadWriteOnce (see claimSpec)
+		if len(spec.AccessModes) == 0 ||
+			slices.Contains(spec.AccessModes, corev1.ReadWriteOnce) ||
+			slices.Contains(spec.AccessModes, corev1.ReadWriteOncePod) {
+			return appsv1.RecreateDeploymentStrategyType
+		}
+	}
+	return appsv1.RollingUpdateDeploymentStrategyType
+}
+
+// strategyConfig renders a Deployment strategy. The rolling update parameters
+// are the API defaults, applied explicitly so the operator owns them and they
+// are removed again when the strategy switches to Recreate.
+func strategyConfig(t appsv1.DeploymentStrategyType) *appsv1apply.DeploymentStrategyApplyConfiguration {
+	s := appsv1apply.DeploymentStrategy().WithType(t)
+	if t == appsv1.RollingUpdateDeploymentStrategyType {
+		s.WithRollingUpdate(appsv1apply.RollingUpdateDeployment().
+			WithMaxSurge(intstr.FromString("25%")).
+			WithMaxUnavailable(intstr.FromString("25%")))
+	}
+	return s
+}
+
+// rolloutStatus reports the progress of the Deployment's latest rollout, the
+// way `kubectl rollout status` judges it.
+func rolloutStatus(dep *appsv1.Deployment) *codespacev1.RolloutStatus {
+	st := &codespacev1.RolloutStatus{
+		Strategy:        string(dep.Spec.Strategy.Type),
+		Phase:           codespacev1.RolloutPhaseProgressing,
+		Replicas:        dep.Status.Replicas,
+		UpdatedReplicas: dep.Status.UpdatedReplicas,
+		ReadyReplicas:   dep.Status.ReadyReplicas,
+		RestartedAt:     dep.Spec.Template.Annotations[codespacev1.RestartAnnotation],
+	}
+	desired := int32(1)
+	if dep.Spec.Replicas != nil {
+		desired = *dep.Spec.Replicas
+	}
+	for _, c := range dep.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			st.Phase, st.Message = codespacev1.RolloutPhaseFailed, c.Message
+			return st
+		}
+	}
+	switch {
+	case dep.Status.ObservedGeneration < dep.Generation:
+		st.Message = "waiting for the rollout to start"
+	case dep.Status.UpdatedReplicas < desired:
+		st.Message = fmt.Sprintf("%d of %d pods updated", dep.Status.UpdatedReplicas, desired)
+	case dep.Status.Replicas > dep.Status.UpdatedReplicas:
+		st.Message = fmt.Sprintf("%d old pods pending termination", dep.Status.Replicas-dep.Status.UpdatedReplicas)
+	case dep.Status.AvailableReplicas < dep.Status.UpdatedReplicas:
+		st.Message = fmt.Sprintf("%d of %d updated pods available", dep.Status.AvailableReplicas, dep.Status.UpdatedReplicas)
+	default:
+		st.Phase = codespacev1.RolloutPhaseComplete
+	}
+	return st
+}
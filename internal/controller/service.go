@@ -21,8 +21,6 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
 	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 	metav1apply "k8s.io/client-go/applyconfigurations/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -30,27 +28,20 @@ import (
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
 )
 
-func (r *SessionReconciler) reconcileService(ctx context.Context, sess *codespacev1.Session, name string, labels map[string]string) (*corev1.Service, error) {
+func (r *SessionReconciler) reconcileService(ctx context.Context, sess *codespacev1.Session, opts *Options, name string, labels map[string]string) (*corev1.Service, error) {
 	ns := sess.Namespace
-	target := 4180
-	if sess.Spec.Auth.Mode != "oauth2proxy" || sess.Spec.Networking == nil || sess.Spec.Networking.Host == "" {
-		if sess.Spec.Profile.IDE == "vscode" {
-			target = 8080
-		} else {
-			target = 8888
-		}
+	target := int32(authProxyPort)
+	if !authProxyEnabled(sess) {
+		target = r.determinePort(sess)
 	}
 
 	svc := corev1apply.Service(name, ns).
+		WithLabels(r.childLabels(sess)).
+		WithAnnotations(serviceAnnotations(sess)).
 		WithSpec(
 			corev1apply.ServiceSpec().
 				WithSelector(labels).
-				WithPorts(
-					corev1apply.ServicePort().
-						WithName("http").
-						WithPort(80).
-						WithTargetPort(intstr.FromInt(target)),
-				),
+				WithPorts(servicePorts(sess, target)...),
 		)
 
 	owner := metav1apply.OwnerReference().
@@ -66,11 +57,8 @@ func (r *SessionReconciler) reconcileService(ctx context.Context, sess *codespac
 	if err != nil {
 		return nil, err
 	}
-	if err := r.Patch(ctx,
-		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}},
-		client.RawPatch(types.ApplyPatchType, data),
-		client.FieldOwner(ssaFieldOwner),
-	); err != nil {
+	if err := r.apply(ctx, sess, opts, "Service",
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}}, data); err != nil {
 		return nil, err
 	}
 
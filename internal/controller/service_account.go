@@ -17,22 +17,30 @@ package controller
 
 import (
 	"context"
-
 	"encoding/json"
+	"fmt"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 	metav1apply "k8s.io/client-go/applyconfigurations/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *SessionReconciler) reconcileServiceAccount(ctx context.Context, sess *codespacev1.Session, name string) error {
+func (r *SessionReconciler) reconcileServiceAccount(ctx context.Context, sess *codespacev1.Session, opts *Options, name string) error {
+	pullSecrets := r.imagePullSecrets(sess, opts)
+
 	// Build apply configuration
-	sa := corev1apply.ServiceAccount(name, sess.Namespace)
+	sa := corev1apply.ServiceAccount(name, sess.Namespace).
+		WithLabels(r.childLabels(sess))
+	for _, s := range pullSecrets {
+		sa.WithImagePullSecrets(corev1apply.LocalObjectReference().WithName(s))
+	}
 	// OwnerRef via apply config
 	owner := metav1apply.OwnerReference().
 		WithAPIVersion(codespacev1.GroupVersion.String()).
@@ -48,9 +56,68 @@ func (r *SessionReconciler) reconcileServiceAccount(ctx context.Context, sess *c
 	if err != nil {
 		return err
 	}
-	return r.Patch(ctx,
-		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: sess.Namespace}},
-		client.RawPatch(types.ApplyPatchType, data),
-		client.FieldOwner(ssaFieldOwner),
-	)
+	if err := r.apply(ctx, sess, opts, "ServiceAccount",
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: sess.Namespace}}, data); err != nil {
+		return err
+	}
+	return r.checkImagePullSecrets(ctx, sess, pullSecrets)
+}
+
+// imagePullSecrets merges the controller-wide pull secrets with the Session's own.
+func (r *SessionReconciler) imagePullSecrets(sess *codespacev1.Session, opts *Options) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	for _, s := range opts.ImagePullSecrets {
+		add(s)
+	}
+	for _, s := range sess.Spec.ImagePullSecrets {
+		add(s.Name)
+	}
+	return out
+}
+
+// checkImagePullSecrets sets the ImagePullSecretsReady condition. A missing
+// Secret does not fail the reconcile: the pod may still pull public images, and
+// the Secret may be created later. Only metadata is read, so Secret data is
+// neither fetched nor cached.
+func (r *SessionReconciler) checkImagePullSecrets(ctx context.Context, sess *codespacev1.Session, names []string) error {
+	if len(names) == 0 {
+		meta.RemoveStatusCondition(&sess.Status.Conditions, codespacev1.ConditionImagePullSecretsReady)
+		return nil
+	}
+
+	var missing []string
+	for _, n := range names {
+		secret := &metav1.PartialObjectMetadata{}
+		secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+		if err := r.Get(ctx, client.ObjectKey{Namespace: sess.Namespace, Name: n}, secret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("get image pull secret %s: %w", n, err)
+			}
+			missing = append(missing, n)
+		}
+	}
+
+	cond := metav1.Condition{
+		Type:               codespacev1.ConditionImagePullSecretsReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Found",
+		Message:            "All image pull secrets exist",
+		ObservedGeneration: sess.Generation,
+	}
+	if len(missing) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "SecretNotFound"
+		cond.Message = "Image pull secrets not found: " + strings.Join(missing, ", ")
+	}
+	if meta.SetStatusCondition(&sess.Status.Conditions, cond) && len(missing) > 0 {
+		r.event(sess, corev1.EventTypeWarning, reasonImagePullSecretMissing, "%s", cond.Message)
+	}
+	return nil
 }
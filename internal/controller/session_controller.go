@@ -18,8 +18,8 @@ package controller
 import (
 	"context"
 	"fmt"
-	"os"
-	"sync"
+	"math"
+	"sync/atomic"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -27,56 +27,59 @@ import (
 	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
 )
 
-var (
-	cfgOnce       sync.Once
-	namePrefix    = "cs-"                // default prefix for child names
-	ssaFieldOwner = "codespace-operator" // default SSA field manager
-)
-
-func loadControllerConfig() {
-	cfgOnce.Do(func() {
-		if v := os.Getenv("SESSION_NAME_PREFIX"); v != "" {
-			namePrefix = v
-		}
-		if v := os.Getenv("FIELD_OWNER"); v != "" {
-			ssaFieldOwner = v
-		}
-	})
-}
-
 // RBAC markers (operator-sdk reads these)
 //+kubebuilder:rbac:groups=codespace.codespace.dev,resources=sessions,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=codespace.codespace.dev,resources=sessions/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups="",resources=secrets;configmaps;services;persistentvolumeclaims;serviceaccounts,verbs=create;update;patch;get;list;watch;delete
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=create;update;patch;get;list;watch;delete
 //+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=create;update;patch;get;list;watch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 const sessionFinalizer = "codespace.dev/session-finalizer"
 
 type SessionReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Options the reconciler starts with; see SetOptions for changing them.
+	Options Options
+	// InstanceID, if set, limits the controller to Sessions labelled with it
+	// (see ResolveInstanceID and CacheOptions).
+	InstanceID string
+	// ServerAppName is the server's app_name, used to find server instances.
+	ServerAppName string
+
+	instances instanceIndex
+	current   atomic.Pointer[Options]
 }
 
 // Reconcile creates/updates child resources for a Session.
 func (r *SessionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	loadControllerConfig()
 	logger := log.FromContext(ctx)
+	opts := r.opts()
 
 	var sess codespacev1.Session
 	if err := r.Get(ctx, req.NamespacedName, &sess); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// Requests from child resources are not filtered by instance
+	if !r.ownsSession(&sess) {
+		return ctrl.Result{}, nil
+	}
 
 	// Apply defaults
-	r.applyDefaults(&sess)
+	r.applyDefaults(&sess, opts)
 
 	// Finalizer / deletion flow
 	if !sess.DeletionTimestamp.IsZero() {
@@ -86,52 +89,117 @@ func (r *SessionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 
-	name, labels := r.desiredNamesLabels(&sess)
+	name, labels := r.desiredNamesLabels(&sess, opts)
+	r.reconcileOwnership(ctx, &sess)
+	issueTLS, err := r.defaultNetworking(&sess, opts, name)
+	if err != nil {
+		return r.failStatus(ctx, &sess, reasonIngressFailed, fmt.Errorf("networking: %w", err))
+	}
 
 	// --- Child resources ---
-	if err := r.reconcileServiceAccount(ctx, &sess, name); err != nil {
-		return r.failStatus(ctx, &sess, fmt.Errorf("serviceaccount: %w", err))
+	if err := r.reconcileServiceAccount(ctx, &sess, opts, name); err != nil {
+		return r.failStatus(ctx, &sess, reasonServiceAccountFailed, fmt.Errorf("serviceaccount: %w", err))
+	}
+
+	// A restore swaps the home volume while the Session is scaled to zero
+	restoring, err := r.reconcileRestore(ctx, &sess, opts, name)
+	if err != nil {
+		return r.failStatus(ctx, &sess, reasonRestoreFailed, err)
+	}
+	if restoring {
+		zero := int32(0)
+		sess.Spec.Replicas = &zero
+	} else if err := r.reconcilePVC(ctx, &sess, opts, name, "home", sess.Spec.Home); err != nil {
+		return r.failStatus(ctx, &sess, reasonPVCFailed, fmt.Errorf("pvc-home: %w", err))
 	}
-	if err := r.reconcilePVC(ctx, &sess, name, "home", sess.Spec.Home); err != nil {
-		return r.failStatus(ctx, &sess, fmt.Errorf("pvc-home: %w", err))
+	if err := r.reconcilePVC(ctx, &sess, opts, name, "scratch", sess.Spec.Scratch); err != nil {
+		return r.failStatus(ctx, &sess, reasonPVCFailed, fmt.Errorf("pvc-scratch: %w", err))
 	}
-	if err := r.reconcilePVC(ctx, &sess, name, "scratch", sess.Spec.Scratch); err != nil {
-		return r.failStatus(ctx, &sess, fmt.Errorf("pvc-scratch: %w", err))
+	requeueAfter := opts.RequeueInterval
+	if restoring {
+		requeueAfter = restorePollInterval
+	} else {
+		requeueAfter = min(requeueAfter, r.reconcileSnapshotSchedules(ctx, &sess, name))
 	}
 
-	dep, err := r.reconcileDeployment(ctx, &sess, name, labels)
+	dep, err := r.reconcileDeployment(ctx, &sess, opts, name, labels)
 	if err != nil {
-		return r.failStatus(ctx, &sess, fmt.Errorf("deployment: %w", err))
+		return r.failStatus(ctx, &sess, reasonDeploymentFailed, fmt.Errorf("deployment: %w", err))
 	}
 
-	svc, err := r.reconcileService(ctx, &sess, name, labels)
+	svc, err := r.reconcileService(ctx, &sess, opts, name, labels)
 	if err != nil {
-		return r.failStatus(ctx, &sess, fmt.Errorf("service: %w", err))
+		return r.failStatus(ctx, &sess, reasonServiceFailed, fmt.Errorf("service: %w", err))
+	}
+
+	if err := r.reconcileIngress(ctx, &sess, opts, name, svc.Name); err != nil {
+		return r.failStatus(ctx, &sess, reasonIngressFailed, fmt.Errorf("ingress: %w", err))
+	}
+	if err := r.reconcileCertificate(ctx, &sess, opts, name, issueTLS); err != nil {
+		r.event(&sess, corev1.EventTypeWarning, reasonCertificateFailed, "Certificate: %v", err)
+		recordReconcileError(reasonCertificateFailed)
 	}
 
-	if err := r.reconcileIngress(ctx, &sess, name, svc.Name); err != nil {
-		return r.failStatus(ctx, &sess, fmt.Errorf("ingress: %w", err))
+	// Children removed from the spec; retried on the next reconcile
+	if err := r.pruneChildren(ctx, &sess, name); err != nil {
+		r.event(&sess, corev1.EventTypeWarning, reasonPruneFailed, "Failed to prune child resources: %v", err)
+		recordReconcileError(reasonPruneFailed)
 	}
 
 	// --- Status ---
-	if err := r.updateStatus(ctx, &sess, dep); err != nil && !errors.IsConflict(err) {
+	if err := r.updateStatus(ctx, &sess, opts, dep); err != nil && !errors.IsConflict(err) {
 		logger.Error(err, "status update failed")
+		r.event(&sess, corev1.EventTypeWarning, reasonStatusUpdateFailed, "Failed to update status: %v", err)
+		recordReconcileError(reasonStatusUpdateFailed)
 	}
 
-	return ctrl.Result{RequeueAfter: 2 * time.Minute}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcileSnapshotSchedules runs the snapshot schedules of the Session's volumes
+// and returns when the next one is due. Snapshot failures are reported as events
+// and do not fail the Session, e.g. on clusters without VolumeSnapshot support.
+func (r *SessionReconciler) reconcileSnapshotSchedules(ctx context.Context, sess *codespacev1.Session, name string) time.Duration {
+	next := time.Duration(math.MaxInt64)
+	for _, v := range []struct {
+		volume string
+		spec   *codespacev1.PVCSpec
+	}{{"home", sess.Spec.Home}, {"scratch", sess.Spec.Scratch}} {
+		if v.spec == nil || v.spec.Snapshots == nil {
+			continue
+		}
+		d, err := r.reconcileSnapshotSchedule(ctx, sess, name+"-"+v.volume, v.volume, v.spec.Snapshots)
+		if err != nil {
+			r.event(sess, corev1.EventTypeWarning, reasonSnapshotFailed, "Scheduled snapshot of %s failed: %v", v.volume, err)
+			recordReconcileError(reasonSnapshotFailed)
+			continue
+		}
+		next = min(next, d)
+	}
+	return next
 }
 
 func (r *SessionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := registerSessionCollector(mgr.GetClient()); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&codespacev1.Session{}).
+		For(&codespacev1.Session{}, builder.WithPredicates(predicate.NewPredicateFuncs(r.ownsSession))).
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
 		Owns(&netv1.Ingress{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.ServiceAccount{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(podSession)).
 		Complete(r)
 }
 
-func (r *SessionReconciler) failStatus(ctx context.Context, sess *codespacev1.Session, err error) (ctrl.Result, error) {
-	sess.Status.Phase = "Error"
+func (r *SessionReconciler) failStatus(ctx context.Context, sess *codespacev1.Session, reason string, err error) (ctrl.Result, error) {
+	r.event(sess, corev1.EventTypeWarning, reason, "%v", err)
+	recordReconcileError(reason)
+	sessionReadyTimer.observe(sess, sess.Status.Phase, codespacev1.SessionPhaseError)
+	r.recordPhaseChange(sess, sess.Status.Phase, codespacev1.SessionPhaseError)
+	sess.Status.Phase = codespacev1.SessionPhaseError
 	sess.Status.Reason = err.Error()
 	if uErr := r.Status().Update(ctx, sess); uErr != nil {
 		return ctrl.Result{}, uErr
@@ -18,12 +18,22 @@ package controller
 
 import (
 	"context"
+	"time"
 
+	"github.com/codespace-operator/common/common/pkg/common"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -84,8 +94,9 @@ var _ = Describe("Session Controller", func() {
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &SessionReconciler{
-				Client: k8sClient,
-				Scheme: scheme.Scheme,
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -94,4 +105,788 @@ var _ = Describe("Session Controller", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 	})
+
+	Context("When sharded by instance ID", func() {
+		It("should leave sessions of other instances alone", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "foreign-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+					Labels:    map[string]string{common.InstanceIDLabel: "i1-other"},
+				},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:     k8sClient,
+				Scheme:     scheme.Scheme,
+				Recorder:   record.NewFakeRecorder(32),
+				InstanceID: "i1-mine",
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("not adding the finalizer")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(BeEmpty())
+		})
+	})
+
+	Context("When a child resource is changed externally", func() {
+		It("should report or revert the drift", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "drift-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			report := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+				Options:  Options{DriftPolicy: DriftPolicyReport},
+			}
+			_, err := report.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("scaling the Deployment as another field manager")
+			dep := &appsv1.Deployment{}
+			depKey := types.NamespacedName{Name: "cs-" + key.Name, Namespace: key.Namespace}
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			dep.Spec.Replicas = ptr.To[int32](3)
+			Expect(k8sClient.Update(ctx, dep, client.FieldOwner("kubectl-edit"))).To(Succeed())
+
+			By("reporting it")
+			_, err = report.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Status.Drift).To(ContainElement(SatisfyAll(
+				HaveField("Kind", "Deployment"),
+				HaveField("Action", codespacev1.DriftReported),
+				HaveField("Fields", ContainElement(".spec.replicas")),
+				HaveField("Managers", ContainElement("kubectl-edit")),
+			)))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, codespacev1.ConditionDrifted)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(Equal(int32(3)))
+
+			By("still applying the other fields")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			resource.Spec.Profile.Image = "jupyter/base-notebook:latest"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = report.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(Equal(int32(3)))
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("jupyter/base-notebook:latest"))
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Status.Drift).To(ContainElement(SatisfyAll(
+				HaveField("Kind", "Deployment"),
+				HaveField("Stale", BeFalse()),
+			)))
+
+			By("reverting it")
+			revert := &SessionReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(32)}
+			_, err = revert.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(Equal(int32(1)))
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Status.Drift).To(ContainElement(SatisfyAll(
+				HaveField("Kind", "Deployment"),
+				HaveField("Action", codespacev1.DriftReverted),
+			)))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, codespacev1.ConditionDrifted)).To(BeFalse())
+		})
+	})
+
+	Context("When children are removed from the spec", func() {
+		It("should prune them and keep retained volumes", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "prune-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile:    codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+					Home:       &codespacev1.PVCSpec{Size: "1Gi", MountPath: "/home/jovyan", RetentionPolicy: codespacev1.VolumeRetentionRetain},
+					Scratch:    &codespacev1.PVCSpec{Size: "1Gi", MountPath: "/scratch"},
+					Networking: &codespacev1.NetSpec{Host: "prune.codespace.test"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("removing the volumes and networking")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			resource.Spec.Home, resource.Spec.Scratch, resource.Spec.Networking = nil, nil, nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			name := "cs-" + key.Name
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: key.Namespace}, &netv1.Ingress{}))).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-scratch", Namespace: key.Namespace}, &corev1.PersistentVolumeClaim{}))).To(BeTrue())
+
+			By("keeping the retained home volume without an owner")
+			home := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-home", Namespace: key.Namespace}, home)).To(Succeed())
+			Expect(home.OwnerReferences).To(BeEmpty())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, home)).To(Succeed()) })
+		})
+
+		It("should only prune the home volume when asked to", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "prune-home-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+					Home:    &codespacev1.PVCSpec{Size: "1Gi", MountPath: "/home/jovyan"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("removing the home volume without a retention policy")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			resource.Spec.Home = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			homeKey := types.NamespacedName{Name: "cs-" + key.Name + "-home", Namespace: key.Namespace}
+			home := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, homeKey, home)).To(Succeed())
+			Expect(home.DeletionTimestamp).To(BeNil())
+			Expect(metav1.IsControlledBy(home, resource)).To(BeTrue())
+		})
+	})
+
+	Context("When extra ports are exposed", func() {
+		It("should publish them on the Service and Ingress", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "ports-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile:    codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+					Networking: &codespacev1.NetSpec{Host: "ports.codespace.test"},
+					Ports: []codespacev1.PortSpec{
+						{Name: "tensorboard", ContainerPort: 6006, Path: "/tensorboard"},
+						{Name: "web", ContainerPort: 3000, Subdomain: "web"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			childKey := types.NamespacedName{Name: "cs-" + key.Name, Namespace: key.Namespace}
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, childKey, svc)).To(Succeed())
+			Expect(svc.Spec.Ports).To(ContainElements(
+				HaveField("Name", "tensorboard"),
+				HaveField("Name", "web"),
+			))
+
+			ing := &netv1.Ingress{}
+			Expect(k8sClient.Get(ctx, childKey, ing)).To(Succeed())
+			Expect(ing.Spec.Rules).To(HaveLen(2))
+			Expect(ing.Spec.Rules[0].HTTP.Paths).To(ContainElement(HaveField("Path", "/tensorboard")))
+			Expect(ing.Spec.Rules[1].Host).To(Equal("web.ports.codespace.test"))
+		})
+
+		It("should reject ports that collide with the IDE or each other", func() {
+			ctx := context.Background()
+			for name, ports := range map[string][]codespacev1.PortSpec{
+				"the Service port": {{Name: "web", ContainerPort: 80}},
+				"the IDE port":     {{Name: "web", ContainerPort: 8888}},
+				"another port":     {{Name: "web", ContainerPort: 3000}, {Name: "api", ContainerPort: 3000}},
+			} {
+				By("rejecting a port that repeats " + name)
+				resource := &codespacev1.Session{
+					ObjectMeta: metav1.ObjectMeta{Name: "ports-invalid", Namespace: "default"},
+					Spec: codespacev1.SessionSpec{
+						Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+						Ports:   ports,
+					},
+				}
+				Expect(k8sClient.Create(ctx, resource)).NotTo(Succeed())
+			}
+
+			By("checking the IDE port of the session's own IDE")
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: "ports-vscode", Namespace: "default"},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "vscode", Image: "codercom/code-server:latest"},
+					Ports:   []codespacev1.PortSpec{{Name: "web", ContainerPort: 8080}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).NotTo(Succeed())
+			resource.Spec.Ports[0].ContainerPort = 8888
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+	})
+
+	Context("When an ingress domain is configured", func() {
+		It("should publish sessions without a host", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "domain-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+				Options: Options{
+					IngressDomain:      "codespace.test",
+					IngressAnnotations: map[string]string{"example.com/team": "data"},
+				},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			ing := &netv1.Ingress{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cs-" + key.Name, Namespace: key.Namespace}, ing)).To(Succeed())
+			Expect(ing.Spec.Rules[0].Host).To(Equal("domain-session-default.codespace.test"))
+			Expect(ing.Annotations).To(HaveKeyWithValue("example.com/team", "data"))
+
+			By("keeping the default out of the spec")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Spec.Networking).To(BeNil())
+			Expect(resource.Status.URL).To(Equal("https://domain-session-default.codespace.test"))
+		})
+	})
+
+	Context("When cert-manager issues the TLS secret", func() {
+		It("should template the host and request a Certificate until TLS is turned off", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "tls-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+					Ports:   []codespacev1.PortSpec{{Name: "web", ContainerPort: 3000, Subdomain: "web"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			opts := Options{
+				IngressHostTemplate: "ide-{name}.{namespace}.{domain}",
+				IngressDomain:       "Codespace.test",
+				CertManagerIssuer:   "letsencrypt",
+			}
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+				Options:  opts,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			name := "cs-" + key.Name
+			childKey := types.NamespacedName{Name: name, Namespace: key.Namespace}
+			const host = "ide-tls-session.default.codespace.test"
+			ing := &netv1.Ingress{}
+			Expect(k8sClient.Get(ctx, childKey, ing)).To(Succeed())
+			Expect(ing.Spec.Rules[0].Host).To(Equal(host))
+			Expect(ing.Spec.TLS).To(ConsistOf(netv1.IngressTLS{Hosts: []string{host, "web." + host}, SecretName: name + "-tls"}))
+
+			By("requesting the TLS secret from cert-manager")
+			cert := &unstructured.Unstructured{}
+			cert.SetGroupVersionKind(certificateGVK)
+			Expect(k8sClient.Get(ctx, childKey, cert)).To(Succeed())
+			Expect(metav1.IsControlledBy(cert, resource)).To(BeTrue())
+			spec, _, err := unstructured.NestedMap(cert.Object, "spec")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spec).To(HaveKeyWithValue("secretName", name+"-tls"))
+			Expect(spec).To(HaveKeyWithValue("dnsNames", []any{host, "web." + host}))
+			Expect(spec).To(HaveKeyWithValue("issuerRef", map[string]any{
+				"group": "cert-manager.io", "kind": "ClusterIssuer", "name": "letsencrypt",
+			}))
+
+			By("keeping the defaults out of the spec")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Spec.Networking).To(BeNil())
+			Expect(resource.Status.URL).To(Equal("https://" + host))
+
+			By("deleting the Certificate when TLS is turned off")
+			opts.CertManagerIssuer = ""
+			controllerReconciler.SetOptions(opts)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, childKey, cert))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, childKey, ing)).To(Succeed())
+			Expect(ing.Spec.Rules[0].Host).To(Equal(host))
+			Expect(ing.Spec.TLS).To(BeEmpty())
+		})
+	})
+
+	Context("When sidecars are configured", func() {
+		It("should run them next to the IDE and report their readiness", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "sidecar-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+					Home:    &codespacev1.PVCSpec{Size: "1Gi", MountPath: "/home/jovyan"},
+					Sidecars: []codespacev1.SidecarSpec{{
+						Name:         "postgres",
+						Image:        "postgres:16",
+						Env:          []corev1.EnvVar{{Name: "POSTGRES_PASSWORD", Value: "dev"}},
+						VolumeMounts: []codespacev1.SidecarVolumeMount{{Name: "home", MountPath: "/var/lib/postgresql/data", SubPath: "pgdata"}},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			By("rejecting reserved container names")
+			invalid := resource.DeepCopy()
+			invalid.ObjectMeta = metav1.ObjectMeta{Name: "sidecar-invalid", Namespace: key.Namespace}
+			invalid.Spec.Sidecars[0].Name = "ide"
+			Expect(k8sClient.Create(ctx, invalid)).NotTo(Succeed())
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cs-" + key.Name, Namespace: key.Namespace}, dep)).To(Succeed())
+			containers := dep.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[1].Name).To(Equal("postgres"))
+			Expect(containers[1].VolumeMounts).To(ConsistOf(HaveField("SubPath", "pgdata")))
+
+			By("aggregating the container statuses of the session pods")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cs-sidecar-session-pod", Namespace: key.Namespace,
+					Labels: map[string]string{codespacev1.SessionNameLabel: key.Name},
+				},
+				Spec: *dep.Spec.Template.Spec.DeepCopy(),
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, pod)).To(Succeed()) })
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{Name: "ide", Ready: true, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "postgres", RestartCount: 2, State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Status.Containers).To(Equal([]codespacev1.ContainerStatus{
+				{Name: "ide", Ready: true},
+				{Name: "postgres", RestartCount: 2, State: "Waiting", Reason: "CrashLoopBackOff"},
+			}))
+		})
+	})
+
+	Context("When the session has ReadWriteOnce volumes", func() {
+		It("should recreate its pods and roll them on restart", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "rollout-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			depKey := types.NamespacedName{Name: "cs-" + key.Name, Namespace: key.Namespace}
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			Expect(dep.Spec.Strategy.Type).To(Equal(appsv1.RollingUpdateDeploymentStrategyType))
+
+			By("switching to Recreate when a home volume is added")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			resource.Spec.Home = &codespacev1.PVCSpec{Size: "1Gi", MountPath: "/home/jovyan"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			Expect(dep.Spec.Strategy.Type).To(Equal(appsv1.RecreateDeploymentStrategyType))
+			Expect(dep.Spec.Strategy.RollingUpdate).To(BeNil())
+
+			By("copying a restart request onto the pod template")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			resource.Annotations = map[string]string{codespacev1.RestartAnnotation: "2025-01-02T03:04:05Z"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations).To(HaveKeyWithValue(codespacev1.RestartAnnotation, "2025-01-02T03:04:05Z"))
+
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Status.Rollout).NotTo(BeNil())
+			Expect(resource.Status.Rollout.Strategy).To(Equal("Recreate"))
+			Expect(resource.Status.Rollout.Phase).To(Equal(codespacev1.RolloutPhaseProgressing))
+			Expect(resource.Status.Rollout.RestartedAt).To(Equal("2025-01-02T03:04:05Z"))
+		})
+	})
+
+	Context("When volumes set their claim options", func() {
+		It("should render ephemeral volumes into the pod without a claim", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "ephemeral-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+					Scratch: &codespacev1.PVCSpec{
+						Size: "2Gi", MountPath: "/scratch", Ephemeral: true, StorageClassName: "fast",
+						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOncePod},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			name := "cs-" + key.Name
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: key.Namespace}, dep)).To(Succeed())
+			var scratch *corev1.Volume
+			for i, v := range dep.Spec.Template.Spec.Volumes {
+				if v.Name == "scratch" {
+					scratch = &dep.Spec.Template.Spec.Volumes[i]
+				}
+			}
+			Expect(scratch).NotTo(BeNil())
+			Expect(scratch.PersistentVolumeClaim).To(BeNil())
+			Expect(scratch.Ephemeral).NotTo(BeNil())
+			Expect(scratch.Ephemeral.VolumeClaimTemplate).NotTo(BeNil())
+			tmpl := scratch.Ephemeral.VolumeClaimTemplate
+			Expect(tmpl.Labels).To(HaveKeyWithValue(codespacev1.SessionNameLabel, key.Name))
+			Expect(tmpl.Spec.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOncePod}))
+			Expect(tmpl.Spec.StorageClassName).To(Equal(ptr.To("fast")))
+			Expect(tmpl.Spec.Resources.Requests.Storage().String()).To(Equal("2Gi"))
+			Expect(dep.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(And(
+				HaveField("Name", "scratch"), HaveField("MountPath", "/scratch"))))
+
+			By("not creating a claim or volume status for it")
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-scratch", Namespace: key.Namespace},
+				&corev1.PersistentVolumeClaim{}))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Status.Volumes).NotTo(ContainElement(HaveField("Name", "scratch")))
+		})
+
+		It("should keep access modes, volume mode and data source of an existing claim", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "immutable-claim-session", Namespace: "default"}
+			block := corev1.PersistentVolumeBlock
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+					Home: &codespacev1.PVCSpec{
+						Size: "1Gi", MountPath: "/dev/home",
+						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+						VolumeMode:  &block,
+						DataSource:  &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: "template-home"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			claimKey := types.NamespacedName{Name: "cs-" + key.Name + "-home", Namespace: key.Namespace}
+			claim := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, claimKey, claim)).To(Succeed())
+			Expect(claim.Spec.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}))
+			Expect(claim.Spec.VolumeMode).To(Equal(&block))
+			Expect(claim.Spec.DataSource).NotTo(BeNil())
+			Expect(claim.Spec.DataSource.Name).To(Equal("template-home"))
+
+			By("changing the immutable fields in the spec")
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			filesystem := corev1.PersistentVolumeFilesystem
+			resource.Spec.Home.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+			resource.Spec.Home.VolumeMode = &filesystem
+			resource.Spec.Home.DataSource = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, claimKey, claim)).To(Succeed())
+			Expect(claim.Spec.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}))
+			Expect(claim.Spec.VolumeMode).To(Equal(&block))
+			Expect(claim.Spec.DataSource).NotTo(BeNil())
+			Expect(claim.Spec.DataSource.Name).To(Equal("template-home"))
+		})
+	})
+
+	Context("When image pull secrets are configured", func() {
+		It("should attach them to the ServiceAccount and report missing ones", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "pull-secret-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile:          codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "registry.example.com/notebook:latest"},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "team-registry"}, {Name: "global-registry"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			global := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "global-registry", Namespace: key.Namespace},
+				Type:       corev1.SecretTypeDockerConfigJson,
+				Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+			}
+			Expect(k8sClient.Create(ctx, global)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, global)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+				Options:  Options{ImagePullSecrets: []string{"global-registry"}},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			saKey := types.NamespacedName{Name: "cs-" + key.Name, Namespace: key.Namespace}
+			sa := &corev1.ServiceAccount{}
+			Expect(k8sClient.Get(ctx, saKey, sa)).To(Succeed())
+			Expect(sa.ImagePullSecrets).To(Equal([]corev1.LocalObjectReference{{Name: "global-registry"}, {Name: "team-registry"}}))
+
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, codespacev1.ConditionImagePullSecretsReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("SecretNotFound"))
+			Expect(cond.Message).To(ContainSubstring("team-registry"))
+			Expect(cond.Message).NotTo(ContainSubstring("global-registry"))
+
+			By("becoming ready once the missing secret is created")
+			team := global.DeepCopy()
+			team.ObjectMeta = metav1.ObjectMeta{Name: "team-registry", Namespace: key.Namespace}
+			Expect(k8sClient.Create(ctx, team)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, team)).To(Succeed()) })
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, codespacev1.ConditionImagePullSecretsReady)).To(BeTrue())
+
+			By("removing the condition and the secrets when none are set")
+			resource.Spec.ImagePullSecrets = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			controllerReconciler.SetOptions(Options{})
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, saKey, sa)).To(Succeed())
+			Expect(sa.ImagePullSecrets).To(BeEmpty())
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, codespacev1.ConditionImagePullSecretsReady)).To(BeNil())
+		})
+	})
+
+	Context("When a restore is requested", func() {
+		It("should scale down, swap the home volume and finish", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "restore-session", Namespace: "default"}
+			resource := &codespacev1.Session{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: codespacev1.SessionSpec{
+					Profile: codespacev1.ProfileSpec{IDE: "jupyterlab", Image: "jupyter/minimal-notebook:latest"},
+					Home:    &codespacev1.PVCSpec{Size: "1Gi", MountPath: "/home/jovyan"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, resource)).To(Succeed()) })
+
+			controllerReconciler := &SessionReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
+			reconcileOnce := func() {
+				GinkgoHelper()
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			}
+			reconcileOnce()
+
+			name := "cs-" + key.Name
+			homeKey := types.NamespacedName{Name: name + "-home", Namespace: key.Namespace}
+			old := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, homeKey, old)).To(Succeed())
+
+			By("requesting a restore from a ready snapshot while the pod runs")
+			snap := codespacev1.NewVolumeSnapshot(key.Namespace, "restore-snap", homeKey.Name, "", nil)
+			snap.Object["status"] = map[string]any{"readyToUse": true}
+			Expect(k8sClient.Create(ctx, snap)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, snap)).To(Succeed()) })
+			dep := &appsv1.Deployment{}
+			depKey := types.NamespacedName{Name: name, Namespace: key.Namespace}
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			dep.Status.Replicas = 1
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			resource.Annotations = map[string]string{
+				codespacev1.RestoreSnapshotAnnotation: snap.GetName(),
+				codespacev1.RestoreRequestAnnotation:  "req-1",
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			By("scaling down first")
+			reconcileOnce()
+			Expect(resource.Status.Restore).NotTo(BeNil())
+			Expect(resource.Status.Restore.Phase).To(Equal(codespacev1.RestorePhaseInProgress))
+			Expect(resource.Status.Restore.Message).To(Equal("suspending the session"))
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(BeZero())
+			Expect(k8sClient.Get(ctx, homeKey, &corev1.PersistentVolumeClaim{})).To(Succeed())
+
+			By("deleting the old home volume once no pod is left")
+			dep.Status.Replicas = 0
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			reconcileOnce()
+			Expect(resource.Status.Restore.Message).To(Equal("deleting the old home volume"))
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, homeKey, &corev1.PersistentVolumeClaim{}))
+			}).Should(BeTrue())
+
+			By("recreating it from the snapshot")
+			reconcileOnce()
+			Expect(resource.Status.Restore.Message).To(Equal("recreating the home volume from the snapshot"))
+			home := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, homeKey, home)).To(Succeed())
+			Expect(home.UID).NotTo(Equal(old.UID))
+			Expect(home.Spec.DataSource).To(Equal(codespacev1.VolumeSnapshotDataSource(snap.GetName())))
+			Expect(home.Annotations).To(HaveKeyWithValue(codespacev1.RestoreRequestAnnotation, "req-1"))
+
+			By("finishing and scaling back up")
+			reconcileOnce()
+			Expect(resource.Status.Restore.Phase).To(Equal(codespacev1.RestorePhaseCompleted))
+			Expect(resource.Status.Restore.CompletedAt).NotTo(BeNil())
+			Expect(resource.Annotations).NotTo(HaveKey(codespacev1.RestoreSnapshotAnnotation))
+			Expect(resource.Annotations).NotTo(HaveKey(codespacev1.RestoreRequestAnnotation))
+			reconcileOnce()
+			Expect(k8sClient.Get(ctx, depKey, dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(Equal(int32(1)))
+		})
+	})
+
+	Context("When namespace-scoped", func() {
+		It("should resolve the watched namespaces", func() {
+			ctx := context.Background()
+			namespaces, err := ResolveWatchNamespaces(ctx, k8sClient, []string{"b", " ", "a"}, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(namespaces).To(Equal([]string{"a", "b"}))
+
+			namespaces, err = ResolveWatchNamespaces(ctx, k8sClient, nil, "kubernetes.io/metadata.name=default")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(namespaces).To(Equal([]string{"default"}))
+
+			namespaces, err = ResolveWatchNamespaces(ctx, k8sClient, nil, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(namespaces).To(BeNil())
+		})
+	})
+
+	Context("When configured with options", func() {
+		It("should keep settings per reconciler", func() {
+			a := &SessionReconciler{Options: Options{NamePrefix: "a-"}}
+			b := &SessionReconciler{Options: Options{NamePrefix: "b-", RequeueInterval: time.Minute}}
+			sess := &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{Name: "demo"}}
+
+			nameA, _ := a.desiredNamesLabels(sess, a.opts())
+			nameB, _ := b.desiredNamesLabels(sess, b.opts())
+			Expect(nameA).To(Equal("a-demo"))
+			Expect(nameB).To(Equal("b-demo"))
+			Expect(a.opts().RequeueInterval).To(Equal(DefaultOptions().RequeueInterval))
+			Expect(b.opts().RequeueInterval).To(Equal(time.Minute))
+
+			By("reloading everything but the name prefix")
+			b.SetOptions(Options{NamePrefix: "c-", IngressClassName: "nginx"})
+			nameB, _ = b.desiredNamesLabels(sess, b.opts())
+			Expect(nameB).To(Equal("b-demo"))
+			Expect(b.opts().IngressClassName).To(Equal("nginx"))
+			Expect(b.opts().DefaultImages).To(HaveKey("vscode"))
+		})
+	})
 })
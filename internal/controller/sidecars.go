@@ -0,0 +1,151 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// sidecarContainers renders the Session's sidecars. Their mounts refer to the
+// Session volumes; Block volumes are attached as devices at MountPath.
+func sidecarContainers(sess *codespacev1.Session) ([]*corev1apply.ContainerApplyConfiguration, error) {
+	out := make([]*corev1apply.ContainerApplyConfiguration, 0, len(sess.Spec.Sidecars))
+	for _, s := range sess.Spec.Sidecars {
+		c := corev1.Container{
+			Name:            s.Name,
+			Image:           s.Image,
+			Command:         s.Command,
+			Args:            s.Args,
+			Env:             s.Env,
+			Ports:           s.Ports,
+			SecurityContext: s.SecurityContext,
+			ReadinessProbe:  s.ReadinessProbe,
+		}
+		if s.Resources != nil {
+			c.Resources = *s.Resources
+		}
+		for _, m := range s.VolumeMounts {
+			if spec := sessionVolume(sess, m.Name); spec != nil && spec.VolumeMode != nil && *spec.VolumeMode == corev1.PersistentVolumeBlock {
+				c.VolumeDevices = append(c.VolumeDevices, corev1.VolumeDevice{Name: m.Name, DevicePath: m.MountPath})
+				continue
+			}
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+				Name: m.Name, MountPath: m.MountPath, SubPath: m.SubPath, ReadOnly: m.ReadOnly,
+			})
+		}
+		// The apply configuration shares the Container's JSON schema
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, fmt.Errorf("sidecar %s: %w", s.Name, err)
+		}
+		ac := &corev1apply.ContainerApplyConfiguration{}
+		if err := json.Unmarshal(data, ac); err != nil {
+			return nil, fmt.Errorf("sidecar %s: %w", s.Name, err)
+		}
+		out = append(out, ac)
+	}
+	return out, nil
+}
+
+// sessionVolume returns the spec of the named Session volume, if any.
+func sessionVolume(sess *codespacev1.Session, volume string) *codespacev1.PVCSpec {
+	switch volume {
+	case "home":
+		return sess.Spec.Home
+	case "scratch":
+		return sess.Spec.Scratch
+	}
+	return nil
+}
+
+// containerStatuses aggregates the container statuses of the Session's pods, in
+// the order of the pod spec. Terminating pods are left out.
+func (r *SessionReconciler) containerStatuses(ctx context.Context, sess *codespacev1.Session) ([]codespacev1.ContainerStatus, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(sess.Namespace),
+		client.MatchingLabels{codespacev1.SessionNameLabel: sess.Name}); err != nil {
+		return nil, err
+	}
+	var out []codespacev1.ContainerStatus
+	index := map[string]int{}
+	for _, pod := range pods.Items {
+		if !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		statuses := make(map[string]corev1.ContainerStatus, len(pod.Status.ContainerStatuses))
+		for _, cs := range pod.Status.ContainerStatuses {
+			statuses[cs.Name] = cs
+		}
+		for _, c := range pod.Spec.Containers {
+			i, seen := index[c.Name]
+			if !seen {
+				i = len(out)
+				index[c.Name] = i
+				out = append(out, codespacev1.ContainerStatus{Name: c.Name, Ready: true})
+			}
+			st := &out[i]
+			cs, ok := statuses[c.Name]
+			if !ok {
+				// Not started yet, e.g. while the pod is scheduled or pulling images
+				cs = corev1.ContainerStatus{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: string(pod.Status.Phase)}}}
+			}
+			st.RestartCount += cs.RestartCount
+			if cs.Ready {
+				continue
+			}
+			if st.Ready {
+				st.Ready = false
+				st.State, st.Reason, st.Message = containerState(cs.State)
+			}
+		}
+	}
+	return out, nil
+}
+
+// containerState describes a container state as (state, reason, message).
+func containerState(s corev1.ContainerState) (string, string, string) {
+	switch {
+	case s.Waiting != nil:
+		return "Waiting", s.Waiting.Reason, s.Waiting.Message
+	case s.Terminated != nil:
+		return "Terminated", s.Terminated.Reason, s.Terminated.Message
+	case s.Running != nil:
+		return "Running", "", ""
+	}
+	return "Waiting", "", ""
+}
+
+// podSession maps a Session pod to its Session, so container readiness changes
+// show up in the status without waiting for the Deployment.
+func podSession(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[codespacev1.SessionNameLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}}}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2025 Dennis Marcus Goh.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;delete
+
+// restorePollInterval is how often an in-progress restore is re-checked.
+const restorePollInterval = 5 * time.Second
+
+const defaultSnapshotRetain = 7
+
+// reconcileSnapshotSchedule takes a scheduled snapshot of pvcName when one is
+// due and prunes scheduled snapshots beyond the retention. It returns the time
+// until the next snapshot is due.
+func (r *SessionReconciler) reconcileSnapshotSchedule(ctx context.Context, sess *codespacev1.Session,
+	pvcName, volume string, policy *codespacev1.SnapshotPolicy) (time.Duration, error) {
+	sched, err := cron.ParseStandard(policy.Schedule)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snapshot schedule %q: %w", policy.Schedule, err)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(codespacev1.VolumeSnapshotGVK.GroupVersion().WithKind("VolumeSnapshotList"))
+	if err := r.List(ctx, list, client.InNamespace(sess.Namespace), client.MatchingLabels{
+		codespacev1.SessionNameLabel:     sess.Name,
+		codespacev1.SnapshotVolumeLabel:  volume,
+		codespacev1.SnapshotTriggerLabel: codespacev1.SnapshotTriggerScheduled,
+	}); err != nil {
+		return 0, fmt.Errorf("list snapshots: %w", err)
+	}
+	snaps := list.Items
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].GetCreationTimestamp().Time.Before(snaps[j].GetCreationTimestamp().Time)
+	})
+
+	last := sess.CreationTimestamp.Time
+	if len(snaps) > 0 {
+		last = snaps[len(snaps)-1].GetCreationTimestamp().Time
+	}
+	now := time.Now()
+	next := sched.Next(last)
+	if !now.Before(next) {
+		snap := r.newVolumeSnapshot(sess, fmt.Sprintf("%s-%s", pvcName, now.UTC().Format("20060102-150405")),
+			pvcName, volume, policy.VolumeSnapshotClassName)
+		if err := r.Create(ctx, snap); err != nil && !apierrors.IsAlreadyExists(err) {
+			return 0, fmt.Errorf("create snapshot: %w", err)
+		}
+		r.event(sess, corev1.EventTypeNormal, reasonSnapshotCreated, "Created scheduled snapshot %s of %s", snap.GetName(), pvcName)
+		snaps = append(snaps, *snap)
+		next = sched.Next(now)
+	}
+
+	retain := int(policy.Retain)
+	if retain < 1 {
+		retain = defaultSnapshotRetain
+	}
+	for i := 0; i < len(snaps)-retain; i++ {
+		if err := r.Delete(ctx, &snaps[i]); client.IgnoreNotFound(err) != nil {
+			return 0, fmt.Errorf("prune snapshot %s: %w", snaps[i].GetName(), err)
+		}
+		r.event(sess, corev1.EventTypeNormal, reasonSnapshotPruned, "Deleted snapshot %s (retain %d)", snaps[i].GetName(), retain)
+	}
+
+	return time.Until(next), nil
+}
+
+// newVolumeSnapshot builds a scheduled snapshot of a Session volume, owned by
+// the Session.
+func (r *SessionReconciler) newVolumeSnapshot(sess *codespacev1.Session, name, pvcName, volume, class string) *unstructured.Unstructured {
+	snap := codespacev1.NewVolumeSnapshot(sess.Namespace, name, pvcName, class, map[string]string{
+		codespacev1.SessionNameLabel:     sess.Name,
+		codespacev1.SnapshotVolumeLabel:  volume,
+		codespacev1.SnapshotTriggerLabel: codespacev1.SnapshotTriggerScheduled,
+	})
+	snap.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: codespacev1.GroupVersion.String(),
+		Kind:       "Session",
+		Name:       sess.Name,
+		UID:        sess.UID,
+	}})
+	return snap
+}
+
+// reconcileRestore drives a restore requested through RestoreSnapshotAnnotation:
+// wait for the snapshot, let the Session scale down, delete <name>-home, recreate
+// it from the snapshot and finally drop the annotations. It reports whether the
+// restore is still in progress; while it is, the caller keeps the Session at zero
+// replicas and does not apply the home claim itself.
+func (r *SessionReconciler) reconcileRestore(ctx context.Context, sess *codespacev1.Session, opts *Options, name string) (bool, error) {
+	snapName := sess.Annotations[codespacev1.RestoreSnapshotAnnotation]
+	if snapName == "" {
+		return false, nil
+	}
+	requestID := sess.Annotations[codespacev1.RestoreRequestAnnotation]
+	if requestID == "" {
+		requestID = snapName
+	}
+	if sess.Spec.Home == nil {
+		return false, r.finishRestore(ctx, sess, codespacev1.RestorePhaseFailed, "session has no home volume")
+	}
+
+	if st := sess.Status.Restore; st == nil || st.Snapshot != snapName || st.Phase != codespacev1.RestorePhaseInProgress {
+		sess.Status.Restore = &codespacev1.RestoreStatus{
+			Snapshot:  snapName,
+			Phase:     codespacev1.RestorePhaseInProgress,
+			StartedAt: ptr.To(metav1.Now()),
+		}
+		r.event(sess, corev1.EventTypeNormal, reasonRestoreStarted, "Restoring home volume from snapshot %s", snapName)
+	}
+	progress := func(msg string) (bool, error) {
+		sess.Status.Restore.Message = msg
+		return true, nil
+	}
+
+	// Never touch the current volume before the snapshot is usable
+	snap := &unstructured.Unstructured{}
+	snap.SetGroupVersionKind(codespacev1.VolumeSnapshotGVK)
+	if err := r.Get(ctx, client.ObjectKey{Namespace: sess.Namespace, Name: snapName}, snap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, r.finishRestore(ctx, sess, codespacev1.RestorePhaseFailed,
+				fmt.Sprintf("VolumeSnapshot %s not found", snapName))
+		}
+		return true, err
+	}
+	if ready, _, _ := unstructured.NestedBool(snap.Object, "status", "readyToUse"); !ready {
+		return progress("waiting for the snapshot to be ready")
+	}
+
+	pvcName := name + "-home"
+	var pvc corev1.PersistentVolumeClaim
+	err := r.Get(ctx, client.ObjectKey{Namespace: sess.Namespace, Name: pvcName}, &pvc)
+	switch {
+	case apierrors.IsNotFound(err):
+		ds := codespacev1.VolumeSnapshotDataSource(snapName)
+		if err := r.applyPVC(ctx, sess, opts, pvcName, "home", sess.Spec.Home, ds,
+			map[string]string{codespacev1.RestoreRequestAnnotation: requestID}); err != nil {
+			return true, err
+		}
+		return progress("recreating the home volume from the snapshot")
+	case err != nil:
+		return true, err
+	case pvc.Annotations[codespacev1.RestoreRequestAnnotation] == requestID:
+		return false, r.finishRestore(ctx, sess, codespacev1.RestorePhaseCompleted, "restored from snapshot "+snapName)
+	case !pvc.DeletionTimestamp.IsZero():
+		return progress("waiting for the old home volume to be released")
+	}
+
+	// Scale-down happens in this reconcile; delete the claim once no pod is left
+	var dep appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKey{Namespace: sess.Namespace, Name: name}, &dep); err == nil && dep.Status.Replicas > 0 {
+		return progress("suspending the session")
+	} else if client.IgnoreNotFound(err) != nil {
+		return true, err
+	}
+	if err := r.Delete(ctx, &pvc, client.Preconditions{UID: &pvc.UID}); client.IgnoreNotFound(err) != nil {
+		return true, err
+	}
+	return progress("deleting the old home volume")
+}
+
+// finishRestore records the outcome and removes the restore annotations. Only
+// metadata is patched (through a copy), so in-memory defaults and status survive.
+func (r *SessionReconciler) finishRestore(ctx context.Context, sess *codespacev1.Session, phase, msg string) error {
+	snapName := sess.Annotations[codespacev1.RestoreSnapshotAnnotation]
+
+	cp := sess.DeepCopy()
+	base := cp.DeepCopy()
+	delete(cp.Annotations, codespacev1.RestoreSnapshotAnnotation)
+	delete(cp.Annotations, codespacev1.RestoreRequestAnnotation)
+	if err := r.Patch(ctx, cp, client.MergeFrom(base)); err != nil {
+		return err
+	}
+	delete(sess.Annotations, codespacev1.RestoreSnapshotAnnotation)
+	delete(sess.Annotations, codespacev1.RestoreRequestAnnotation)
+	sess.ResourceVersion = cp.ResourceVersion
+
+	if sess.Status.Restore == nil {
+		sess.Status.Restore = &codespacev1.RestoreStatus{Snapshot: snapName}
+	}
+	sess.Status.Restore.Phase = phase
+	sess.Status.Restore.Message = msg
+	sess.Status.Restore.CompletedAt = ptr.To(metav1.Now())
+
+	if phase == codespacev1.RestorePhaseFailed {
+		return fmt.Errorf("restore from %s: %s", snapName, msg)
+	}
+	r.event(sess, corev1.EventTypeNormal, reasonRestoreCompleted, "Home volume restored from snapshot %s", snapName)
+	return nil
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	auth "github.com/codespace-operator/common/auth/pkg/auth"
+	"github.com/codespace-operator/common/common/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// serverEventSource is the component name used for events emitted by the API server.
+const serverEventSource = "codespace-server"
+
+// audit writes a structured audit record for a privileged operation. Records go to
+// the "audit" log component so they can be routed separately from request logs.
+func (h *handlers) audit(r *http.Request, action string, attrs ...any) {
+	subject, provider := "-", "-"
+	if cl := auth.FromContext(r); cl != nil {
+		subject, provider = cl.Sub, cl.Provider
+	}
+	base := []any{
+		"action", action,
+		"user", subject,
+		"provider", provider,
+		"ip", clientIP(r),
+		"request_id", r.Header.Get("X-Request-Id"),
+	}
+	common.LoggerWithComponent(h.deps.logger, "audit").Info("audit", append(base, attrs...)...)
+}
+
+// recordSessionEvent emits a Kubernetes Event against the session so that actions
+// taken through the server show up next to the controller's events. Failures are
+// logged and otherwise ignored: the audit log remains the source of truth.
+func (h *handlers) recordSessionEvent(ctx context.Context, sess *codespacev1.Session, eventType, reason, message string) {
+	now := metav1.NewTime(time.Now())
+	ev := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: sess.Name + ".",
+			Namespace:    sess.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      codespacev1.GroupVersion.String(),
+			Kind:            "Session",
+			Namespace:       sess.Namespace,
+			Name:            sess.Name,
+			UID:             sess.UID,
+			ResourceVersion: sess.ResourceVersion,
+		},
+		Type:                eventType,
+		Reason:              reason,
+		Message:             message,
+		Source:              corev1.EventSource{Component: serverEventSource},
+		ReportingController: serverEventSource,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+	if err := h.deps.client.Create(ctx, ev); err != nil {
+		logger.Debug("Failed to record session event", "reason", reason, "session", fmt.Sprintf("%s/%s", sess.Namespace, sess.Name), "err", err)
+	}
+}
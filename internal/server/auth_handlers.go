@@ -129,6 +129,7 @@ func (h *handlers) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
 	// Provider verifies and returns claims
 	claims, err := p.HandleCallback(w, r)
 	if err != nil {
+		authFailuresTotal.WithLabelValues(auth.OIDC_PROVIDER).Inc()
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
@@ -254,6 +255,7 @@ func (h *handlers) handlePasswordLogin(w http.ResponseWriter, r *http.Request) {
 				if err == nil {
 					goto ISSUE
 				}
+				authFailuresTotal.WithLabelValues(auth.LOCAL_PROVIDER).Inc()
 			}
 		case auth.LDAP_PROVIDER:
 			if p := h.deps.authManager.GetProvider(auth.LDAP_PROVIDER); p != nil {
@@ -262,6 +264,7 @@ func (h *handlers) handlePasswordLogin(w http.ResponseWriter, r *http.Request) {
 					if err == nil {
 						goto ISSUE
 					}
+					authFailuresTotal.WithLabelValues(auth.LDAP_PROVIDER).Inc()
 				}
 			}
 		}
@@ -296,6 +299,7 @@ func (h *handlers) handleRefresh(w http.ResponseWriter, r *http.Request) {
 	}
 	claims, err := h.deps.authManager.ValidateRequest(r) // read current cookie/header
 	if err != nil {
+		authFailuresTotal.WithLabelValues("session").Inc()
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	auth "github.com/codespace-operator/common/auth/pkg/auth"
+	"github.com/codespace-operator/common/common/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// Batch actions and the RBAC action each one requires per session.
+var batchActions = map[string]string{
+	"stop":   "scale",
+	"start":  "scale",
+	"scale":  "scale",
+	"delete": "delete",
+	"label":  "update",
+}
+
+// Per-item outcomes of a batch request.
+const (
+	batchApplied   = "applied"
+	batchUnchanged = "unchanged"
+	batchDenied    = "denied"
+	batchFailed    = "failed"
+)
+
+// reservedSessionLabels are owned by the server and cannot be changed in bulk.
+var reservedSessionLabels = map[string]bool{
+	common.InstanceIDLabel:       true,
+	common.LabelCreatedBy:        true,
+	common.LabelManagerType:      true,
+	common.LabelManagerNamespace: true,
+	common.LabelManagerName:      true,
+}
+
+// SessionBatchSelector picks the sessions a batch request applies to
+// @Description Sessions to act on; all set fields must match
+type SessionBatchSelector struct {
+	// Namespace limits the batch to one namespace; empty means all namespaces
+	Namespace     string   `json:"namespace,omitempty" example:"default"`
+	LabelSelector string   `json:"labelSelector,omitempty" example:"team=data"`
+	Creator       string   `json:"creator,omitempty" example:"alice"`
+	Phases        []string `json:"phases,omitempty" example:"Ready,Pending"`
+}
+
+// SessionBatchRequest is the request body of the batch endpoint
+// @Description Apply one action to every session matching a selector
+type SessionBatchRequest struct {
+	Selector SessionBatchSelector `json:"selector"`
+	Action   string               `json:"action" example:"stop" enums:"stop,start,delete,scale,label"`
+	// Replicas for scale (required) and start (default 1)
+	Replicas *int32 `json:"replicas,omitempty" example:"1"`
+	// Labels to set for label
+	Labels map[string]string `json:"labels,omitempty"`
+	// RemoveLabels to delete for label
+	RemoveLabels []string `json:"removeLabels,omitempty"`
+	// DryRun evaluates RBAC and runs a server-side dry run without persisting anything
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// SessionBatchResult is the outcome for one session
+// @Description Per-session result of a batch request
+type SessionBatchResult struct {
+	Namespace string `json:"namespace" example:"default"`
+	Name      string `json:"name" example:"my-session"`
+	Status    string `json:"status" example:"applied" enums:"applied,unchanged,denied,failed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SessionBatchResponse reports what a batch request did
+// @Description Summary and per-session results of a batch request
+type SessionBatchResponse struct {
+	Action    string               `json:"action" example:"stop"`
+	DryRun    bool                 `json:"dryRun"`
+	Matched   int                  `json:"matched" example:"3"`
+	Applied   int                  `json:"applied" example:"2"`
+	Unchanged int                  `json:"unchanged" example:"0"`
+	Denied    int                  `json:"denied" example:"1"`
+	Failed    int                  `json:"failed" example:"0"`
+	Results   []SessionBatchResult `json:"results"`
+}
+
+// @Summary Batch session operation
+// @ID batchSessions
+// @Description Stop, start, delete, scale or label every session matching a selector.
+// @Description Sessions the caller cannot list are skipped silently; for the rest, the action is checked per session
+// @Description and reported as denied if not allowed. dryRun (body or query) runs a server-side dry run.
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param request body SessionBatchRequest true "Batch request"
+// @Param dryRun query integer false "If set to 1, nothing is persisted" enums(0,1) default(0)
+// @Success 200 {object} SessionBatchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/server/sessions:batch [post]
+func (h *handlers) handleBatchSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cl := auth.FromContext(r)
+	if cl == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req SessionBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if d := r.URL.Query().Get("dryRun"); d == "1" || d == "true" {
+		req.DryRun = true
+	}
+	if err := validateBatchRequest(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sel, err := sessionSelector(req.Selector.LabelSelector, req.Selector.Creator)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lq := &sessionListQuery{Selector: sel, Phases: csvSet(strings.Join(req.Selector.Phases, ","))}
+	var instanceSel labels.Selector
+	if !h.deps.config.ClusterScope {
+		instanceSel = labels.SelectorFromSet(labels.Set{common.InstanceIDLabel: h.deps.instanceID})
+	}
+
+	// Always read from the API server: the batch acts on what exists now
+	var sl codespacev1.SessionList
+	if err := h.deps.client.List(r.Context(), &sl, lq.listOptions(req.Selector.Namespace, instanceSel)...); err != nil {
+		logger.Error("Failed to list sessions for batch", "err", err, "user", cl.Sub)
+		errJSON(w, fmt.Errorf("failed to list sessions: %w", err))
+		return
+	}
+	lq.sortSessions(sl.Items)
+
+	resp := SessionBatchResponse{Action: req.Action, DryRun: req.DryRun, Results: []SessionBatchResult{}}
+	rbacAction := batchActions[req.Action]
+	for i := range sl.Items {
+		s := &sl.Items[i]
+		if !lq.matches(s) {
+			continue
+		}
+		// Do not reveal sessions the caller cannot see
+		if ok, err := h.deps.rbac.Enforce(cl.Sub, cl.Roles, SESSION_RESOURCE_STRING, "list", s.Namespace); err != nil || !ok {
+			continue
+		}
+
+		res := SessionBatchResult{Namespace: s.Namespace, Name: s.Name}
+		if ok, err := h.deps.rbac.Enforce(cl.Sub, cl.Roles, SESSION_RESOURCE_STRING, rbacAction, s.Namespace); err != nil || !ok {
+			res.Status = batchDenied
+		} else {
+			res.Status, err = h.applyBatchAction(r, s, &req)
+			if err != nil {
+				res.Error = err.Error()
+			}
+		}
+
+		resp.Matched++
+		switch res.Status {
+		case batchApplied:
+			resp.Applied++
+		case batchUnchanged:
+			resp.Unchanged++
+		case batchDenied:
+			resp.Denied++
+		case batchFailed:
+			resp.Failed++
+		}
+		resp.Results = append(resp.Results, res)
+	}
+
+	h.audit(r, "session.batch",
+		"batch_action", req.Action,
+		"dry_run", req.DryRun,
+		"namespace", req.Selector.Namespace,
+		"label_selector", req.Selector.LabelSelector,
+		"creator", req.Selector.Creator,
+		"phases", strings.Join(req.Selector.Phases, ","),
+		"matched", resp.Matched,
+		"applied", resp.Applied,
+		"denied", resp.Denied,
+		"failed", resp.Failed)
+	writeJSON(w, resp)
+}
+
+func validateBatchRequest(req *SessionBatchRequest) error {
+	if _, ok := batchActions[req.Action]; !ok {
+		return fmt.Errorf("invalid action %q (want stop, start, delete, scale or label)", req.Action)
+	}
+	s := req.Selector
+	if s.Namespace == "" && s.LabelSelector == "" && s.Creator == "" && len(s.Phases) == 0 {
+		return fmt.Errorf("selector must not be empty")
+	}
+
+	switch req.Action {
+	case "scale":
+		if req.Replicas == nil {
+			return fmt.Errorf("replicas is required for scale")
+		}
+	case "label":
+		if len(req.Labels) == 0 && len(req.RemoveLabels) == 0 {
+			return fmt.Errorf("labels or removeLabels is required for label")
+		}
+		for k, v := range req.Labels {
+			if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+				return fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, "; "))
+			}
+			if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+				return fmt.Errorf("invalid label value %q: %s", v, strings.Join(errs, "; "))
+			}
+		}
+		for _, k := range append(slices.Collect(maps.Keys(req.Labels)), req.RemoveLabels...) {
+			if reservedSessionLabels[k] {
+				return fmt.Errorf("label %q is managed by the server", k)
+			}
+		}
+	}
+	if req.Replicas != nil && *req.Replicas < 0 {
+		return fmt.Errorf("replicas cannot be negative")
+	}
+	return nil
+}
+
+// applyBatchAction performs the request's action on one session. Updates are
+// merge patches so concurrent changes to other fields are not overwritten.
+func (h *handlers) applyBatchAction(r *http.Request, s *codespacev1.Session, req *SessionBatchRequest) (string, error) {
+	ctx := r.Context()
+	if req.Action == "delete" {
+		opts := []client.DeleteOption{}
+		if req.DryRun {
+			opts = append(opts, client.DryRunAll)
+		}
+		if err := h.deps.client.Delete(ctx, s, opts...); err != nil {
+			if apierrors.IsNotFound(err) {
+				return batchUnchanged, nil
+			}
+			return batchFailed, err
+		}
+		if !req.DryRun {
+			h.recordSessionEvent(ctx, s, corev1.EventTypeNormal, "BatchDeleted", "Deleted by batch request of "+auth.FromContext(r).Sub)
+		}
+		return batchApplied, nil
+	}
+
+	patch := client.MergeFrom(s.DeepCopy())
+	switch req.Action {
+	case "stop":
+		s.Spec.Replicas = ptr.To[int32](0)
+	case "start":
+		replicas := int32(1)
+		if req.Replicas != nil && *req.Replicas > 0 {
+			replicas = *req.Replicas
+		}
+		// Leave already running sessions at their current size
+		if s.Spec.Replicas == nil || *s.Spec.Replicas == 0 {
+			s.Spec.Replicas = &replicas
+		}
+	case "scale":
+		s.Spec.Replicas = req.Replicas
+	case "label":
+		if s.Labels == nil {
+			s.Labels = map[string]string{}
+		}
+		for k, v := range req.Labels {
+			s.Labels[k] = v
+		}
+		for _, k := range req.RemoveLabels {
+			delete(s.Labels, k)
+		}
+	}
+
+	data, err := patch.Data(s)
+	if err != nil {
+		return batchFailed, err
+	}
+	if string(data) == "{}" {
+		return batchUnchanged, nil
+	}
+
+	opts := []client.PatchOption{}
+	if req.DryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := h.deps.client.Patch(ctx, s, patch, opts...); err != nil {
+		return batchFailed, err
+	}
+	return batchApplied, nil
+}
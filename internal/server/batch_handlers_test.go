@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/codespace-operator/common/common/pkg/common"
+	"k8s.io/utils/ptr"
+)
+
+func TestValidateBatchRequest(t *testing.T) {
+	ns := SessionBatchSelector{Namespace: "default"}
+	cases := []struct {
+		name    string
+		req     SessionBatchRequest
+		wantErr bool
+	}{
+		{"stop namespace", SessionBatchRequest{Selector: ns, Action: "stop"}, false},
+		{"unknown action", SessionBatchRequest{Selector: ns, Action: "restart"}, true},
+		{"empty selector", SessionBatchRequest{Action: "delete"}, true},
+		{"scale needs replicas", SessionBatchRequest{Selector: ns, Action: "scale"}, true},
+		{"negative replicas", SessionBatchRequest{Selector: ns, Action: "scale", Replicas: ptr.To[int32](-1)}, true},
+		{"label needs labels", SessionBatchRequest{Selector: ns, Action: "label"}, true},
+		{"invalid label value", SessionBatchRequest{Selector: ns, Action: "label", Labels: map[string]string{"team": "a b"}}, true},
+		{"reserved label", SessionBatchRequest{Selector: ns, Action: "label", RemoveLabels: []string{common.InstanceIDLabel}}, true},
+		{"label by creator", SessionBatchRequest{
+			Selector: SessionBatchSelector{Creator: "alice"}, Action: "label", Labels: map[string]string{"team": "data"},
+		}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateBatchRequest(&tc.req)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("validateBatchRequest() err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
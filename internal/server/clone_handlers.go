@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// Ways to copy the home volume when cloning.
+const (
+	cloneHomeNone     = "none"
+	cloneHomeClone    = "clone"
+	cloneHomeSnapshot = "snapshot"
+)
+
+// clonedFromAnnotation records the "namespace/name" a session was cloned from.
+const clonedFromAnnotation = "codespace.dev/cloned-from"
+
+// SessionCloneRequest represents the request body for cloning a session
+// @Description Request body for cloning a session
+type SessionCloneRequest struct {
+	Name string `json:"name" validate:"required" example:"my-session-copy"`
+	// Namespace of the new session; defaults to the source namespace
+	Namespace string `json:"namespace,omitempty" example:"default"`
+	// CopyHome copies the home volume: none (fresh volume), clone (CSI volume clone)
+	// or snapshot (VolumeSnapshot, then restore). Copies need the same namespace.
+	CopyHome string `json:"copyHome,omitempty" example:"clone" enums:"none,clone,snapshot"`
+	// VolumeSnapshotClassName for copyHome=snapshot; empty uses the default class
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// Replicas of the new session; defaults to the source's
+	Replicas *int32 `json:"replicas,omitempty" example:"1"`
+}
+
+// @Summary Clone session
+// @ID cloneSession
+// @Description Create a new session with the same spec as an existing one, optionally copying its home volume.
+// @Description Requires get on the source namespace and create on the target namespace. The ingress host is not copied.
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Source namespace"
+// @Param name path string true "Source session name"
+// @Param request body SessionCloneRequest true "Clone request"
+// @Success 201 {object} codespacev1.Session
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/clone [post]
+func (h *handlers) handleCloneSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := sessionPathParts(r)
+	if len(parts) < 3 || parts[2] != "clone" {
+		http.Error(w, "invalid path - expected /api/v1/server/sessions/{namespace}/{name}/clone", http.StatusBadRequest)
+		return
+	}
+	namespace, name := parts[0], parts[1]
+
+	var req SessionCloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "session name is required", http.StatusBadRequest)
+		return
+	}
+	if req.Namespace == "" {
+		req.Namespace = namespace
+	}
+	switch req.CopyHome {
+	case "":
+		req.CopyHome = cloneHomeNone
+	case cloneHomeNone, cloneHomeClone, cloneHomeSnapshot:
+	default:
+		http.Error(w, fmt.Sprintf("invalid copyHome %q (want none, clone or snapshot)", req.CopyHome), http.StatusBadRequest)
+		return
+	}
+	if req.CopyHome != cloneHomeNone && req.Namespace != namespace {
+		http.Error(w, "copying the home volume requires the same namespace", http.StatusBadRequest)
+		return
+	}
+	if req.Replicas != nil && *req.Replicas < 0 {
+		http.Error(w, "replicas cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	// RBAC on both ends
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "get", namespace)
+	if !ok {
+		return
+	}
+	if _, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "create", req.Namespace); !ok {
+		return
+	}
+
+	source, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		logger.Error("Failed to get session for clone", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		writeSessionLookupError(w, err)
+		return
+	}
+	// The clone runs the source's sidecars in its own namespace, as pr
+	if !h.canRunSidecars(w, pr, req.Namespace, source.Spec.Sidecars, nil) {
+		return
+	}
+
+	clone := &codespacev1.Session{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: codespacev1.GroupVersion.String(),
+			Kind:       "Session",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        req.Name,
+			Namespace:   req.Namespace,
+			Labels:      h.newSessionLabels(req.Name, pr.Subject),
+			Annotations: newSessionAnnotations(pr.Subject),
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	// Carry over user labels, but not the identity labels set above
+	for k, v := range source.Labels {
+		if _, set := clone.Labels[k]; !set && !strings.HasPrefix(k, "app.kubernetes.io/") {
+			clone.Labels[k] = v
+		}
+	}
+	clone.Annotations[clonedFromAnnotation] = namespace + "/" + name
+	if clone.Spec.Networking != nil {
+		clone.Spec.Networking.Host = ""
+	}
+	if clone.Spec.Home != nil {
+		clone.Spec.Home.DataSource = nil
+	}
+	if req.Replicas != nil {
+		clone.Spec.Replicas = req.Replicas
+	}
+
+	var snapshot client.Object
+	if req.CopyHome != cloneHomeNone {
+		pvcName, err := h.homePVCName(r.Context(), source)
+		if err != nil {
+			if errors.Is(err, errNoHomeVolume) {
+				http.Error(w, "source session has no home volume to copy", http.StatusBadRequest)
+				return
+			}
+			errJSON(w, fmt.Errorf("failed to find home volume: %w", err))
+			return
+		}
+
+		ds := &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: pvcName}
+		if req.CopyHome == cloneHomeSnapshot {
+			snap := codespacev1.NewVolumeSnapshot(namespace, "", pvcName, req.VolumeSnapshotClassName,
+				map[string]string{
+					codespacev1.SessionNameLabel:     req.Name,
+					codespacev1.SnapshotVolumeLabel:  "home",
+					codespacev1.SnapshotTriggerLabel: codespacev1.SnapshotTriggerClone,
+				})
+			// Unique, so clones of the same name (e.g. after a failed one) never collide
+			snap.SetGenerateName(req.Name + "-clone-")
+			if err := h.deps.client.Create(r.Context(), snap); err != nil {
+				logger.Error("Failed to snapshot home volume for clone", "pvc", pvcName, "namespace", namespace, "err", err, "user", pr.Subject)
+				errJSON(w, fmt.Errorf("failed to snapshot home volume: %w", err))
+				return
+			}
+			snapshot = snap
+			ds = codespacev1.VolumeSnapshotDataSource(snap.GetName())
+		}
+		clone.Spec.Home.DataSource = ds
+	}
+
+	if err := h.deps.client.Create(r.Context(), clone); err != nil {
+		if snapshot != nil {
+			_ = h.deps.client.Delete(r.Context(), snapshot)
+		}
+		logger.Error("Failed to create cloned session", "name", req.Name, "namespace", req.Namespace, "err", err, "user", pr.Subject)
+		if apierrors.IsAlreadyExists(err) {
+			http.Error(w, "session already exists", http.StatusConflict)
+			return
+		}
+		errJSON(w, fmt.Errorf("failed to create session: %w", err))
+		return
+	}
+
+	// The snapshot only exists to seed the clone; let it go with the clone
+	if snapshot != nil {
+		patch := client.MergeFrom(snapshot.DeepCopyObject().(client.Object))
+		snapshot.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: codespacev1.GroupVersion.String(),
+			Kind:       "Session",
+			Name:       clone.Name,
+			UID:        clone.UID,
+		}})
+		if err := h.deps.client.Patch(r.Context(), snapshot, patch); err != nil {
+			logger.Warn("Failed to set owner on clone snapshot", "snapshot", snapshot.GetName(), "err", err)
+		}
+	}
+
+	h.audit(r, "session.clone",
+		"source", namespace+"/"+name,
+		"target", req.Namespace+"/"+req.Name,
+		"copy_home", req.CopyHome)
+	h.recordSessionEvent(r.Context(), source, corev1.EventTypeNormal, "Cloned",
+		fmt.Sprintf("Cloned to %s/%s by %s", req.Namespace, req.Name, pr.Subject))
+
+	logger.Info("Cloned session", "source", namespace+"/"+name, "target", req.Namespace+"/"+req.Name, "copyHome", req.CopyHome, "user", pr.Subject)
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, clone)
+}
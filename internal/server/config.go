@@ -26,9 +26,15 @@ type ServerConfig struct {
 	ReadTimeout  int    `mapstructure:"read_timeout"`
 	WriteTimeout int    `mapstructure:"write_timeout"`
 
-	// CORS
+	// CORS: comma-separated origins allowed to call the API with credentials.
+	// "*" allows anonymous cross-origin reads only.
 	AllowOrigin string `mapstructure:"allow_origin"`
 
+	// SessionProxyDomain serves each session proxied under /s/ on a host of its
+	// own below this domain (needs a wildcard DNS record and certificate). The
+	// proxy is disabled while it is empty.
+	SessionProxyDomain string `mapstructure:"session_proxy_domain"`
+
 	// Kubernetes
 	KubeQPS   float32 `mapstructure:"kube_qps"`
 	KubeBurst int     `mapstructure:"kube_burst"`
@@ -42,6 +48,18 @@ type ServerConfig struct {
 
 	// Auth config file path - note the correct mapstructure tag
 	AuthConfigPath string `mapstructure:"auth_config_path"`
+
+	// Observability
+	MetricsEnabled bool `mapstructure:"metrics_enabled"`
+	// MetricsAddr is the listener for /metrics, kept off the public port. Empty
+	// serves /metrics on the public port, which then requires MetricsToken.
+	MetricsAddr string `mapstructure:"metrics_addr"`
+	// MetricsToken, if set, is the bearer token scrapers must send.
+	MetricsToken       string  `mapstructure:"metrics_token"`
+	TracingEnabled     bool    `mapstructure:"tracing_enabled"`
+	OTLPEndpoint       string  `mapstructure:"otlp_endpoint"`
+	OTLPInsecure       bool    `mapstructure:"otlp_insecure"`
+	TracingSampleRatio float64 `mapstructure:"tracing_sample_ratio"`
 }
 
 // -----------------------------
@@ -68,6 +86,17 @@ func LoadServerConfig() (*ServerConfig, *viper.Viper, error) {
 // Helpers / methods
 // -----------------------------
 
+// AllowedOrigins splits AllowOrigin into its origins.
+func (c *ServerConfig) AllowedOrigins() []string {
+	var out []string
+	for _, o := range strings.Split(c.AllowOrigin, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
 func (c *ServerConfig) GetAddr() string {
 	if strings.TrimSpace(c.Host) == "" {
 		return fmt.Sprintf(":%d", c.Port)
@@ -86,6 +115,7 @@ func setServerDefaults(v *viper.Viper) {
 	v.SetDefault("developer_mode", false)
 
 	v.SetDefault("allow_origin", "")
+	v.SetDefault("session_proxy_domain", "")
 	v.SetDefault("kube_qps", 50.0)
 	v.SetDefault("kube_burst", 100)
 	v.SetDefault("log_level", "info")
@@ -95,6 +125,14 @@ func setServerDefaults(v *viper.Viper) {
 
 	v.SetDefault("local_users_path", "/etc/codespace-operator/auth/local-users.yaml")
 	v.SetDefault("auth_config_path", "/etc/codespace-operator/auth/auth.yaml")
+
+	v.SetDefault("metrics_enabled", true)
+	v.SetDefault("metrics_addr", ":9464")
+	v.SetDefault("metrics_token", "")
+	v.SetDefault("tracing_enabled", false)
+	v.SetDefault("otlp_endpoint", "localhost:4317")
+	v.SetDefault("otlp_insecure", true)
+	v.SetDefault("tracing_sample_ratio", 1.0)
 }
 
 func (c *ServerConfig) BuildAuthConfig() (*auth.AuthConfig, error) {
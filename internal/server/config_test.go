@@ -325,6 +325,32 @@ func TestServerConfig_EnvironmentVariables(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "observability settings",
+			envVars: map[string]string{
+				"CODESPACE_SERVER_METRICS_ENABLED":      "false",
+				"CODESPACE_SERVER_TRACING_ENABLED":      "true",
+				"CODESPACE_SERVER_OTLP_ENDPOINT":        "otel-collector:4317",
+				"CODESPACE_SERVER_TRACING_SAMPLE_RATIO": "0.25",
+			},
+			verifyFn: func(t *testing.T, cfg *ServerConfig) {
+				if cfg.MetricsEnabled {
+					t.Error("MetricsEnabled: want false, got true")
+				}
+				if !cfg.TracingEnabled {
+					t.Error("TracingEnabled: want true, got false")
+				}
+				if cfg.OTLPEndpoint != "otel-collector:4317" {
+					t.Errorf("OTLPEndpoint: want 'otel-collector:4317', got %q", cfg.OTLPEndpoint)
+				}
+				if !cfg.OTLPInsecure {
+					t.Error("OTLPInsecure: want default true")
+				}
+				if cfg.TracingSampleRatio != 0.25 {
+					t.Errorf("TracingSampleRatio: want 0.25, got %f", cfg.TracingSampleRatio)
+				}
+			},
+		},
 		{
 			name:    "defaults when no env vars set",
 			envVars: map[string]string{},
@@ -354,6 +380,9 @@ func TestServerConfig_EnvironmentVariables(t *testing.T) {
 				if cfg.GetAddr() != ":8080" {
 					t.Errorf("GetAddr(): want default ':8080', got %q", cfg.GetAddr())
 				}
+				if cfg.MetricsAddr != ":9464" {
+					t.Errorf("MetricsAddr: want default ':9464', got %q", cfg.MetricsAddr)
+				}
 			},
 		},
 		{
@@ -0,0 +1,421 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+const defaultDetailsEventLimit = 20
+
+// SessionDetails aggregates the state of a session and its child resources
+// @Description Everything needed to debug a session in one call
+type SessionDetails struct {
+	Session    *codespacev1.Session `json:"session"`
+	Deployment *DeploymentSummary   `json:"deployment,omitempty"`
+	Pods       []PodSummary         `json:"pods"`
+	Volumes    []VolumeSummary      `json:"volumes"`
+	Service    *ServiceSummary      `json:"service,omitempty"`
+	Ingress    *IngressSummary      `json:"ingress,omitempty"`
+	Events     []SessionEvent       `json:"events"`
+}
+
+// DeploymentSummary is the rollout state of the session Deployment
+type DeploymentSummary struct {
+	Name                string             `json:"name" example:"cs-my-session"`
+	Replicas            int32              `json:"replicas" example:"1"`
+	UpdatedReplicas     int32              `json:"updatedReplicas" example:"1"`
+	ReadyReplicas       int32              `json:"readyReplicas" example:"1"`
+	AvailableReplicas   int32              `json:"availableReplicas" example:"1"`
+	UnavailableReplicas int32              `json:"unavailableReplicas" example:"0"`
+	RolloutComplete     bool               `json:"rolloutComplete" example:"true"`
+	Conditions          []ConditionSummary `json:"conditions,omitempty"`
+}
+
+// ConditionSummary is a condition of a child resource
+type ConditionSummary struct {
+	Type               string    `json:"type" example:"Available"`
+	Status             string    `json:"status" example:"True"`
+	Reason             string    `json:"reason,omitempty" example:"MinimumReplicasAvailable"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// PodSummary is a session pod with its container statuses
+type PodSummary struct {
+	Name       string             `json:"name" example:"cs-my-session-7d9c6b5f4-abcde"`
+	Phase      string             `json:"phase" example:"Running"`
+	Ready      bool               `json:"ready" example:"true"`
+	Restarts   int32              `json:"restarts" example:"0"`
+	Node       string             `json:"node,omitempty" example:"worker-1"`
+	PodIP      string             `json:"podIP,omitempty" example:"10.244.1.12"`
+	StartTime  *time.Time         `json:"startTime,omitempty"`
+	Reason     string             `json:"reason,omitempty"`
+	Message    string             `json:"message,omitempty"`
+	Containers []ContainerSummary `json:"containers"`
+}
+
+// ContainerSummary is the status of a single (init) container
+type ContainerSummary struct {
+	Name             string `json:"name" example:"ide"`
+	Image            string `json:"image" example:"jupyter/minimal-notebook:latest"`
+	Init             bool   `json:"init,omitempty"`
+	Ready            bool   `json:"ready" example:"true"`
+	RestartCount     int32  `json:"restartCount" example:"0"`
+	State            string `json:"state" example:"running"`
+	Reason           string `json:"reason,omitempty" example:"CrashLoopBackOff"`
+	Message          string `json:"message,omitempty"`
+	LastTermination  string `json:"lastTerminationReason,omitempty" example:"OOMKilled"`
+	LastTerminatedAt string `json:"lastTerminatedAt,omitempty"`
+}
+
+// VolumeSummary is a session PersistentVolumeClaim
+type VolumeSummary struct {
+	Name         string   `json:"name" example:"cs-my-session-home"`
+	Phase        string   `json:"phase" example:"Bound"`
+	Requested    string   `json:"requested,omitempty" example:"10Gi"`
+	Capacity     string   `json:"capacity,omitempty" example:"10Gi"`
+	StorageClass string   `json:"storageClass,omitempty" example:"standard"`
+	AccessModes  []string `json:"accessModes,omitempty"`
+	VolumeName   string   `json:"volumeName,omitempty"`
+}
+
+// ServiceSummary is the session Service
+type ServiceSummary struct {
+	Name      string   `json:"name" example:"cs-my-session"`
+	Type      string   `json:"type" example:"ClusterIP"`
+	ClusterIP string   `json:"clusterIP,omitempty" example:"10.96.12.34"`
+	Ports     []string `json:"ports,omitempty" example:"http:80->4180/TCP"`
+}
+
+// IngressSummary is the session Ingress
+type IngressSummary struct {
+	Name         string   `json:"name" example:"cs-my-session"`
+	ClassName    string   `json:"className,omitempty" example:"nginx"`
+	Hosts        []string `json:"hosts,omitempty"`
+	TLS          bool     `json:"tls" example:"true"`
+	LoadBalancer []string `json:"loadBalancer,omitempty" example:"203.0.113.10"`
+}
+
+// @Summary Get session details
+// @ID getSessionDetails
+// @Description Aggregate a session with its Deployment rollout state, pods and container statuses, PVCs, Service, Ingress and recent events
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Param eventLimit query integer false "Number of most recent events to include" default(20)
+// @Success 200 {object} SessionDetails
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/details [get]
+func (h *handlers) handleSessionDetails(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := sessionPathParts(r)
+	if len(parts) < 3 || parts[2] != "details" {
+		http.Error(w, "invalid path - expected /api/v1/server/sessions/{namespace}/{name}/details", http.StatusBadRequest)
+		return
+	}
+	namespace, name := parts[0], parts[1]
+
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "get", namespace)
+	if !ok {
+		return
+	}
+
+	eventLimit := defaultDetailsEventLimit
+	if v := r.URL.Query().Get("eventLimit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "eventLimit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		eventLimit = n
+	}
+
+	session, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		logger.Error("Failed to get session for details", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		writeSessionLookupError(w, err)
+		return
+	}
+
+	details, err := h.buildSessionDetails(r.Context(), session, eventLimit)
+	if err != nil {
+		logger.Error("Failed to collect session details", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		errJSON(w, fmt.Errorf("failed to collect session details: %w", err))
+		return
+	}
+	writeJSON(w, details)
+}
+
+// buildSessionDetails lists the children of a session by SessionNameLabel. The
+// Deployment, Service and Ingress must also be controlled by the session, as
+// anyone who can create objects in the namespace can copy the label.
+func (h *handlers) buildSessionDetails(ctx context.Context, session *codespacev1.Session, eventLimit int) (*SessionDetails, error) {
+	ns := session.Namespace
+	sel := client.MatchingLabels{codespacev1.SessionNameLabel: session.Name}
+	out := &SessionDetails{Session: session, Pods: []PodSummary{}, Volumes: []VolumeSummary{}, Events: []SessionEvent{}}
+
+	var deps appsv1.DeploymentList
+	if err := h.deps.client.List(ctx, &deps, client.InNamespace(ns), sel); err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	if d := controlledBy(deps.Items, session); d != nil {
+		out.Deployment = summarizeDeployment(d)
+	}
+
+	var pods corev1.PodList
+	if err := h.deps.client.List(ctx, &pods, client.InNamespace(ns), sel); err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	for i := range pods.Items {
+		out.Pods = append(out.Pods, summarizePod(&pods.Items[i]))
+	}
+	sort.Slice(out.Pods, func(i, j int) bool { return out.Pods[i].Name < out.Pods[j].Name })
+
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := h.deps.client.List(ctx, &pvcs, client.InNamespace(ns), sel); err != nil {
+		return nil, fmt.Errorf("list persistentvolumeclaims: %w", err)
+	}
+	for i := range pvcs.Items {
+		out.Volumes = append(out.Volumes, summarizePVC(&pvcs.Items[i]))
+	}
+	sort.Slice(out.Volumes, func(i, j int) bool { return out.Volumes[i].Name < out.Volumes[j].Name })
+
+	var svcs corev1.ServiceList
+	if err := h.deps.client.List(ctx, &svcs, client.InNamespace(ns), sel); err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+	if svc := controlledBy(svcs.Items, session); svc != nil {
+		out.Service = summarizeService(svc)
+	}
+
+	var ings netv1.IngressList
+	if err := h.deps.client.List(ctx, &ings, client.InNamespace(ns), sel); err != nil {
+		return nil, fmt.Errorf("list ingresses: %w", err)
+	}
+	if ing := controlledBy(ings.Items, session); ing != nil {
+		out.Ingress = summarizeIngress(ing)
+	}
+
+	// Events are best effort: a missing events permission should not hide the rest.
+	type ref struct{ kind, name string }
+	refs := []ref{{"Session", session.Name}}
+	if out.Deployment != nil {
+		refs = append(refs, ref{"Deployment", out.Deployment.Name})
+	}
+	for _, p := range out.Pods {
+		refs = append(refs, ref{"Pod", p.Name})
+	}
+	for _, v := range out.Volumes {
+		refs = append(refs, ref{"PersistentVolumeClaim", v.Name})
+	}
+	if out.Ingress != nil {
+		refs = append(refs, ref{"Ingress", out.Ingress.Name})
+	}
+	for _, rf := range refs {
+		evs, err := h.listEventsFor(ctx, ns, rf.kind, rf.name, "")
+		if err != nil {
+			logger.Warn("Failed to list events for session child", "kind", rf.kind, "name", rf.name, "err", err)
+			continue
+		}
+		out.Events = append(out.Events, evs...)
+	}
+	sortEvents(out.Events)
+	if eventLimit > 0 && len(out.Events) > eventLimit {
+		out.Events = out.Events[len(out.Events)-eventLimit:]
+	}
+
+	return out, nil
+}
+
+// controlledBy returns the first of items that session controls, or nil.
+func controlledBy[T any, P interface {
+	*T
+	metav1.Object
+}](items []T, session *codespacev1.Session) P {
+	for i := range items {
+		if p := P(&items[i]); metav1.IsControlledBy(p, session) {
+			return p
+		}
+	}
+	return nil
+}
+
+func summarizeDeployment(d *appsv1.Deployment) *DeploymentSummary {
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	s := &DeploymentSummary{
+		Name:                d.Name,
+		Replicas:            desired,
+		UpdatedReplicas:     d.Status.UpdatedReplicas,
+		ReadyReplicas:       d.Status.ReadyReplicas,
+		AvailableReplicas:   d.Status.AvailableReplicas,
+		UnavailableReplicas: d.Status.UnavailableReplicas,
+	}
+	// Same check as `kubectl rollout status`
+	s.RolloutComplete = d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == desired &&
+		d.Status.Replicas == desired &&
+		d.Status.AvailableReplicas == desired
+	for _, c := range d.Status.Conditions {
+		s.Conditions = append(s.Conditions, ConditionSummary{
+			Type:               string(c.Type),
+			Status:             string(c.Status),
+			Reason:             c.Reason,
+			Message:            c.Message,
+			LastTransitionTime: c.LastTransitionTime.Time,
+		})
+	}
+	return s
+}
+
+func summarizePod(p *corev1.Pod) PodSummary {
+	s := PodSummary{
+		Name:       p.Name,
+		Phase:      string(p.Status.Phase),
+		Node:       p.Spec.NodeName,
+		PodIP:      p.Status.PodIP,
+		Reason:     p.Status.Reason,
+		Message:    p.Status.Message,
+		Containers: []ContainerSummary{},
+	}
+	if p.Status.StartTime != nil {
+		t := p.Status.StartTime.Time
+		s.StartTime = &t
+	}
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			s.Ready = c.Status == corev1.ConditionTrue
+		}
+	}
+
+	images := map[string]string{}
+	for _, c := range p.Spec.InitContainers {
+		images[c.Name] = c.Image
+	}
+	for _, c := range p.Spec.Containers {
+		images[c.Name] = c.Image
+	}
+	for _, cs := range p.Status.InitContainerStatuses {
+		s.Containers = append(s.Containers, summarizeContainer(cs, images[cs.Name], true))
+		s.Restarts += cs.RestartCount
+	}
+	for _, cs := range p.Status.ContainerStatuses {
+		s.Containers = append(s.Containers, summarizeContainer(cs, images[cs.Name], false))
+		s.Restarts += cs.RestartCount
+	}
+	return s
+}
+
+func summarizeContainer(cs corev1.ContainerStatus, image string, init bool) ContainerSummary {
+	if image == "" {
+		image = cs.Image
+	}
+	s := ContainerSummary{
+		Name:         cs.Name,
+		Image:        image,
+		Init:         init,
+		Ready:        cs.Ready,
+		RestartCount: cs.RestartCount,
+	}
+	switch {
+	case cs.State.Running != nil:
+		s.State = "running"
+	case cs.State.Waiting != nil:
+		s.State = "waiting"
+		s.Reason = cs.State.Waiting.Reason
+		s.Message = cs.State.Waiting.Message
+	case cs.State.Terminated != nil:
+		s.State = "terminated"
+		s.Reason = cs.State.Terminated.Reason
+		s.Message = cs.State.Terminated.Message
+	default:
+		s.State = "unknown"
+	}
+	if t := cs.LastTerminationState.Terminated; t != nil {
+		s.LastTermination = t.Reason
+		if !t.FinishedAt.IsZero() {
+			s.LastTerminatedAt = t.FinishedAt.Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+func summarizePVC(p *corev1.PersistentVolumeClaim) VolumeSummary {
+	s := VolumeSummary{
+		Name:       p.Name,
+		Phase:      string(p.Status.Phase),
+		VolumeName: p.Spec.VolumeName,
+	}
+	if q, ok := p.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		s.Requested = q.String()
+	}
+	if q, ok := p.Status.Capacity[corev1.ResourceStorage]; ok {
+		s.Capacity = q.String()
+	}
+	if p.Spec.StorageClassName != nil {
+		s.StorageClass = *p.Spec.StorageClassName
+	}
+	for _, m := range p.Spec.AccessModes {
+		s.AccessModes = append(s.AccessModes, string(m))
+	}
+	return s
+}
+
+func summarizeService(svc *corev1.Service) *ServiceSummary {
+	s := &ServiceSummary{
+		Name:      svc.Name,
+		Type:      string(svc.Spec.Type),
+		ClusterIP: svc.Spec.ClusterIP,
+	}
+	for _, p := range svc.Spec.Ports {
+		s.Ports = append(s.Ports, fmt.Sprintf("%s:%d->%s/%s", p.Name, p.Port, p.TargetPort.String(), p.Protocol))
+	}
+	return s
+}
+
+func summarizeIngress(ing *netv1.Ingress) *IngressSummary {
+	s := &IngressSummary{
+		Name: ing.Name,
+		TLS:  len(ing.Spec.TLS) > 0,
+	}
+	if ing.Spec.IngressClassName != nil {
+		s.ClassName = *ing.Spec.IngressClassName
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host != "" {
+			s.Hosts = append(s.Hosts, rule.Host)
+		}
+	}
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			s.LoadBalancer = append(s.LoadBalancer, lb.IP)
+		}
+		if lb.Hostname != "" {
+			s.LoadBalancer = append(s.LoadBalancer, lb.Hostname)
+		}
+	}
+	return s
+}
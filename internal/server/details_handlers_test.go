@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// newTestClient returns a fake client holding objs, with the Event field
+// indexes listEventsFor selects on.
+func newTestClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := codespacev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	b := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...)
+	for field, value := range map[string]func(*corev1.Event) string{
+		"involvedObject.kind": func(e *corev1.Event) string { return e.InvolvedObject.Kind },
+		"involvedObject.name": func(e *corev1.Event) string { return e.InvolvedObject.Name },
+		"involvedObject.uid":  func(e *corev1.Event) string { return string(e.InvolvedObject.UID) },
+	} {
+		b = b.WithIndex(&corev1.Event{}, field, func(o client.Object) []string { return []string{value(o.(*corev1.Event))} })
+	}
+	return b.Build()
+}
+
+// testEvent is an event about kind/name, seen minute minutes into 2025.
+func testEvent(ns, kind, name, uid string, minute int) *corev1.Event {
+	at := metav1.NewTime(time.Date(2025, 1, 1, 0, minute, 0, 0, time.UTC))
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: ns, Name: fmt.Sprintf("%s-%d", name, minute)},
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Name: name, Namespace: ns, UID: types.UID(uid)},
+		Reason:         fmt.Sprintf("Reason%d", minute),
+		Type:           corev1.EventTypeNormal,
+		FirstTimestamp: at,
+		LastTimestamp:  at,
+	}
+}
+
+func TestSummarizePod(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "cs-demo-abc"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init-home", Image: "busybox"}},
+			Containers:     []corev1.Container{{Name: "ide", Image: "jupyter"}, {Name: "postgres", Image: "postgres:16"}},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name: "init-home", RestartCount: 2,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}},
+			}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "ide", Ready: true, RestartCount: 1, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{
+					Name: "postgres", RestartCount: 4,
+					State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}},
+				},
+			},
+		},
+	}
+
+	got := summarizePod(pod)
+	if got.Restarts != 7 {
+		t.Errorf("Restarts = %d, want the init and regular containers' 7", got.Restarts)
+	}
+	if got.Ready {
+		t.Error("Ready = true for a pod whose Ready condition is False")
+	}
+	want := []ContainerSummary{
+		{Name: "init-home", Image: "busybox", Init: true, RestartCount: 2, State: "terminated", Reason: "Completed"},
+		{Name: "ide", Image: "jupyter", Ready: true, RestartCount: 1, State: "running"},
+		{Name: "postgres", Image: "postgres:16", RestartCount: 4, State: "waiting", Reason: "CrashLoopBackOff", LastTermination: "OOMKilled"},
+	}
+	if len(got.Containers) != len(want) {
+		t.Fatalf("Containers = %+v, want %+v", got.Containers, want)
+	}
+	for i := range want {
+		if got.Containers[i] != want[i] {
+			t.Errorf("Containers[%d] = %+v, want %+v", i, got.Containers[i], want[i])
+		}
+	}
+}
+
+func TestSummarizeDeploymentRolloutComplete(t *testing.T) {
+	deployment := func(replicas *int32, generation, observed int64, status appsv1.DeploymentStatus) *appsv1.Deployment {
+		status.ObservedGeneration = observed
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "cs-demo", Generation: generation},
+			Spec:       appsv1.DeploymentSpec{Replicas: replicas},
+			Status:     status,
+		}
+	}
+	done := appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: 1, AvailableReplicas: 1}
+	for _, tc := range []struct {
+		name string
+		d    *appsv1.Deployment
+		want bool
+	}{
+		{"complete", deployment(ptr.To[int32](1), 2, 2, done), true},
+		{"replicas default to 1", deployment(nil, 2, 2, done), true},
+		{"spec not observed yet", deployment(ptr.To[int32](1), 3, 2, done), false},
+		{"old pod still running", deployment(ptr.To[int32](1), 2, 2,
+			appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1}), false},
+		{"new pod not updated", deployment(ptr.To[int32](1), 2, 2,
+			appsv1.DeploymentStatus{Replicas: 1, AvailableReplicas: 1}), false},
+		{"new pod not available", deployment(ptr.To[int32](1), 2, 2,
+			appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, UnavailableReplicas: 1}), false},
+		{"suspended", deployment(ptr.To[int32](0), 2, 2, appsv1.DeploymentStatus{}), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := summarizeDeployment(tc.d).RolloutComplete; got != tc.want {
+				t.Fatalf("RolloutComplete = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildSessionDetails(t *testing.T) {
+	session := &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "team", UID: "demo-uid"}}
+	child := func(name string, owned bool) metav1.ObjectMeta {
+		meta := metav1.ObjectMeta{Name: name, Namespace: "team", Labels: map[string]string{codespacev1.SessionNameLabel: "demo"}}
+		if owned {
+			meta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(session, codespacev1.GroupVersion.WithKind("Session"))}
+		}
+		return meta
+	}
+	objs := []client.Object{
+		// Copies of the label on objects the session does not control sort first
+		&appsv1.Deployment{ObjectMeta: child("a-impostor", false)},
+		&appsv1.Deployment{ObjectMeta: child("cs-demo", true)},
+		&corev1.Service{ObjectMeta: child("a-impostor", false)},
+		&corev1.Service{ObjectMeta: child("cs-demo", true)},
+		&netv1.Ingress{ObjectMeta: child("a-impostor", false)},
+		&netv1.Ingress{ObjectMeta: child("cs-demo", true)},
+		&corev1.Pod{ObjectMeta: child("cs-demo-b", false)},
+		&corev1.Pod{ObjectMeta: child("cs-demo-a", false)},
+	}
+	for i := range 25 {
+		objs = append(objs, testEvent("team", "Session", "demo", "demo-uid", i))
+	}
+	objs = append(objs, testEvent("team", "Pod", "cs-demo-a", "", 30), testEvent("team", "Session", "other", "", 40))
+	h := &handlers{deps: &serverDeps{client: newTestClient(t, objs...)}}
+
+	for _, tc := range []struct {
+		name       string
+		eventLimit int
+		wantEvents int
+		wantFirst  string
+	}{
+		{"default limit", defaultDetailsEventLimit, 20, "Reason6"},
+		{"small limit", 3, 3, "Reason23"},
+		{"no limit", 0, 26, "Reason0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			details, err := h.buildSessionDetails(context.Background(), session, tc.eventLimit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if details.Deployment == nil || details.Deployment.Name != "cs-demo" ||
+				details.Service == nil || details.Service.Name != "cs-demo" ||
+				details.Ingress == nil || details.Ingress.Name != "cs-demo" {
+				t.Fatalf("children = %+v, %+v, %+v; want the controlled cs-demo", details.Deployment, details.Service, details.Ingress)
+			}
+			if len(details.Pods) != 2 || details.Pods[0].Name != "cs-demo-a" {
+				t.Fatalf("pods = %+v, want both sorted by name", details.Pods)
+			}
+			events := details.Events
+			if len(events) != tc.wantEvents || events[0].Reason != tc.wantFirst || events[len(events)-1].Reason != "Reason30" {
+				t.Fatalf("got %d events from %s to %s, want %d from %s to Reason30",
+					len(events), events[0].Reason, events[len(events)-1].Reason, tc.wantEvents, tc.wantFirst)
+			}
+		})
+	}
+}
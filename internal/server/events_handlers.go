@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SessionEvent is a trimmed-down Kubernetes Event for the UI
+// @Description Kubernetes event recorded for a session or one of its child resources
+type SessionEvent struct {
+	Type      string    `json:"type" example:"Warning"`
+	Reason    string    `json:"reason" example:"DeploymentFailed"`
+	Message   string    `json:"message" example:"deployment: Apply failed with 1 conflict"`
+	Count     int32     `json:"count" example:"1"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Source    string    `json:"source,omitempty" example:"session-controller"`
+	Object    string    `json:"object" example:"Session/my-session"`
+}
+
+// SessionEventsResponse wraps the events of a session
+// @Description Events recorded for a session, oldest first
+type SessionEventsResponse struct {
+	Items []SessionEvent `json:"items"`
+	Total int            `json:"total" example:"3"`
+}
+
+// @Summary List session events
+// @ID listSessionEvents
+// @Description Get the Kubernetes events recorded against a session (phase changes, child resource failures, deletion steps)
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Param limit query integer false "Only return the most recent N events"
+// @Success 200 {object} SessionEventsResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/events [get]
+func (h *handlers) handleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := sessionPathParts(r)
+	if len(parts) < 3 || parts[2] != "events" {
+		http.Error(w, "invalid path - expected /api/v1/server/sessions/{namespace}/{name}/events", http.StatusBadRequest)
+		return
+	}
+	namespace, name := parts[0], parts[1]
+
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "get", namespace)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	session, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		logger.Error("Failed to get session for events", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		writeSessionLookupError(w, err)
+		return
+	}
+
+	events, err := h.listEventsFor(r.Context(), namespace, "Session", name, session.UID)
+	if err != nil {
+		logger.Error("Failed to list session events", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		errJSON(w, fmt.Errorf("failed to list events: %w", err))
+		return
+	}
+	sortEvents(events)
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+
+	writeJSON(w, SessionEventsResponse{Items: events, Total: len(events)})
+}
+
+// listEventsFor returns the events whose involvedObject matches the given object.
+// An empty uid matches every incarnation of the object name.
+func (h *handlers) listEventsFor(ctx context.Context, namespace, kind, name string, uid types.UID) ([]SessionEvent, error) {
+	fields := client.MatchingFields{
+		"involvedObject.kind": kind,
+		"involvedObject.name": name,
+	}
+	if uid != "" {
+		fields["involvedObject.uid"] = string(uid)
+	}
+
+	var list corev1.EventList
+	if err := h.deps.client.List(ctx, &list, client.InNamespace(namespace), fields); err != nil {
+		return nil, err
+	}
+
+	out := make([]SessionEvent, 0, len(list.Items))
+	for i := range list.Items {
+		out = append(out, toSessionEvent(&list.Items[i]))
+	}
+	return out, nil
+}
+
+func toSessionEvent(ev *corev1.Event) SessionEvent {
+	first, last := ev.FirstTimestamp.Time, ev.LastTimestamp.Time
+	if first.IsZero() {
+		first = ev.EventTime.Time
+	}
+	if last.IsZero() {
+		last = first
+	}
+	if ev.Series != nil && ev.Series.LastObservedTime.After(last) {
+		last = ev.Series.LastObservedTime.Time
+	}
+
+	count := ev.Count
+	if ev.Series != nil && ev.Series.Count > count {
+		count = ev.Series.Count
+	}
+	if count == 0 {
+		count = 1
+	}
+
+	source := ev.Source.Component
+	if source == "" {
+		source = ev.ReportingController
+	}
+
+	return SessionEvent{
+		Type:      ev.Type,
+		Reason:    ev.Reason,
+		Message:   ev.Message,
+		Count:     count,
+		FirstSeen: first,
+		LastSeen:  last,
+		Source:    source,
+		Object:    ev.InvolvedObject.Kind + "/" + ev.InvolvedObject.Name,
+	}
+}
+
+// sortEvents orders events oldest first, like `kubectl describe`.
+func sortEvents(events []SessionEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastSeen.Before(events[j].LastSeen)
+	})
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codespace-operator/common/common/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+func TestToSessionEvent(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2025, 1, 1, 0, minute, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		name        string
+		ev          corev1.Event
+		first, last time.Time
+		count       int32
+		source      string
+	}{
+		{"core event", corev1.Event{
+			FirstTimestamp: metav1.NewTime(at(1)), LastTimestamp: metav1.NewTime(at(5)), Count: 3,
+			Source: corev1.EventSource{Component: "session-controller"},
+		}, at(1), at(5), 3, "session-controller"},
+		{"events.k8s.io event", corev1.Event{
+			EventTime:           metav1.NewMicroTime(at(2)),
+			ReportingController: "kubelet",
+		}, at(2), at(2), 1, "kubelet"},
+		{"series", corev1.Event{
+			EventTime: metav1.NewMicroTime(at(2)),
+			Series:    &corev1.EventSeries{Count: 7, LastObservedTime: metav1.NewMicroTime(at(9))},
+		}, at(2), at(9), 7, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.ev.InvolvedObject = corev1.ObjectReference{Kind: "Session", Name: "demo"}
+			got := toSessionEvent(&tc.ev)
+			if !got.FirstSeen.Equal(tc.first) || !got.LastSeen.Equal(tc.last) || got.Count != tc.count ||
+				got.Source != tc.source || got.Object != "Session/demo" {
+				t.Fatalf("toSessionEvent() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestHandleSessionEvents(t *testing.T) {
+	session := &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{
+		Name: "demo", Namespace: "team", UID: "demo-uid",
+		Labels: map[string]string{common.InstanceIDLabel: "test"},
+	}}
+	objs := []client.Object{
+		session,
+		testEvent("team", "Session", "demo", "demo-uid", 3),
+		testEvent("team", "Session", "demo", "demo-uid", 1),
+		testEvent("team", "Session", "demo", "demo-uid", 2),
+		// A deleted session of the same name, and another object
+		testEvent("team", "Session", "demo", "old-uid", 0),
+		testEvent("team", "Deployment", "demo", "", 4),
+	}
+	const policy = "p, viewer, session, get, team, allow\n"
+
+	for _, tc := range []struct {
+		name       string
+		path       string
+		wantCode   int
+		wantEvents []string
+	}{
+		{"oldest first", "/api/v1/server/sessions/team/demo/events", http.StatusOK, []string{"Reason1", "Reason2", "Reason3"}},
+		{"most recent", "/api/v1/server/sessions/team/demo/events?limit=2", http.StatusOK, []string{"Reason2", "Reason3"}},
+		{"invalid limit", "/api/v1/server/sessions/team/demo/events?limit=-1", http.StatusBadRequest, nil},
+		{"missing session", "/api/v1/server/sessions/team/gone/events", http.StatusNotFound, nil},
+		{"other namespace", "/api/v1/server/sessions/other/demo/events", http.StatusForbidden, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHandlers(t, policy, objs...)
+			rec := httptest.NewRecorder()
+			h.handleSessionEvents(rec, requestAs(http.MethodGet, tc.path, "local:bob", "viewer"))
+			if rec.Code != tc.wantCode {
+				t.Fatalf("status %d %q, want %d", rec.Code, rec.Body.String(), tc.wantCode)
+			}
+			if tc.wantEvents == nil {
+				return
+			}
+			var resp SessionEventsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			reasons := []string{}
+			for _, ev := range resp.Items {
+				reasons = append(reasons, ev.Reason)
+			}
+			if resp.Total != len(tc.wantEvents) || len(reasons) != len(tc.wantEvents) {
+				t.Fatalf("events = %v (total %d), want %v", reasons, resp.Total, tc.wantEvents)
+			}
+			for i := range reasons {
+				if reasons[i] != tc.wantEvents[i] {
+					t.Fatalf("events = %v, want %v", reasons, tc.wantEvents)
+				}
+			}
+		})
+	}
+}
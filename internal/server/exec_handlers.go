@@ -0,0 +1,379 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// defaultExecCommand starts bash when the image has it and falls back to sh.
+var defaultExecCommand = []string{"/bin/sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash -l || exec sh"}
+
+const (
+	execPingInterval = 30 * time.Second
+	execWriteWait    = 10 * time.Second
+)
+
+// execMessage is the JSON envelope exchanged over the exec WebSocket.
+//
+// Client -> server (text frames):
+//
+//	{"op":"stdin","data":"ls -la\r"}
+//	{"op":"resize","cols":120,"rows":40}
+//
+// Server -> client: terminal output as binary frames, then a final text frame
+//
+//	{"op":"exit","code":0}
+type execMessage struct {
+	Op    string `json:"op"`
+	Data  string `json:"data,omitempty"`
+	Cols  uint16 `json:"cols,omitempty"`
+	Rows  uint16 `json:"rows,omitempty"`
+	Code  int    `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// @Summary Open a terminal in a session
+// @ID execSession
+// @Description Upgrade to a WebSocket and attach an interactive shell to a session container (defaults to the ide container).
+// @Description Client frames are JSON: {"op":"stdin","data":"..."} and {"op":"resize","cols":N,"rows":N}. Output is sent as binary frames,
+// @Description followed by a final {"op":"exit","code":N} text frame. Browsers authenticate with the session cookie or, when enabled, the access_token query parameter.
+// @Tags sessions
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Param pod query string false "Pod name (defaults to the newest running pod of the session)"
+// @Param container query string false "Container name" default(ide)
+// @Param command query []string false "Command to run instead of the default login shell" collectionFormat(multi)
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/exec [get]
+func (h *handlers) handleSessionExec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !websocket.IsWebSocketUpgrade(r) {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+
+	parts := sessionPathParts(r)
+	if len(parts) < 3 || parts[2] != "exec" {
+		http.Error(w, "invalid path - expected /api/v1/server/sessions/{namespace}/{name}/exec", http.StatusBadRequest)
+		return
+	}
+	namespace, name := parts[0], parts[1]
+
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "exec", namespace)
+	if !ok {
+		return
+	}
+
+	session, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		logger.Error("Failed to get session for exec", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		writeSessionLookupError(w, err)
+		return
+	}
+
+	pod, err := h.resolveSessionPod(r.Context(), session, r.URL.Query().Get("pod"))
+	if err != nil {
+		if errors.Is(err, errNoSessionPod) || apierrors.IsNotFound(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		errJSON(w, fmt.Errorf("failed to find session pod: %w", err))
+		return
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		http.Error(w, fmt.Sprintf("pod %s is %s, not Running", pod.Name, pod.Status.Phase), http.StatusConflict)
+		return
+	}
+
+	container := q(r, "container", defaultSessionContainer)
+	found := false
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container {
+			found = true
+			break
+		}
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("container %q not found in pod %s", container, pod.Name), http.StatusBadRequest)
+		return
+	}
+
+	command := r.URL.Query()["command"]
+	if len(command) == 0 {
+		command = defaultExecCommand
+	}
+
+	executor, err := h.newPodExecutor(namespace, pod.Name, &corev1.PodExecOptions{
+		Container: container,
+		Command:   command,
+		Stdin:     true,
+		Stdout:    true,
+		Stderr:    false, // merged into stdout by the TTY
+		TTY:       true,
+	})
+	if err != nil {
+		errJSON(w, fmt.Errorf("failed to create executor: %w", err))
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin:     h.checkWebSocketOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already wrote the HTTP error
+		logger.Warn("WebSocket upgrade failed", "err", err, "user", pr.Subject)
+		return
+	}
+	defer conn.Close()
+	defer trackStream("exec")()
+
+	start := time.Now()
+	auditAttrs := []any{
+		"namespace", namespace,
+		"session", name,
+		"pod", pod.Name,
+		"container", container,
+		"command", strings.Join(command, " "),
+	}
+	h.audit(r, "session.exec.start", auditAttrs...)
+	h.recordSessionEvent(r.Context(), session, corev1.EventTypeNormal, "ExecStarted",
+		fmt.Sprintf("Terminal opened in %s/%s by %s", pod.Name, container, pr.Subject))
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	term := newWSTerminal(conn, cancel)
+	go term.readLoop()
+	go term.pingLoop(ctx)
+
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:             term.stdin,
+		Stdout:            term,
+		Tty:               true,
+		TerminalSizeQueue: term,
+	})
+
+	code := 0
+	msg := execMessage{Op: "exit"}
+	var exitErr utilexec.CodeExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitStatus()
+	case errors.Is(err, context.Canceled):
+		// client went away
+	default:
+		code = 1
+		msg.Error = err.Error()
+		logger.Warn("Exec stream failed", "pod", pod.Name, "container", container, "err", err, "user", pr.Subject)
+	}
+	msg.Code = code
+	term.writeJSON(msg)
+	_ = term.writeControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+
+	h.audit(r, "session.exec.end", append(auditAttrs, "exit_code", code, "duration", time.Since(start).Round(time.Second).String())...)
+	h.recordSessionEvent(context.Background(), session, corev1.EventTypeNormal, "ExecEnded",
+		fmt.Sprintf("Terminal in %s/%s closed by %s (exit code %d)", pod.Name, container, pr.Subject, code))
+}
+
+// newPodExecutor builds an executor for the pod exec subresource. It speaks the
+// WebSocket exec protocol and falls back to SPDY for API servers that lack it.
+func (h *handlers) newPodExecutor(namespace, pod string, opts *corev1.PodExecOptions) (remotecommand.Executor, error) {
+	if h.deps.restConfig == nil {
+		return nil, errors.New("exec is not available: no Kubernetes REST config")
+	}
+	req := h.deps.kube.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("pods").
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(opts, scheme.ParameterCodec)
+
+	wsExec, err := remotecommand.NewWebSocketExecutor(h.deps.restConfig, http.MethodGet, req.URL().String())
+	if err != nil {
+		return nil, err
+	}
+	spdyExec, err := remotecommand.NewSPDYExecutor(h.deps.restConfig, http.MethodPost, req.URL())
+	if err != nil {
+		return nil, err
+	}
+	return remotecommand.NewFallbackExecutor(wsExec, spdyExec, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	})
+}
+
+// checkWebSocketOrigin rejects cross-site upgrades. Browsers send cookies on
+// WebSocket handshakes regardless of CORS, so the Origin must be our own host or
+// one listed in allow_origin.
+func (h *handlers) checkWebSocketOrigin(r *http.Request) bool {
+	return websocketOriginAllowed(r.Header.Get("Origin"), r.Host, h.deps.config.AllowedOrigins())
+}
+
+// websocketOriginAllowed matches origin against host and the allowed origins.
+// A "*" never matches: a credentialed upgrade is as good as the user's session.
+func websocketOriginAllowed(origin, host string, allowed []string) bool {
+	if origin == "" {
+		return true // non-browser client
+	}
+	for _, a := range allowed {
+		if a != "*" && strings.EqualFold(a, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, host)
+}
+
+// wsTerminal adapts a WebSocket connection to the stdin/stdout/resize streams
+// expected by remotecommand.
+type wsTerminal struct {
+	conn   *websocket.Conn
+	cancel context.CancelFunc
+
+	stdin   *io.PipeReader
+	stdinW  *io.PipeWriter
+	resizes chan remotecommand.TerminalSize
+
+	writeMu sync.Mutex
+}
+
+func newWSTerminal(conn *websocket.Conn, cancel context.CancelFunc) *wsTerminal {
+	pr, pw := io.Pipe()
+	return &wsTerminal{
+		conn:    conn,
+		cancel:  cancel,
+		stdin:   pr,
+		stdinW:  pw,
+		resizes: make(chan remotecommand.TerminalSize, 4),
+	}
+}
+
+// readLoop consumes client frames until the socket closes, then ends the exec.
+func (t *wsTerminal) readLoop() {
+	defer func() {
+		_ = t.stdinW.Close()
+		close(t.resizes)
+		t.cancel()
+	}()
+
+	t.conn.SetPongHandler(func(string) error {
+		return t.conn.SetReadDeadline(time.Now().Add(2 * execPingInterval))
+	})
+	_ = t.conn.SetReadDeadline(time.Now().Add(2 * execPingInterval))
+
+	for {
+		mt, data, err := t.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = t.conn.SetReadDeadline(time.Now().Add(2 * execPingInterval))
+
+		if mt == websocket.BinaryMessage {
+			// raw keystrokes
+			if _, err := t.stdinW.Write(data); err != nil {
+				return
+			}
+			continue
+		}
+
+		var msg execMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Op {
+		case "stdin":
+			if _, err := t.stdinW.Write([]byte(msg.Data)); err != nil {
+				return
+			}
+		case "resize":
+			if msg.Cols == 0 || msg.Rows == 0 {
+				continue
+			}
+			select {
+			case t.resizes <- remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows}:
+			default:
+				// drop bursts; the next resize wins
+			}
+		}
+	}
+}
+
+func (t *wsTerminal) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(execPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.writeControl(websocket.PingMessage, nil); err != nil {
+				t.cancel()
+				return
+			}
+		}
+	}
+}
+
+// Write sends terminal output to the client as a binary frame.
+func (t *wsTerminal) Write(p []byte) (int, error) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_ = t.conn.SetWriteDeadline(time.Now().Add(execWriteWait))
+	if err := t.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Next implements remotecommand.TerminalSizeQueue.
+func (t *wsTerminal) Next() *remotecommand.TerminalSize {
+	size, ok := <-t.resizes
+	if !ok {
+		return nil
+	}
+	return &size
+}
+
+func (t *wsTerminal) writeJSON(v any) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_ = t.conn.SetWriteDeadline(time.Now().Add(execWriteWait))
+	_ = t.conn.WriteJSON(v)
+}
+
+func (t *wsTerminal) writeControl(messageType int, data []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	return t.conn.WriteControl(messageType, data, time.Now().Add(execWriteWait))
+}
@@ -0,0 +1,27 @@
+package server
+
+import "testing"
+
+func TestWebsocketOriginAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		origin  string
+		allowed []string
+		want    bool
+	}{
+		{"no origin", "", nil, true},
+		{"same host", "https://codespace.test", nil, true},
+		{"same host case", "https://CodeSpace.test", nil, true},
+		{"cross site", "https://evil.test", nil, false},
+		{"wildcard", "https://evil.test", []string{"*"}, false},
+		{"listed", "https://ui.test", []string{"*", "https://UI.test"}, true},
+		{"unlisted", "https://evil.test", []string{"https://ui.test"}, false},
+		{"bad origin", "://", nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := websocketOriginAllowed(tc.origin, "codespace.test", tc.allowed); got != tc.want {
+				t.Fatalf("websocketOriginAllowed(%q) = %v, want %v", tc.origin, got, tc.want)
+			}
+		})
+	}
+}
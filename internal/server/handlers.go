@@ -93,6 +93,18 @@ func (h *handlers) handleReadyz(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
+	// Without the cache, sessions are served from the API server, which is
+	// checked below
+	status := "ready"
+	if hub := h.deps.sessions; hub != nil && !hub.Synced() {
+		if !hub.Fallback() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("not ready: session cache not synced: " + hub.NotSyncedReason()))
+			return
+		}
+		status += " (session cache unavailable, reading from the API server: " + hub.NotSyncedReason() + ")"
+	}
+
 	readyCtx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
 
@@ -105,7 +117,7 @@ func (h *handlers) handleReadyz(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("ready"))
+	_, _ = w.Write([]byte(status))
 }
 
 // handleAdminUsers - GET /api/v1/admin/users (requires admin privileges)
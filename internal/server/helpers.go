@@ -21,6 +21,7 @@ import (
 	"github.com/codespace-operator/common/common/pkg/common"
 	rbac "github.com/codespace-operator/common/rbac/pkg/rbac"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
@@ -32,6 +33,15 @@ func writeJSON(w http.ResponseWriter, v any) {
 }
 
 func writeSSE(w http.ResponseWriter, event string, v any) {
+	writeSSEWithID(w, "", event, v)
+}
+
+// writeSSEWithID writes an event with an id: line, which EventSource sends back
+// as Last-Event-ID when it reconnects. An empty id is omitted.
+func writeSSEWithID(w http.ResponseWriter, id, event string, v any) {
+	if id != "" {
+		_, _ = w.Write([]byte("id: " + id + "\n"))
+	}
 	_, _ = w.Write([]byte("event: " + event + "\n"))
 	b, _ := json.Marshal(v)
 	_, _ = w.Write([]byte("data: " + string(b) + "\n\n"))
@@ -86,6 +96,33 @@ func splitCSVQuery(s string) []string {
 	return out
 }
 
+// sessionPathParts splits /api/v1/server/sessions/{namespace}/{name}[/sub[/...]] into its parts.
+func sessionPathParts(r *http.Request) []string {
+	return strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/server/sessions/"), "/")
+}
+
+// getScopedSession fetches a session and reports it as not found when it belongs
+// to another server instance (unless running cluster-scoped).
+func (h *handlers) getScopedSession(ctx context.Context, namespace, name string) (*codespacev1.Session, error) {
+	var session codespacev1.Session
+	if err := h.deps.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &session); err != nil {
+		return nil, err
+	}
+	if session.Labels[common.InstanceIDLabel] != h.deps.instanceID && !h.deps.config.ClusterScope {
+		return nil, apierrors.NewNotFound(gvr.GroupResource(), name)
+	}
+	return &session, nil
+}
+
+// writeSessionLookupError maps getScopedSession errors onto HTTP responses.
+func writeSessionLookupError(w http.ResponseWriter, err error) {
+	if apierrors.IsNotFound(err) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	errJSON(w, fmt.Errorf("failed to get session: %w", err))
+}
+
 // extractNamespaceFromRequest extracts namespace from request path or query parameters
 func (h *handlers) extractNamespaceFromRequest(r *http.Request) string {
 	// Try query parameter first
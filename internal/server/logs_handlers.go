@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// defaultSessionContainer is the IDE container rendered by the controller.
+const defaultSessionContainer = "ide"
+
+// errNoSessionPod is returned when a session currently has no pods (e.g. it is suspended).
+var errNoSessionPod = errors.New("session has no pods")
+
+// @Summary Stream session logs
+// @ID streamSessionLogs
+// @Description Stream container logs of a session pod as chunked text, or as Server-Sent Events when the client accepts text/event-stream
+// @Tags sessions
+// @Produce text/plain
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Param pod query string false "Pod name (defaults to the newest running pod of the session)"
+// @Param container query string false "Container name, e.g. ide, oauth2-proxy or an init container" default(ide)
+// @Param follow query boolean false "Keep streaming new log lines"
+// @Param tailLines query integer false "Number of lines from the end of the log to show"
+// @Param sinceSeconds query integer false "Only return logs newer than this many seconds"
+// @Param previous query boolean false "Return logs of the previous (crashed) container instance"
+// @Param timestamps query boolean false "Prefix every line with its RFC3339 timestamp"
+// @Success 200 {string} string "Log stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/logs [get]
+func (h *handlers) handleSessionLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := sessionPathParts(r)
+	if len(parts) < 3 || parts[2] != "logs" {
+		http.Error(w, "invalid path - expected /api/v1/server/sessions/{namespace}/{name}/logs", http.StatusBadRequest)
+		return
+	}
+	namespace, name := parts[0], parts[1]
+
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "logs", namespace)
+	if !ok {
+		return
+	}
+
+	opts, err := parseLogOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		logger.Error("Failed to get session for logs", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		writeSessionLookupError(w, err)
+		return
+	}
+
+	pod, err := h.resolveSessionPod(r.Context(), session, r.URL.Query().Get("pod"))
+	if err != nil {
+		if errors.Is(err, errNoSessionPod) || apierrors.IsNotFound(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		errJSON(w, fmt.Errorf("failed to find session pod: %w", err))
+		return
+	}
+	if !podHasContainer(pod, opts.Container) {
+		http.Error(w, fmt.Sprintf("container %q not found in pod %s", opts.Container, pod.Name), http.StatusBadRequest)
+		return
+	}
+
+	stream, err := h.deps.kube.CoreV1().Pods(namespace).GetLogs(pod.Name, opts).Stream(r.Context())
+	if err != nil {
+		logger.Error("Failed to open log stream", "pod", pod.Name, "container", opts.Container, "err", err, "user", pr.Subject)
+		if apierrors.IsBadRequest(err) {
+			// e.g. previous=true without a previous container, or container still creating
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		errJSON(w, fmt.Errorf("failed to stream logs: %w", err))
+		return
+	}
+	defer stream.Close()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sse := wantsSSE(r)
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Connection", "keep-alive")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	logger.Info("Started log stream", "namespace", namespace, "name", name, "pod", pod.Name,
+		"container", opts.Container, "follow", opts.Follow, "user", pr.Subject)
+	defer trackStream("logs")()
+
+	if sse {
+		writeSSE(w, "ping", map[string]string{"status": "connected", "pod": pod.Name, "container": opts.Container})
+		flusher.Flush()
+	}
+
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readLines(r.Context(), stream, lines)
+	}()
+
+	ticker := time.NewTicker(25 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.Debug("Log stream ended by client", "pod", pod.Name, "user", pr.Subject)
+			return
+		case <-ticker.C:
+			if sse {
+				writeSSE(w, "ping", map[string]string{"timestamp": time.Now().Format(time.RFC3339)})
+				flusher.Flush()
+			}
+		case line := <-lines:
+			if sse {
+				writeSSE(w, "log", map[string]string{"line": line})
+			} else {
+				_, _ = io.WriteString(w, line+"\n")
+			}
+			flusher.Flush()
+		case err := <-readErr:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logger.Warn("Log stream interrupted", "pod", pod.Name, "err", err, "user", pr.Subject)
+			}
+			if sse {
+				writeSSE(w, "end", map[string]string{"pod": pod.Name, "container": opts.Container})
+				flusher.Flush()
+			}
+			return
+		}
+	}
+}
+
+// parseLogOptions maps the query string onto PodLogOptions.
+func parseLogOptions(r *http.Request) (*corev1.PodLogOptions, error) {
+	qs := r.URL.Query()
+	opts := &corev1.PodLogOptions{
+		Container:  q(r, "container", defaultSessionContainer),
+		Follow:     parseBool(qs.Get("follow")),
+		Previous:   parseBool(qs.Get("previous")),
+		Timestamps: parseBool(qs.Get("timestamps")),
+	}
+	if v := qs.Get("tailLines"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("tailLines must be a non-negative integer")
+		}
+		opts.TailLines = &n
+	}
+	if v := qs.Get("sinceSeconds"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("sinceSeconds must be a positive integer")
+		}
+		opts.SinceSeconds = &n
+	}
+	return opts, nil
+}
+
+// resolveSessionPod returns the named pod if it belongs to the session, otherwise
+// the newest running pod of the session (falling back to the newest pod at all).
+func (h *handlers) resolveSessionPod(ctx context.Context, session *codespacev1.Session, podName string) (*corev1.Pod, error) {
+	if podName != "" {
+		var pod corev1.Pod
+		if err := h.deps.client.Get(ctx, client.ObjectKey{Namespace: session.Namespace, Name: podName}, &pod); err != nil {
+			return nil, err
+		}
+		if pod.Labels[codespacev1.SessionNameLabel] != session.Name {
+			return nil, apierrors.NewNotFound(corev1.Resource("pods"), podName)
+		}
+		return &pod, nil
+	}
+
+	var pods corev1.PodList
+	if err := h.deps.client.List(ctx, &pods,
+		client.InNamespace(session.Namespace),
+		client.MatchingLabels{codespacev1.SessionNameLabel: session.Name},
+	); err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, errNoSessionPod
+	}
+
+	items := pods.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		ri, rj := items[i].Status.Phase == corev1.PodRunning, items[j].Status.Phase == corev1.PodRunning
+		if ri != rj {
+			return ri
+		}
+		return items[j].CreationTimestamp.Before(&items[i].CreationTimestamp)
+	})
+	return &items[0], nil
+}
+
+func podHasContainer(pod *corev1.Pod, name string) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readLines forwards every line of rd to out until EOF or ctx is done.
+func readLines(ctx context.Context, rd io.Reader, out chan<- string) error {
+	br := bufio.NewReader(rd)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			select {
+			case out <- strings.TrimRight(line, "\r\n"):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func wantsSSE(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func parseBool(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b || v == "1"
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	auth "github.com/codespace-operator/common/auth/pkg/auth"
+	"github.com/codespace-operator/common/common/pkg/common"
+	rbac "github.com/codespace-operator/common/rbac/pkg/rbac"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// newTestHandlers returns handlers for this server instance ("test") that
+// authorize with policy and read objs from a fake client.
+func newTestHandlers(t *testing.T, policy string, objs ...client.Object) *handlers {
+	t.Helper()
+	enf := testEnforcer(t, policy)
+	return &handlers{deps: &serverDeps{
+		client:     newTestClient(t, objs...),
+		kube:       kubefake.NewClientset(),
+		config:     &ServerConfig{},
+		rbac:       enf,
+		rbacMw:     rbac.NewMiddleware(enf, ExtractFromAuth, slog.Default()),
+		instanceID: "test",
+		logger:     slog.Default(),
+	}}
+}
+
+// requestAs is a request by an authenticated user.
+func requestAs(method, target, subject string, roles ...string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	return r.WithContext(auth.WithClaims(r.Context(), &auth.TokenClaims{Sub: subject, Roles: roles}))
+}
+
+// testSessionPod is a pod of session in phase, created minute minutes into 2025.
+func testSessionPod(ns, name, session string, phase corev1.PodPhase, minute int) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns, Name: name,
+			Labels:            map[string]string{codespacev1.SessionNameLabel: session},
+			CreationTimestamp: metav1.NewTime(time.Date(2025, 1, 1, 0, minute, 0, 0, time.UTC)),
+		},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init-home"}},
+			Containers:     []corev1.Container{{Name: "ide"}, {Name: "postgres"}},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestResolveSessionPod(t *testing.T) {
+	session := &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "team"}}
+	running := testSessionPod("team", "cs-demo-running", "demo", corev1.PodRunning, 1)
+	pending := testSessionPod("team", "cs-demo-pending", "demo", corev1.PodPending, 2)
+	older := testSessionPod("team", "cs-demo-older", "demo", corev1.PodRunning, 0)
+	other := testSessionPod("team", "cs-other", "other", corev1.PodRunning, 3)
+
+	for _, tc := range []struct {
+		name    string
+		objs    []client.Object
+		podName string
+		want    string
+		wantErr func(error) bool
+	}{
+		{"newest running pod", []client.Object{older, running, pending, other}, "", "cs-demo-running", nil},
+		{"newest pod when none runs", []client.Object{pending, other}, "", "cs-demo-pending", nil},
+		{"named pod", []client.Object{older, running}, "cs-demo-older", "cs-demo-older", nil},
+		{"pod of another session", []client.Object{running, other}, "cs-other", "", apierrors.IsNotFound},
+		{"missing pod", []client.Object{running}, "cs-demo-gone", "", apierrors.IsNotFound},
+		{"no pods", []client.Object{other}, "", "", func(err error) bool { return errors.Is(err, errNoSessionPod) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &handlers{deps: &serverDeps{client: newTestClient(t, tc.objs...)}}
+			pod, err := h.resolveSessionPod(context.Background(), session, tc.podName)
+			if tc.wantErr != nil {
+				if !tc.wantErr(err) {
+					t.Fatalf("err = %v", err)
+				}
+				return
+			}
+			if err != nil || pod.Name != tc.want {
+				t.Fatalf("resolveSessionPod() = %v, %v; want %s", pod, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleSessionLogs(t *testing.T) {
+	session := &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{
+		Name: "demo", Namespace: "team",
+		Labels: map[string]string{common.InstanceIDLabel: "test"},
+	}}
+	objs := []client.Object{
+		session,
+		testSessionPod("team", "cs-demo-old", "demo", corev1.PodRunning, 0),
+		testSessionPod("team", "cs-demo-new", "demo", corev1.PodRunning, 1),
+		testSessionPod("team", "cs-other", "other", corev1.PodRunning, 2),
+	}
+	const policy = "p, editor, session, logs, team, allow\n"
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		sse      bool
+		wantCode int
+		wantBody string
+		want     *corev1.PodLogOptions
+	}{
+		{"defaults", "/api/v1/server/sessions/team/demo/logs", false, http.StatusOK, "fake logs\n",
+			&corev1.PodLogOptions{Container: "ide"}},
+		{"container, previous and tail", "/api/v1/server/sessions/team/demo/logs?container=postgres&previous=true&tailLines=50",
+			false, http.StatusOK, "fake logs\n",
+			&corev1.PodLogOptions{Container: "postgres", Previous: true, TailLines: ptr.To[int64](50)}},
+		{"init container", "/api/v1/server/sessions/team/demo/logs?container=init-home&follow=true&timestamps=1",
+			false, http.StatusOK, "fake logs\n",
+			&corev1.PodLogOptions{Container: "init-home", Follow: true, Timestamps: true}},
+		{"newest pod over SSE", "/api/v1/server/sessions/team/demo/logs", true, http.StatusOK, `"pod":"cs-demo-new"`,
+			&corev1.PodLogOptions{Container: "ide"}},
+		{"named pod", "/api/v1/server/sessions/team/demo/logs?pod=cs-demo-old", true, http.StatusOK, `"pod":"cs-demo-old"`,
+			&corev1.PodLogOptions{Container: "ide"}},
+		{"pod of another session", "/api/v1/server/sessions/team/demo/logs?pod=cs-other", false, http.StatusNotFound, "", nil},
+		{"unknown container", "/api/v1/server/sessions/team/demo/logs?container=nope", false, http.StatusBadRequest, "not found", nil},
+		{"negative tail", "/api/v1/server/sessions/team/demo/logs?tailLines=-1", false, http.StatusBadRequest, "tailLines", nil},
+		{"missing session", "/api/v1/server/sessions/team/gone/logs", false, http.StatusNotFound, "", nil},
+		{"other namespace", "/api/v1/server/sessions/other/demo/logs", false, http.StatusForbidden, "", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHandlers(t, policy, objs...)
+			var got *corev1.PodLogOptions
+			h.deps.kube.(*kubefake.Clientset).PrependReactor("get", "pods", func(a k8stesting.Action) (bool, runtime.Object, error) {
+				if a.GetSubresource() == "log" {
+					got = a.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+				}
+				return false, nil, nil
+			})
+
+			r := requestAs(http.MethodGet, tc.path, "local:alice", "editor")
+			if tc.sse {
+				r.Header.Set("Accept", "text/event-stream")
+			}
+			rec := httptest.NewRecorder()
+			h.handleSessionLogs(rec, r)
+			if rec.Code != tc.wantCode || !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("got %d %q, want %d %q", rec.Code, rec.Body.String(), tc.wantCode, tc.wantBody)
+			}
+			if tc.want == nil {
+				if got != nil {
+					t.Fatalf("logs requested with %+v", got)
+				}
+				return
+			}
+			if got == nil || got.Container != tc.want.Container || got.Previous != tc.want.Previous ||
+				got.Follow != tc.want.Follow || got.Timestamps != tc.want.Timestamps ||
+				!ptr.Equal(got.TailLines, tc.want.TailLines) {
+				t.Fatalf("log options = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codespace-operator/common/common/pkg/common"
+	rbac "github.com/codespace-operator/common/rbac/pkg/rbac"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const serverMetricsNamespace = "codespace_server"
+
+// metricsRegistry holds the server's own metrics plus Go/process collectors.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: serverMetricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: serverMetricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method. Streams are measured until they close.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	authFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: serverMetricsNamespace,
+		Name:      "auth_failures_total",
+		Help:      "Failed authentications by provider (session = missing or invalid session token).",
+	}, []string{"provider"})
+
+	rbacDenialsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: serverMetricsNamespace,
+		Name:      "rbac_denials_total",
+		Help:      "Requests denied by RBAC by resource and action.",
+	}, []string{"resource", "action"})
+
+	activeStreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: serverMetricsNamespace,
+		Name:      "active_streams",
+		Help:      "Open long-lived connections by kind (sessions SSE, logs, exec).",
+	}, []string{"stream"})
+
+	kubeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: serverMetricsNamespace,
+		Name:      "kube_request_duration_seconds",
+		Help:      "Latency of Kubernetes API requests made by the server, until response headers.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"verb", "code"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		authFailuresTotal,
+		rbacDenialsTotal,
+		activeStreams,
+		kubeRequestDuration,
+	)
+}
+
+func metricsHandler(token string) http.Handler {
+	h := promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveMetrics serves /metrics on its own listener at addr. It blocks.
+func serveMetrics(addr, token string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(token))
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return srv.ListenAndServe()
+}
+
+// trackStream increments the active stream gauge and returns the matching decrement.
+func trackStream(kind string) func() {
+	g := activeStreams.WithLabelValues(kind)
+	g.Inc()
+	return g.Dec
+}
+
+// sessionOperations are the sub-resources served under /api/v1/server/sessions/{ns}/{name}/.
+// Anything else is collapsed into a single route label to bound cardinality.
+var sessionOperations = map[string]bool{
+	"scale":     true,
+	"events":    true,
+	"logs":      true,
+	"exec":      true,
+	"details":   true,
+	"clone":     true,
+	"snapshots": true,
+	"restore":   true,
+	"restart":   true,
+}
+
+// routeLabel maps a request onto the mux pattern it is served by, templating
+// the session path parameters.
+func routeLabel(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	switch pattern {
+	case "":
+		return "unmatched"
+	case "/":
+		return "/static"
+	case "/api/v1/server/sessions/":
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, pattern), "/"), "/")
+		switch {
+		case len(parts) == 2:
+			return pattern + "{namespace}/{name}"
+		case len(parts) == 3 && sessionOperations[parts[2]]:
+			return pattern + "{namespace}/{name}/" + parts[2]
+		default:
+			return pattern + "{path}"
+		}
+	case proxyPathPrefix:
+		return proxyPathPrefix + "{namespace}/{name}/{path}"
+	}
+	return pattern
+}
+
+// metricsMiddleware records request counts/latency and session-token auth failures.
+// It must sit outside AuthGate so rejected requests are counted.
+func metricsMiddleware(mux *http.ServeMux, authPath string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			route := routeLabel(mux, r)
+
+			rw := &common.ResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			code := rw.StatusCode()
+			httpRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(code)).Inc()
+			httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+
+			// Login endpoints count their own failures per provider
+			if code == http.StatusUnauthorized && !strings.HasPrefix(r.URL.Path, authPath) {
+				authFailuresTotal.WithLabelValues("session").Inc()
+			}
+		})
+	}
+}
+
+// countingEnforcer decorates the RBAC enforcer used by the request middleware so
+// that every denied MustCan is counted. Direct Enforce calls used to filter lists
+// go to the undecorated enforcer and are not counted as denials.
+type countingEnforcer struct {
+	rbac.RBACInterface
+}
+
+func (c countingEnforcer) Enforce(subject string, roles []string, resource, action, domain string) (bool, error) {
+	ok, err := c.RBACInterface.Enforce(subject, roles, resource, action, domain)
+	if err == nil && !ok {
+		rbacDenialsTotal.WithLabelValues(resource, action).Inc()
+	}
+	return ok, err
+}
+
+// kubeMetricsTransport measures Kubernetes API latency for the server's clients.
+type kubeMetricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t *kubeMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	kubeRequestDuration.WithLabelValues(req.Method, code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
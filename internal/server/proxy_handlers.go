@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+
+	auth "github.com/codespace-operator/common/auth/pkg/auth"
+	"github.com/codespace-operator/common/common/pkg/common"
+	rbac "github.com/codespace-operator/common/rbac/pkg/rbac"
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// proxyPathPrefix is where sessions are reverse-proxied, as /s/{namespace}/{name}/...
+const proxyPathPrefix = "/s/"
+
+// errNoSessionService is returned while the controller has not created the
+// session's Service yet.
+var errNoSessionService = errors.New("session service not found")
+
+// parseProxyPath splits /s/{namespace}/{name}/{rest} into its parts. rest keeps
+// its leading slash and is empty for /s/{namespace}/{name}.
+func parseProxyPath(p string) (namespace, name, rest string, ok bool) {
+	trimmed, found := strings.CutPrefix(p, proxyPathPrefix)
+	if !found {
+		return "", "", "", false
+	}
+	namespace, trimmed, _ = strings.Cut(trimmed, "/")
+	name, rest, hasRest := strings.Cut(trimmed, "/")
+	if namespace == "" || name == "" {
+		return "", "", "", false
+	}
+	if hasRest {
+		rest = "/" + rest
+	}
+	return namespace, name, rest, true
+}
+
+// handleSessionProxy reverse-proxies /s/{namespace}/{name}/... to the session's
+// Service, WebSocket upgrades included, so sessions are usable on clusters
+// without an ingress controller. See canConnect for who may open a session and
+// proxy_origin.go for the origin the session is served on. The proxy needs
+// session_proxy_domain and answers 501 Not Implemented without it.
+//
+// IDEs that serve under the proxy path themselves (see codespacev1.BasePathAnnotation)
+// get the full path; for the others the prefix is stripped, and redirects and
+// cookie paths in the response are moved under it.
+func (h *handlers) handleSessionProxy(w http.ResponseWriter, r *http.Request) {
+	namespace, name, rest, ok := parseProxyPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	domain := h.deps.config.SessionProxyDomain
+	if domain == "" {
+		http.Error(w, "the session proxy needs session_proxy_domain to be configured", http.StatusNotImplemented)
+		return
+	}
+	isolated := onSessionProxyDomain(domain, r.Host)
+	if isolated && !strings.EqualFold(r.Host, sessionProxyHost(domain, namespace, name)) {
+		http.NotFound(w, r)
+		return
+	}
+	base := codespacev1.ProxyPath(namespace, name)
+	if rest == "" {
+		target := base
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+
+	// Session hosts never see the server cookie; they have a login of their own
+	var claims *auth.TokenClaims
+	var err error
+	if isolated {
+		if ticket := r.URL.Query().Get(proxyTicketParam); ticket != "" {
+			h.redeemProxyTicket(w, r, namespace, name, ticket)
+			return
+		}
+		claims, err = h.proxyCookieClaims(r, namespace, name)
+	} else {
+		claims, err = h.deps.authManager.ValidateRequest(r)
+	}
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	r = r.WithContext(auth.WithClaims(r.Context(), claims))
+	pr, _ := ExtractFromAuth(r)
+	upgrade := websocket.IsWebSocketUpgrade(r)
+	if upgrade && !h.checkWebSocketOrigin(r) {
+		http.Error(w, "cross-origin websocket rejected", http.StatusForbidden)
+		return
+	}
+
+	session, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		writeSessionLookupError(w, err)
+		return
+	}
+	if ok, err := canConnect(h.deps.rbac, pr, session); err != nil || !ok {
+		if err != nil {
+			logger.Error("RBAC enforcement error", "err", err, "user", pr.Subject)
+		}
+		rbacDenialsTotal.WithLabelValues(SESSION_RESOURCE_STRING, "connect").Inc()
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	// oauth2-proxy would ask for a second login, for a callback on its own host
+	if session.Spec.Auth.Mode == "oauth2proxy" && strings.HasPrefix(session.Status.URL, "https://") {
+		http.Redirect(w, r, session.Status.URL, http.StatusTemporaryRedirect)
+		return
+	}
+	if !isolated {
+		h.redirectToProxyHost(w, r, claims, namespace, name)
+		return
+	}
+
+	svc, err := h.sessionService(r.Context(), session)
+	if err != nil {
+		logger.Warn("No service to proxy to", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		if errors.Is(err, errNoSessionService) {
+			http.Error(w, "session is not ready", http.StatusServiceUnavailable)
+			return
+		}
+		errJSON(w, fmt.Errorf("failed to find session service: %w", err))
+		return
+	}
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("%s.%s.svc:%d", svc.Name, svc.Namespace, servicePort(svc))}
+	strip := svc.Annotations[codespacev1.BasePathAnnotation] != base
+	prefix := strings.TrimSuffix(base, "/")
+
+	if upgrade {
+		h.audit(r, "session.connect", "namespace", namespace, "name", name, "path", rest)
+	}
+	// On its own host the IDE may frame itself
+	w.Header().Del("X-Frame-Options")
+
+	proxy := &httputil.ReverseProxy{
+		Transport: h.deps.proxyTransport,
+		Rewrite: func(p *httputil.ProxyRequest) {
+			p.SetURL(target)
+			// IDEs compare the WebSocket Origin with Host, so keep the session host
+			p.Out.Host = p.In.Host
+			p.SetXForwarded()
+			p.Out.Header.Set("X-Forwarded-Prefix", prefix)
+			if strip {
+				p.Out.URL.Path = rest
+				p.Out.URL.RawPath = strings.TrimPrefix(p.In.URL.EscapedPath(), prefix)
+			}
+			h.stripCredentials(p.Out)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.Header.Get("X-Frame-Options") == "" {
+				resp.Header.Set("X-Frame-Options", "SAMEORIGIN")
+			}
+			rewriteProxyResponse(resp, prefix, r.Host, strip,
+				h.deps.authManager.GetCookieName(), proxyCookieName)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.Warn("Session proxy failed", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+			http.Error(w, "session is not reachable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// canConnect reports whether pr may open the session through the proxy, which
+// gives full access to the IDE and its volumes. "connect" on sessions in the
+// namespace only covers the sessions pr created; other users' sessions need
+// "connect" on the session itself, i.e. on the object session/<name>.
+func canConnect(enf rbac.RBACInterface, pr *rbac.Principal, session *codespacev1.Session) (bool, error) {
+	if session.Annotations[common.AnnotationCreatedBy] == pr.Subject {
+		ok, err := enf.Enforce(pr.Subject, pr.Roles, SESSION_RESOURCE_STRING, "connect", session.Namespace)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return enf.Enforce(pr.Subject, pr.Roles, SESSION_RESOURCE_STRING+"/"+session.Name, "connect", session.Namespace)
+}
+
+// sessionService finds the Service the controller created for the session.
+func (h *handlers) sessionService(ctx context.Context, session *codespacev1.Session) (*corev1.Service, error) {
+	var list corev1.ServiceList
+	if err := h.deps.client.List(ctx, &list, client.InNamespace(session.Namespace),
+		client.MatchingLabels{codespacev1.SessionNameLabel: session.Name}); err != nil {
+		return nil, err
+	}
+	if svc := controlledBy(list.Items, session); svc != nil {
+		return svc, nil
+	}
+	return nil, errNoSessionService
+}
+
+// servicePort is the port of the Service's "http" port, where the IDE is served.
+func servicePort(svc *corev1.Service) int32 {
+	for _, p := range svc.Spec.Ports {
+		if p.Name == "http" {
+			return p.Port
+		}
+	}
+	return 80
+}
+
+// stripCredentials keeps the user's server and proxy credentials from reaching
+// the session.
+func (h *handlers) stripCredentials(out *http.Request) {
+	out.Header.Del("Authorization")
+	if cookies := out.Cookies(); len(cookies) > 0 {
+		out.Header.Del("Cookie")
+		for _, c := range cookies {
+			if c.Name != h.deps.authManager.GetCookieName() && c.Name != proxyCookieName {
+				out.AddCookie(c)
+			}
+		}
+	}
+	if q := out.URL.Query(); q.Has("access_token") || q.Has(proxyTicketParam) {
+		q.Del("access_token")
+		q.Del(proxyTicketParam)
+		out.URL.RawQuery = q.Encode()
+	}
+}
+
+// rewriteProxyResponse moves redirects of an upstream that serves at "/" under
+// prefix (strip) and scopes its cookies to prefix. Cookies named like one of
+// reserved are dropped, so a session cannot set or shadow the server's.
+func rewriteProxyResponse(resp *http.Response, prefix, upstreamHost string, strip bool, reserved ...string) {
+	if loc := resp.Header.Get("Location"); strip && loc != "" {
+		resp.Header.Set("Location", rewriteLocation(loc, prefix, upstreamHost))
+	}
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+		resp.Header.Del("Set-Cookie")
+		for _, v := range cookies {
+			c, err := http.ParseSetCookie(v)
+			if err != nil || slices.Contains(reserved, c.Name) {
+				continue
+			}
+			resp.Header.Add("Set-Cookie", rewriteCookiePath(v, prefix, strip))
+		}
+	}
+}
+
+// rewriteLocation prefixes absolute-path redirects and turns redirects to the
+// upstream's own address, the Host it was sent, into paths under prefix. Others
+// are left alone.
+func rewriteLocation(loc, prefix, upstreamHost string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return loc
+	}
+	if u.Host != "" {
+		if !strings.EqualFold(u.Host, upstreamHost) {
+			return loc
+		}
+		u.Scheme, u.Host = "", ""
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		return u.String()
+	}
+	u.Path = prefix + u.Path
+	if u.RawPath != "" {
+		u.RawPath = prefix + u.RawPath
+	}
+	return u.String()
+}
+
+// rewriteCookiePath scopes a cookie to prefix and drops its domain, which
+// names the upstream. With strip, the upstream's paths are relative to prefix;
+// otherwise paths outside prefix are narrowed to it.
+func rewriteCookiePath(v, prefix string, strip bool) string {
+	c, err := http.ParseSetCookie(v)
+	if err != nil {
+		return v
+	}
+	switch {
+	case strip && strings.HasPrefix(c.Path, "/"):
+		c.Path = prefix + c.Path
+	case c.Path != prefix && !strings.HasPrefix(c.Path, prefix+"/"):
+		c.Path = prefix + "/"
+	}
+	c.Domain = ""
+	return c.String()
+}
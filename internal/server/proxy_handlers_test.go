@@ -0,0 +1,334 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	auth "github.com/codespace-operator/common/auth/pkg/auth"
+	"github.com/codespace-operator/common/common/pkg/common"
+	rbac "github.com/codespace-operator/common/rbac/pkg/rbac"
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+func TestParseProxyPath(t *testing.T) {
+	cases := []struct {
+		path                  string
+		namespace, name, rest string
+		ok                    bool
+	}{
+		{"/s/default/demo/", "default", "demo", "/", true},
+		{"/s/default/demo/lab/tree", "default", "demo", "/lab/tree", true},
+		{"/s/default/demo", "default", "demo", "", true},
+		{"/s/default/", "", "", "", false},
+		{"/s//demo/", "", "", "", false},
+		{"/api/v1/me", "", "", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			ns, name, rest, ok := parseProxyPath(tc.path)
+			if ok != tc.ok || ns != tc.namespace || name != tc.name || rest != tc.rest {
+				t.Fatalf("parseProxyPath() = %q, %q, %q, %v; want %q, %q, %q, %v",
+					ns, name, rest, ok, tc.namespace, tc.name, tc.rest, tc.ok)
+			}
+		})
+	}
+}
+
+func TestRewriteProxyResponse(t *testing.T) {
+	const prefix, upstream = "/s/default/demo", "cs-demo.default.svc:80"
+	locations := []struct{ in, want string }{
+		{"/login?next=%2F", "/s/default/demo/login?next=%2F"},
+		{"http://cs-demo.default.svc:80/lab", "/s/default/demo/lab"},
+		{"https://idp.example.com/auth", "https://idp.example.com/auth"},
+		{"lab", "lab"},
+	}
+	for _, tc := range locations {
+		if got := rewriteLocation(tc.in, prefix, upstream); got != tc.want {
+			t.Errorf("rewriteLocation(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	cookies := []struct {
+		in    string
+		strip bool
+		want  string
+	}{
+		{"_xsrf=abc; Path=/", true, "_xsrf=abc; Path=/s/default/demo/"},
+		{"key=v; Path=/vscode; Domain=cs-demo; HttpOnly", true, "key=v; Path=/s/default/demo/vscode; HttpOnly"},
+		{"key=v", true, "key=v; Path=/s/default/demo/"},
+		{"_xsrf=abc; Path=/s/default/demo/", false, "_xsrf=abc; Path=/s/default/demo/"},
+		{"key=v; Path=/api", false, "key=v; Path=/s/default/demo/"},
+		{"key=v; Path=/s/default/demo-other", false, "key=v; Path=/s/default/demo/"},
+	}
+	for _, tc := range cookies {
+		if got := rewriteCookiePath(tc.in, prefix, tc.strip); got != tc.want {
+			t.Errorf("rewriteCookiePath(%q, %v) = %q, want %q", tc.in, tc.strip, got, tc.want)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Set-Cookie": {
+		"CODESPACE_SESSION=forged; Path=/",
+		"codespace_proxy=forged",
+		"_xsrf=abc; Path=/",
+	}}}
+	rewriteProxyResponse(resp, prefix, upstream, true, "CODESPACE_SESSION", proxyCookieName)
+	if got := resp.Header.Values("Set-Cookie"); len(got) != 1 || got[0] != "_xsrf=abc; Path=/s/default/demo/" {
+		t.Errorf("Set-Cookie = %q, want only the session's own cookie", got)
+	}
+}
+
+func TestSessionProxyHost(t *testing.T) {
+	const domain = "sessions.codespace.test"
+	a := sessionProxyHost(domain, "team", "demo")
+	if !onSessionProxyDomain(domain, a) || !onSessionProxyDomain(domain, strings.ToUpper(a)) {
+		t.Fatalf("%q is not on %q", a, domain)
+	}
+	if a == sessionProxyHost(domain, "team-demo", "") || a == sessionProxyHost(domain, "team", "demo2") {
+		t.Fatalf("session hosts are not unique")
+	}
+	if onSessionProxyDomain(domain, "codespace.test") || onSessionProxyDomain(domain, "evilsessions.codespace.test") ||
+		onSessionProxyDomain("", a) {
+		t.Fatalf("server host treated as a session host")
+	}
+
+	guard := sessionProxyHostGuard(domain)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		host, path string
+		want       int
+	}{
+		{a, "/s/team/demo/", http.StatusOK},
+		{a, "/api/v1/me", http.StatusNotFound},
+		{a, "/", http.StatusNotFound},
+		{"codespace.test", "/api/v1/me", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Host = tc.host
+		guard.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s%s: status %d, want %d", tc.host, tc.path, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestProxyTokens(t *testing.T) {
+	h := &handlers{deps: &serverDeps{authCfg: &auth.AuthConfig{JWTSecret: "secret"}, logger: slog.Default()}}
+	ticketsFor := func(namespace, name string) auth.TokenManager {
+		tm, err := h.proxyTokens("ticket", namespace, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	ticket, err := ticketsFor("team", "demo").CreateToken("local:alice", nil, "local", time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ticketsFor("team", "demo").ValidateToken(ticket); err != nil {
+		t.Fatalf("ticket rejected by its session: %v", err)
+	}
+	if _, err := ticketsFor("team", "other").ValidateToken(ticket); err == nil {
+		t.Fatal("ticket accepted by another session")
+	}
+	cookies, _ := h.proxyTokens("cookie", "team", "demo")
+	if _, err := cookies.ValidateToken(ticket); err == nil {
+		t.Fatal("ticket accepted as a cookie")
+	}
+	server, _ := auth.NewJWTManager("secret", slog.Default())
+	if _, err := server.ValidateToken(ticket); err == nil {
+		t.Fatal("ticket accepted as a server login")
+	}
+}
+
+// testEnforcer loads policy into the casbin model the server ships with.
+func testEnforcer(t *testing.T, policy string) rbac.RBACInterface {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.csv")
+	if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	enf, err := rbac.NewRBAC(context.Background(), rbac.RBACConfig{
+		ModelPath:  "../../cfg/rbac-casbin/model.conf",
+		PolicyPath: path,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enf
+}
+
+func TestCanConnect(t *testing.T) {
+	enf := testEnforcer(t, `
+p, admin, *, *, *, allow
+p, editor, session, connect, *, allow
+p, local:bob, session/shared, connect, team, allow
+`)
+
+	session := func(name, creator string) *codespacev1.Session {
+		return &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "team",
+			Annotations: map[string]string{common.AnnotationCreatedBy: creator},
+		}}
+	}
+	cases := []struct {
+		name    string
+		pr      rbac.Principal
+		session *codespacev1.Session
+		want    bool
+	}{
+		{"creator", rbac.Principal{Subject: "local:alice", Roles: []string{"editor"}}, session("mine", "local:alice"), true},
+		{"other editor", rbac.Principal{Subject: "local:carol", Roles: []string{"editor"}}, session("mine", "local:alice"), false},
+		{"creator without connect", rbac.Principal{Subject: "local:dave", Roles: []string{"viewer"}}, session("his", "local:dave"), false},
+		{"granted on the session", rbac.Principal{Subject: "local:bob"}, session("shared", "local:alice"), true},
+		{"grant is per session", rbac.Principal{Subject: "local:bob"}, session("mine", "local:alice"), false},
+		{"admin", rbac.Principal{Subject: "local:root", Roles: []string{"admin"}}, session("mine", "local:alice"), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := canConnect(enf, &tc.pr, tc.session)
+			if err != nil || got != tc.want {
+				t.Fatalf("canConnect() = %v, %v; want %v", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSessionProxyWebSocket(t *testing.T) {
+	// The IDE: echoes WebSocket messages and reports what reached it
+	var upstreamPath, upstreamCookies string
+	upgrader := websocket.Upgrader{}
+	ide := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath, upstreamCookies = r.URL.Path, r.Header.Get("Cookie")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer ide.Close()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := codespacev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	session := &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{
+		Name: "demo", Namespace: "team", UID: "demo-uid",
+		Labels:      map[string]string{common.InstanceIDLabel: "test"},
+		Annotations: map[string]string{common.AnnotationCreatedBy: "local:alice"},
+	}}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cs-demo", Namespace: "team",
+			Labels:          map[string]string{codespacev1.SessionNameLabel: "demo"},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(session, codespacev1.GroupVersion.WithKind("Session"))},
+		},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}},
+	}
+
+	const domain = "sessions.codespace.test"
+	host := sessionProxyHost(domain, "team", "demo")
+	newHandlers := func(domain string) *handlers {
+		authCfg := &auth.AuthConfig{JWTSecret: "secret"}
+		am, err := auth.NewAuthManager(authCfg, slog.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &handlers{deps: &serverDeps{
+			client:      fake.NewClientBuilder().WithScheme(scheme).WithObjects(session, svc).Build(),
+			config:      &ServerConfig{SessionProxyDomain: domain},
+			rbac:        testEnforcer(t, "p, editor, session, connect, *, allow\n"),
+			authManager: am,
+			authCfg:     authCfg,
+			instanceID:  "test",
+			logger:      slog.Default(),
+			// Every Service resolves to the test IDE
+			proxyTransport: &http.Transport{DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, ide.Listener.Addr().String())
+			}},
+		}}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		domain   string
+		origin   string
+		wantCode int
+	}{
+		{"session host", domain, "http://" + host, http.StatusSwitchingProtocols},
+		{"cross-origin", domain, "https://evil.example.com", http.StatusForbidden},
+		{"no proxy domain", "", "http://" + host, http.StatusNotImplemented},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHandlers(tc.domain)
+			front := httptest.NewServer(http.HandlerFunc(h.handleSessionProxy))
+			defer front.Close()
+
+			cm, err := h.proxyTokens("cookie", "team", "demo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := cm.CreateToken("local:alice", []string{"editor"}, "local", time.Hour, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dialer := websocket.Dialer{NetDialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, front.Listener.Addr().String())
+			}}
+			header := http.Header{
+				"Origin": {tc.origin},
+				"Cookie": {proxyCookieName + "=" + token + "; _xsrf=abc"},
+			}
+			conn, resp, err := dialer.Dial("ws://"+host+"/s/team/demo/api/kernels/1/channels", header)
+			if resp == nil {
+				t.Fatalf("dial: %v", err)
+			}
+			if resp.StatusCode != tc.wantCode {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tc.wantCode)
+			}
+			if conn == nil {
+				return
+			}
+			defer conn.Close()
+
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "ping" {
+				t.Fatalf("echo = %q, %v", msg, err)
+			}
+			if upstreamPath != "/api/kernels/1/channels" {
+				t.Errorf("upstream path %q, want the prefix stripped", upstreamPath)
+			}
+			if upstreamCookies != "_xsrf=abc" {
+				t.Errorf("upstream cookies %q, want only the session's own", upstreamCookies)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	auth "github.com/codespace-operator/common/auth/pkg/auth"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// Session content is controlled by whoever can edit the session, so it must not
+// run on the origin of the server UI and API, where its scripts would act with
+// the viewer's credentials. Every session is served on a host of its own under
+// session_proxy_domain and the server's own host only redirects there; the login
+// is handed over with a short-lived ticket and kept in a cookie of the session
+// host. Without session_proxy_domain the proxy is off: a sandboxed page on the
+// server's origin cannot open WebSockets or send cookies, so no IDE would work.
+const (
+	proxyTicketParam = "codespace_proxy_ticket"
+	proxyCookieName  = "codespace_proxy"
+	proxyTicketTTL   = time.Minute
+)
+
+// sessionProxyHost is the host a session is served on under domain. The label
+// is a hash, as namespace and name together can exceed a DNS label.
+func sessionProxyHost(domain, namespace, name string) string {
+	sum := sha256.Sum256([]byte(namespace + "/" + name))
+	return "s-" + hex.EncodeToString(sum[:10]) + "." + domain
+}
+
+// onSessionProxyDomain reports whether host is a session host under domain.
+func onSessionProxyDomain(domain, host string) bool {
+	return domain != "" && strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(domain))
+}
+
+// sessionProxyHostGuard serves nothing but the session proxy on session hosts,
+// so the UI and API never share an origin with session content.
+func sessionProxyHostGuard(domain string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if onSessionProxyDomain(domain, r.Host) && !strings.HasPrefix(r.URL.Path, proxyPathPrefix) {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// proxyTokens signs the tickets or cookies ("ticket", "cookie") of one session
+// with a key derived from the JWT secret, so neither is valid for another
+// session, for the other purpose or as a server login.
+func (h *handlers) proxyTokens(purpose, namespace, name string) (auth.TokenManager, error) {
+	mac := hmac.New(sha256.New, []byte(h.deps.authCfg.JWTSecret))
+	mac.Write([]byte("session-proxy/" + purpose + "/" + namespace + "/" + name))
+	return auth.NewJWTManager(hex.EncodeToString(mac.Sum(nil)), h.deps.logger)
+}
+
+// redirectToProxyHost sends an authorized request on the server's host to the
+// session's own host, with a ticket for the login.
+func (h *handlers) redirectToProxyHost(w http.ResponseWriter, r *http.Request, claims *auth.TokenClaims, namespace, name string) {
+	tm, err := h.proxyTokens("ticket", namespace, name)
+	if err != nil {
+		errJSON(w, err)
+		return
+	}
+	ticket, err := tm.CreateToken(claims.Sub, claims.Roles, claims.Provider, proxyTicketTTL,
+		map[string]any{"email": claims.Email, "username": claims.Username})
+	if err != nil {
+		errJSON(w, err)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	q := r.URL.Query()
+	q.Set(proxyTicketParam, ticket)
+	target := url.URL{
+		Scheme:   scheme,
+		Host:     sessionProxyHost(h.deps.config.SessionProxyDomain, namespace, name),
+		Path:     r.URL.Path,
+		RawPath:  r.URL.RawPath,
+		RawQuery: q.Encode(),
+	}
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// redeemProxyTicket turns a ticket into the session host's login cookie, scoped
+// to the session's path, and reloads the page without the ticket.
+func (h *handlers) redeemProxyTicket(w http.ResponseWriter, r *http.Request, namespace, name, ticket string) {
+	tm, err := h.proxyTokens("ticket", namespace, name)
+	if err != nil {
+		errJSON(w, err)
+		return
+	}
+	claims, err := tm.ValidateToken(ticket)
+	if err != nil {
+		http.Error(w, "invalid or expired session link", http.StatusUnauthorized)
+		return
+	}
+	cm, err := h.proxyTokens("cookie", namespace, name)
+	if err != nil {
+		errJSON(w, err)
+		return
+	}
+	ttl := h.deps.authCfg.SessionTTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	token, err := cm.CreateToken(claims.Sub, claims.Roles, claims.Provider, ttl,
+		map[string]any{"email": claims.Email, "username": claims.Username})
+	if err != nil {
+		errJSON(w, err)
+		return
+	}
+	h.deps.authManager.SetCookie(w, r, proxyCookieName, token, auth.CookieSession,
+		&auth.CookieOpts{Path: codespacev1.ProxyPath(namespace, name), HttpOnly: true})
+
+	q := r.URL.Query()
+	q.Del(proxyTicketParam)
+	target := url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: q.Encode()}
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
+
+// proxyCookieClaims validates the login cookie of a session host.
+func (h *handlers) proxyCookieClaims(r *http.Request, namespace, name string) (*auth.TokenClaims, error) {
+	c, err := r.Cookie(proxyCookieName)
+	if err != nil {
+		return nil, auth.ErrNoToken
+	}
+	cm, err := h.proxyTokens("cookie", namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return cm.ValidateToken(c.Value)
+}
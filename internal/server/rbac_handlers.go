@@ -130,7 +130,7 @@ func (h *handlers) handleUserIntrospect(w http.ResponseWriter, r *http.Request)
 
 	// Default actions if not specified
 	if len(actions) == 0 {
-		actions = []string{"get", "list", "watch", "create", "update", "delete", "scale"}
+		actions = []string{"get", "list", "watch", "create", "update", "delete", "scale", "logs", "exec", "connect", "snapshot", "restore", "restart", "privileged"}
 	}
 
 	// Get implicit roles from Casbin
@@ -520,7 +520,7 @@ func (h *handlers) handleUserPermissions(w http.ResponseWriter, r *http.Request)
 	actions := splitCSVQuery(r.URL.Query().Get("actions"))
 
 	if len(actions) == 0 {
-		actions = []string{"get", "list", "watch", "create", "update", "delete", "scale"}
+		actions = []string{"get", "list", "watch", "create", "update", "delete", "scale", "logs", "exec", "connect", "snapshot", "restore", "restart", "privileged"}
 	}
 
 	// If no namespaces specified, discover user's allowed namespaces
@@ -9,16 +9,25 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"slices"
+	"strings"
 	"time"
 
 	auth "github.com/codespace-operator/common/auth/pkg/auth"
 	"github.com/codespace-operator/common/common/pkg/common"
 	rbac "github.com/codespace-operator/common/rbac/pkg/rbac"
 	"github.com/swaggo/swag"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
@@ -46,6 +55,9 @@ type ErrorResponse struct {
 type serverDeps struct {
 	client      client.Client
 	dyn         dynamic.Interface
+	kube        kubernetes.Interface
+	restConfig  *rest.Config
+	sessions    *sessionHub
 	scheme      *runtime.Scheme
 	config      *ServerConfig
 	rbac        rbac.RBACInterface
@@ -56,6 +68,8 @@ type serverDeps struct {
 	instanceID  string
 	manager     common.AnchorMeta
 	logger      *slog.Logger
+	// proxyTransport reaches session Services; nil uses http.DefaultTransport
+	proxyTransport http.RoundTripper
 }
 
 // ServerVersionInfo contains server version and build information
@@ -102,12 +116,30 @@ func RunServer(cfg *ServerConfig, args []string) {
 	k8sCfg.Timeout = 30 * time.Second
 	k8sCfg.QPS = cfg.KubeQPS
 	k8sCfg.Burst = cfg.KubeBurst
+	k8sCfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(&kubeMetricsTransport{next: rt})
+	})
+
+	shutdownTracing, err := setupTracing(context.Background(), cfg, logger)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
 
 	scheme := runtime.NewScheme()
 	if err := corev1.AddToScheme(scheme); err != nil {
 		logger.Error("Failed to add corev1 scheme", "error", err)
 		os.Exit(1)
 	}
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		logger.Error("Failed to add appsv1 scheme", "error", err)
+		os.Exit(1)
+	}
+	if err := netv1.AddToScheme(scheme); err != nil {
+		logger.Error("Failed to add networkingv1 scheme", "error", err)
+		os.Exit(1)
+	}
 	if err := codespacev1.AddToScheme(scheme); err != nil {
 		logger.Error("Failed to add codespace scheme", "error", err)
 		os.Exit(1)
@@ -125,6 +157,15 @@ func RunServer(cfg *ServerConfig, args []string) {
 		os.Exit(1)
 	}
 
+	// Streaming calls (pod logs, exec) must not be cut off by the client timeout
+	streamCfg := rest.CopyConfig(k8sCfg)
+	streamCfg.Timeout = 0
+	kubeClient, err := kubernetes.NewForConfig(streamCfg)
+	if err != nil {
+		logger.Error("Failed to create Kubernetes clientset", "error", err)
+		os.Exit(1)
+	}
+
 	// Test Kubernetes connectivity
 	if err := testKubernetesConnection(k8sClient); err != nil {
 		logger.Error("Kubernetes connection test failed", "error", err)
@@ -167,11 +208,26 @@ func RunServer(cfg *ServerConfig, args []string) {
 			"name", manager.Name,
 			"namespace", manager.Namespace)
 	}
-	rbacMW := rbac.NewMiddleware(rbacSystem, ExtractFromAuth, logger)
+	// Shared Session informer for list and stream endpoints
+	sessionHub, err := newSessionHub(streamCfg, scheme, cfg.ClusterScope, instanceID)
+	if err != nil {
+		logger.Error("Failed to create session cache", "error", err)
+		os.Exit(1)
+	}
+	go func() {
+		if err := sessionHub.Start(context.Background()); err != nil {
+			logger.Error("Session cache stopped", "error", err)
+		}
+	}()
+
+	rbacMW := rbac.NewMiddleware(countingEnforcer{rbacSystem}, ExtractFromAuth, logger)
 	// Create server dependencies with proper interfaces
 	deps := &serverDeps{
 		client:      k8sClient,
 		dyn:         dynClient,
+		kube:        kubeClient,
+		restConfig:  streamCfg,
+		sessions:    sessionHub,
 		scheme:      scheme,
 		config:      cfg,
 		rbac:        rbacSystem,
@@ -189,12 +245,25 @@ func RunServer(cfg *ServerConfig, args []string) {
 
 	// Build middleware chain with proper interfaces
 	var handler http.Handler = mux
-	handler = corsMiddleware(cfg.AllowOrigin)(handler)
+	handler = corsMiddleware(cfg.AllowedOrigins())(handler)
 	handler = requestLoggingMiddleware(logger)(handler)
 	handler = deps.authMw.AuthGate(handler)
+	if cfg.MetricsEnabled {
+		handler = metricsMiddleware(mux, authCfg.AuthPath)(handler)
+	}
+	handler = tracingMiddleware(mux)(handler)
 	handler = securityHeadersMiddleware()(handler)
+	handler = sessionProxyHostGuard(cfg.SessionProxyDomain)(handler)
 
 	logger.Info("Codespace Server starting", "address", cfg.GetAddr())
+	if cfg.MetricsEnabled && cfg.MetricsAddr != "" {
+		logger.Info("Serving metrics", "address", cfg.MetricsAddr)
+		go func() {
+			if err := serveMetrics(cfg.MetricsAddr, cfg.MetricsToken); err != nil {
+				logger.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
 
 	// Report if running cluster-scoped
 	if cfg.ClusterScope {
@@ -256,12 +325,28 @@ func setupHandlers(deps *serverDeps) *http.ServeMux {
 	// === Health and Status Endpoints ===
 	mux.HandleFunc("/healthz", h.handleHealthz)
 	mux.HandleFunc("/readyz", h.handleReadyz)
+	// On the public port only behind a token; see serveMetrics otherwise
+	if cfg := deps.config; cfg.MetricsEnabled && cfg.MetricsAddr == "" {
+		if cfg.MetricsToken == "" {
+			logger.Warn("Not serving /metrics: set metrics_addr or metrics_token")
+		} else {
+			mux.Handle("/metrics", metricsHandler(cfg.MetricsToken))
+		}
+	}
 
 	// === Session Operations with RBAC ===
 	mux.HandleFunc("/api/v1/server/sessions", h.wrapWithAuth(h.handleSessionOperations))
+	mux.HandleFunc("/api/v1/server/sessions:batch", h.wrapWithAuth(h.handleBatchSessions))
 	mux.HandleFunc("/api/v1/server/sessions/adopt", h.wrapWithRBAC("*", "admin", "*", h.handleAdoptSession))
 	mux.HandleFunc("/api/v1/server/sessions/", h.wrapWithAuth(h.handleSessionOperationsWithPath))
 
+	// === Session Reverse Proxy ===
+	// Authenticates itself: session hosts have a login of their own
+	mux.HandleFunc(proxyPathPrefix, h.handleSessionProxy)
+	if deps.config.SessionProxyDomain == "" {
+		logger.Info("Session proxy disabled: set session_proxy_domain to serve sessions under " + proxyPathPrefix)
+	}
+
 	// === Session Streaming ===
 	mux.HandleFunc("/api/v1/stream/sessions", h.wrapWithAuth(h.handleStreamSessions))
 
@@ -429,12 +514,18 @@ func (h *handlers) handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(spec))
 }
 
-func corsMiddleware(allowOrigin string) func(http.Handler) http.Handler {
+// corsMiddleware echoes a listed Origin with credentials allowed. A "*" entry
+// allows any origin without credentials.
+func corsMiddleware(allowed []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if allowOrigin != "" {
-				w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			origin := r.Header.Get("Origin")
+			switch {
+			case origin != "" && slices.ContainsFunc(allowed, func(a string) bool { return a != "*" && strings.EqualFold(a, origin) }):
+				w.Header().Set("Access-Control-Allow-Origin", origin)
 				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			case slices.Contains(allowed, "*"):
+				w.Header().Set("Access-Control-Allow-Origin", "*")
 			}
 			w.Header().Set("Vary", "Origin")
 			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
@@ -456,15 +547,26 @@ func requestLoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handl
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
-			// Generate or extract request ID
+			// Generate or extract request ID. When tracing, a fresh ID reuses the
+			// trace ID so logs and traces share one correlation key.
+			span := trace.SpanFromContext(r.Context())
 			reqID := r.Header.Get("X-Request-Id")
 			if reqID == "" {
-				reqID = common.RandB64(6)
+				if span.SpanContext().IsValid() {
+					reqID = span.SpanContext().TraceID().String()
+				} else {
+					reqID = common.RandB64(6)
+				}
+				r.Header.Set("X-Request-Id", reqID)
 			}
 			w.Header().Set("X-Request-Id", reqID)
 
 			// Create request-scoped logger
 			reqLogger := common.LoggerWithRequestID(logger, reqID)
+			if span.SpanContext().IsValid() {
+				span.SetAttributes(attribute.String("http.request_id", reqID))
+				reqLogger = reqLogger.With("trace_id", span.SpanContext().TraceID().String())
+			}
 			r = r.WithContext(common.WithLogger(r.Context(), reqLogger))
 
 			rw := &common.ResponseWriter{ResponseWriter: w}
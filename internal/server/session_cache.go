@@ -0,0 +1,374 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codespace-operator/common/common/pkg/common"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// subscriberBuffer is how many events a slow SSE client may lag behind before
+// it is disconnected (EventSource reconnects on its own).
+const subscriberBuffer = 256
+
+// cacheSyncTimeout is how long the endpoints wait for the shared cache before
+// serving from the API server directly.
+const cacheSyncTimeout = 30 * time.Second
+
+// historySize bounds how many recent changes are kept for Last-Event-ID resumes.
+// Clients that fall further behind get a resync and a fresh snapshot.
+const historySize = 1024
+
+// sessionChange is a single Session add/update/delete seen by the shared informer.
+// ID is the Session's resourceVersion and doubles as the SSE event ID. Resync
+// changes carry no Session and tell the subscriber to start over from a snapshot.
+type sessionChange struct {
+	ID      string
+	Type    watch.EventType
+	Session *codespacev1.Session
+	Resync  bool
+}
+
+// sessionSubscriber receives the changes of one SSE connection. An empty
+// namespace subscribes to all namespaces; RBAC filtering is done by the handler.
+type sessionSubscriber struct {
+	namespace string
+	ch        chan sessionChange
+}
+
+// C is closed when the subscriber is dropped (too slow) or the hub stops.
+func (s *sessionSubscriber) C() <-chan sessionChange { return s.ch }
+
+// sessionHub owns the server's single Session informer. List endpoints read
+// from its cache and every SSE stream is fanned out from its one watch, instead
+// of each request hitting the API server.
+type sessionHub struct {
+	cache   cache.Cache
+	synced  atomic.Bool
+	created time.Time
+	// lastErr is the last watch error before the cache synced, and forbidden
+	// whether it was an authorization error, which will not go away on retry.
+	lastErr   atomic.Pointer[string]
+	forbidden atomic.Bool
+
+	mu      sync.RWMutex
+	subs    map[*sessionSubscriber]struct{}
+	history []sessionChange
+	lastID  string
+}
+
+// newSessionHub builds an informer cache for Sessions. Outside cluster scope the
+// cache only holds Sessions labelled with this server's instance ID.
+func newSessionHub(cfg *rest.Config, scheme *runtime.Scheme, clusterScope bool, instanceID string) (*sessionHub, error) {
+	byObject := cache.ByObject{}
+	if !clusterScope {
+		byObject.Label = labels.SelectorFromSet(labels.Set{common.InstanceIDLabel: instanceID})
+	}
+	hub := &sessionHub{subs: map[*sessionSubscriber]struct{}{}, created: time.Now()}
+	c, err := cache.New(cfg, cache.Options{
+		Scheme:                   scheme,
+		ByObject:                 map[client.Object]cache.ByObject{&codespacev1.Session{}: byObject},
+		DefaultWatchErrorHandler: hub.watchError,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create session cache: %w", err)
+	}
+	hub.cache = c
+	return hub, nil
+}
+
+// watchError is called by the reflector when the watch breaks. The reflector
+// relists and re-watches on its own; when the watch expired (410 Gone) the
+// history may have gaps, so subscribers are told to resync.
+func (hub *sessionHub) watchError(ctx context.Context, r *toolscache.Reflector, err error) {
+	toolscache.DefaultWatchErrorHandler(ctx, r, err)
+	hub.handleWatchError(err)
+}
+
+// handleWatchError records err and resyncs the subscribers if the watch expired.
+func (hub *sessionHub) handleWatchError(err error) {
+	hub.recordError(err)
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		logger.Info("Session watch expired, resyncing streams", "err", err)
+		hub.resync()
+	}
+}
+
+// recordError keeps err as the reason the cache has not synced yet.
+func (hub *sessionHub) recordError(err error) {
+	if hub.Synced() {
+		return
+	}
+	msg := err.Error()
+	hub.lastErr.Store(&msg)
+	hub.forbidden.Store(apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err))
+}
+
+// Start runs the informer until ctx is done. It blocks.
+func (hub *sessionHub) Start(ctx context.Context) error {
+	inf, err := hub.cache.GetInformer(ctx, &codespacev1.Session{})
+	if err != nil {
+		return fmt.Errorf("get session informer: %w", err)
+	}
+	if _, err := inf.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) { hub.broadcast(watch.Added, obj) },
+		UpdateFunc: func(_, obj any) {
+			hub.broadcast(watch.Modified, obj)
+		},
+		DeleteFunc: func(obj any) {
+			if tomb, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tomb.Obj
+			}
+			hub.broadcast(watch.Deleted, obj)
+		},
+	}); err != nil {
+		return fmt.Errorf("add session event handler: %w", err)
+	}
+
+	go func() {
+		if hub.cache.WaitForCacheSync(ctx) {
+			hub.synced.Store(true)
+			logger.Info("Session cache synced")
+		}
+	}()
+
+	err = hub.cache.Start(ctx)
+
+	hub.mu.Lock()
+	for s := range hub.subs {
+		close(s.ch)
+		delete(hub.subs, s)
+	}
+	hub.mu.Unlock()
+	return err
+}
+
+// Synced reports whether the initial list has completed.
+func (hub *sessionHub) Synced() bool { return hub.synced.Load() }
+
+// Fallback reports whether the endpoints should stop waiting for the cache and
+// read from the API server: it was denied access, or has not synced within
+// cacheSyncTimeout. The cache keeps retrying and is used once it syncs.
+func (hub *sessionHub) Fallback() bool {
+	return !hub.Synced() && (hub.forbidden.Load() || time.Since(hub.created) > cacheSyncTimeout)
+}
+
+// NotSyncedReason says why the cache has not synced yet.
+func (hub *sessionHub) NotSyncedReason() string {
+	if msg := hub.lastErr.Load(); msg != nil {
+		return *msg
+	}
+	return "waiting for the initial list"
+}
+
+// WaitForSync blocks until the cache is synced, the hub falls back or ctx is
+// done, and reports whether the cache synced.
+func (hub *sessionHub) WaitForSync(ctx context.Context) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !hub.Synced() {
+		if hub.Fallback() {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// Reader returns the cache-backed reader for Sessions.
+func (hub *sessionHub) Reader() client.Reader { return hub.cache }
+
+// Subscribe registers a new stream. Callers must Unsubscribe when done.
+//
+// If lastEventID is still in the history, the changes after it are returned
+// for replay and resumed is true. Otherwise the caller should send a snapshot
+// from the cache, tagged with snapshotID. Changes broadcast after Subscribe
+// returns are delivered on the subscriber's channel, so nothing is missed;
+// a change may show up in both the snapshot and the channel.
+func (hub *sessionHub) Subscribe(namespace, lastEventID string) (s *sessionSubscriber, replay []sessionChange, resumed bool, snapshotID string) {
+	s = &sessionSubscriber{namespace: namespace, ch: make(chan sessionChange, subscriberBuffer)}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.subs[s] = struct{}{}
+
+	if lastEventID != "" {
+		for i := len(hub.history) - 1; i >= 0; i-- {
+			if hub.history[i].ID != lastEventID {
+				continue
+			}
+			for _, c := range hub.history[i+1:] {
+				if namespace == "" || namespace == c.Session.Namespace {
+					c.Session = c.Session.DeepCopy()
+					replay = append(replay, c)
+				}
+			}
+			return s, replay, true, hub.lastID
+		}
+	}
+	return s, nil, false, hub.lastID
+}
+
+// LastID is the resourceVersion of the most recent change, used to tag snapshots.
+func (hub *sessionHub) LastID() string {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return hub.lastID
+}
+
+// Unsubscribe removes s; it is safe to call after s was dropped.
+func (hub *sessionHub) Unsubscribe(s *sessionSubscriber) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.subs[s]; ok {
+		delete(hub.subs, s)
+		close(s.ch)
+	}
+}
+
+func (hub *sessionHub) broadcast(t watch.EventType, obj any) {
+	sess, ok := obj.(*codespacev1.Session)
+	if !ok {
+		return
+	}
+
+	change := sessionChange{ID: sess.ResourceVersion, Type: t, Session: sess}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.history = append(hub.history, change)
+	if len(hub.history) > historySize {
+		hub.history = append(hub.history[:0:0], hub.history[len(hub.history)-historySize:]...)
+	}
+	hub.lastID = change.ID
+
+	for s := range hub.subs {
+		if s.namespace != "" && s.namespace != sess.Namespace {
+			continue
+		}
+		c := change
+		c.Session = sess.DeepCopy()
+		hub.send(s, c)
+	}
+}
+
+// resync drops the history and tells every subscriber to start over.
+func (hub *sessionHub) resync() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.history = nil
+	for s := range hub.subs {
+		hub.send(s, sessionChange{Resync: true})
+	}
+}
+
+// send delivers c without blocking the informer. A subscriber whose buffer is
+// full is dropped; its client reconnects with Last-Event-ID and resumes from
+// the history. Callers hold hub.mu.
+func (hub *sessionHub) send(s *sessionSubscriber, c sessionChange) {
+	select {
+	case s.ch <- c:
+	default:
+		logger.Warn("Dropping slow session stream subscriber", "namespace", s.namespace)
+		delete(hub.subs, s)
+		close(s.ch)
+	}
+}
+
+// watchSessionsDirect streams the Session changes in namespace ("" for all)
+// after resourceVersion from an API server watch of its own, for streams served
+// while the shared cache is unavailable. The watch is resumed when the API
+// server closes it; once resourceVersion has expired a Resync change is sent
+// and the channel closed. It is also closed when ctx is done.
+func (h *handlers) watchSessionsDirect(ctx context.Context, namespace, resourceVersion string) <-chan sessionChange {
+	ch := make(chan sessionChange, subscriberBuffer)
+	opts := metav1.ListOptions{ResourceVersion: resourceVersion}
+	if !h.deps.config.ClusterScope {
+		opts.LabelSelector = labels.Set{common.InstanceIDLabel: h.deps.instanceID}.AsSelector().String()
+	}
+	var res dynamic.ResourceInterface = h.deps.dyn.Resource(gvr)
+	if namespace != "" {
+		res = h.deps.dyn.Resource(gvr).Namespace(namespace)
+	}
+	send := func(c sessionChange) bool {
+		select {
+		case ch <- c:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	expired := func(err error) bool { return apierrors.IsResourceExpired(err) || apierrors.IsGone(err) }
+
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			w, err := res.Watch(ctx, opts)
+			if expired(err) {
+				send(sessionChange{Resync: true})
+				return
+			}
+			if err != nil {
+				logger.Warn("Failed to watch sessions", "namespace", namespace, "err", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			for ev := range w.ResultChan() {
+				if ev.Type == watch.Error {
+					if err := apierrors.FromObject(ev.Object); expired(err) {
+						w.Stop()
+						send(sessionChange{Resync: true})
+						return
+					}
+					continue
+				}
+				u, ok := ev.Object.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				var sess codespacev1.Session
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &sess); err != nil {
+					logger.Warn("Failed to convert session in stream", "name", u.GetName(), "err", err)
+					continue
+				}
+				opts.ResourceVersion = sess.ResourceVersion
+				if !send(sessionChange{ID: sess.ResourceVersion, Type: ev.Type, Session: &sess}) {
+					w.Stop()
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// sessionReader serves Session reads from the shared cache once it has synced,
+// and from the API server before that.
+func (h *handlers) sessionReader() client.Reader {
+	if h.deps.sessions != nil && h.deps.sessions.Synced() {
+		return h.deps.sessions.Reader()
+	}
+	return h.deps.client
+}
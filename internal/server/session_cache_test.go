@@ -0,0 +1,208 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+func TestSessionHubFallback(t *testing.T) {
+	denied := apierrors.NewForbidden(schema.GroupResource{Group: "codespace.codespace.dev", Resource: "sessions"}, "", nil)
+	for _, tc := range []struct {
+		name    string
+		age     time.Duration
+		err     error
+		synced  bool
+		want    bool
+		wantMsg string
+	}{
+		{"starting", time.Second, nil, false, false, "waiting for the initial list"},
+		{"retrying", time.Second, apierrors.NewServiceUnavailable("down"), false, false, "down"},
+		{"forbidden", time.Second, denied, false, true, "forbidden"},
+		{"timed out", time.Minute, nil, false, true, "waiting for the initial list"},
+		{"synced", time.Minute, nil, true, false, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hub := &sessionHub{created: time.Now().Add(-tc.age)}
+			hub.synced.Store(tc.synced)
+			if tc.err != nil {
+				hub.recordError(tc.err)
+			}
+			if got := hub.Fallback(); got != tc.want {
+				t.Fatalf("Fallback() = %v, want %v", got, tc.want)
+			}
+			if got := hub.NotSyncedReason(); !tc.synced && !strings.Contains(got, tc.wantMsg) {
+				t.Fatalf("NotSyncedReason() = %q, want %q", got, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestReadyzReportsSessionCache(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := codespacev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		hub      *sessionHub
+		wantCode int
+		wantBody string
+	}{
+		{"waiting", &sessionHub{created: time.Now()}, http.StatusServiceUnavailable, "session cache not synced"},
+		{"fallback", &sessionHub{created: time.Now().Add(-time.Hour)}, http.StatusOK, "reading from the API server"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &handlers{deps: &serverDeps{
+				client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
+				sessions: tc.hub,
+			}}
+			rec := httptest.NewRecorder()
+			h.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tc.wantCode || !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("readyz = %d %q, want %d %q", rec.Code, rec.Body.String(), tc.wantCode, tc.wantBody)
+			}
+		})
+	}
+}
+
+// testHub returns a hub whose history holds n changes with IDs 1..n, to
+// Sessions alternating between the namespaces "a" and "b".
+func testHub(n int) *sessionHub {
+	hub := &sessionHub{subs: map[*sessionSubscriber]struct{}{}, created: time.Now()}
+	for i := 1; i <= n; i++ {
+		ns := "a"
+		if i%2 == 0 {
+			ns = "b"
+		}
+		hub.broadcast(watch.Modified, &codespacev1.Session{ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns, Name: "s" + strconv.Itoa(i), ResourceVersion: strconv.Itoa(i),
+		}})
+	}
+	return hub
+}
+
+func changeIDs(changes []sessionChange) []string {
+	ids := []string{}
+	for _, c := range changes {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestSessionHubSubscribe(t *testing.T) {
+	ids := func(from, to int) []string {
+		out := []string{}
+		for i := from; i <= to; i++ {
+			out = append(out, strconv.Itoa(i))
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name        string
+		history     int
+		namespace   string
+		lastEventID string
+		wantResumed bool
+		wantReplay  []string
+	}{
+		{"first connect", 5, "", "", false, nil},
+		{"resume", 5, "", "2", true, ids(3, 5)},
+		{"resume in a namespace", 5, "a", "2", true, []string{"3", "5"}},
+		{"up to date", 5, "", "5", true, []string{}},
+		{"unknown ID", 5, "", "42", false, nil},
+		{"ID left the history", historySize + 10, "", "5", false, nil},
+		{"oldest kept ID", historySize + 10, "", "11", true, ids(12, historySize+10)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hub := testHub(tc.history)
+			s, replay, resumed, snapshotID := hub.Subscribe(tc.namespace, tc.lastEventID)
+			defer hub.Unsubscribe(s)
+			if resumed != tc.wantResumed {
+				t.Fatalf("resumed = %v, want %v", resumed, tc.wantResumed)
+			}
+			if snapshotID != strconv.Itoa(tc.history) {
+				t.Fatalf("snapshotID = %q, want %d", snapshotID, tc.history)
+			}
+			if !resumed {
+				if replay != nil {
+					t.Fatalf("replay = %v without a resume", changeIDs(replay))
+				}
+				return
+			}
+			if got := changeIDs(replay); strings.Join(got, ",") != strings.Join(tc.wantReplay, ",") {
+				t.Fatalf("replay = %v, want %v", got, tc.wantReplay)
+			}
+		})
+	}
+}
+
+func TestSessionHubWatchError(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		err        error
+		wantResync bool
+	}{
+		{"expired", apierrors.NewResourceExpired("too old resource version"), true},
+		{"gone", apierrors.NewGone("gone"), true},
+		{"other", apierrors.NewServiceUnavailable("down"), false},
+		{"network", errors.New("connection reset"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hub := testHub(3)
+			hub.synced.Store(true)
+			s, _, _, _ := hub.Subscribe("", "")
+			defer hub.Unsubscribe(s)
+
+			hub.handleWatchError(tc.err)
+			select {
+			case c := <-s.C():
+				if !tc.wantResync || !c.Resync || c.Session != nil {
+					t.Fatalf("got %+v, want a resync: %v", c, tc.wantResync)
+				}
+			default:
+				if tc.wantResync {
+					t.Fatal("no resync sent")
+				}
+			}
+
+			// The history may have gaps after a resync, so it cannot be resumed
+			_, _, resumed, _ := hub.Subscribe("", "2")
+			if resumed == tc.wantResync {
+				t.Fatalf("resumed = %v after %v", resumed, tc.err)
+			}
+		})
+	}
+}
+
+func TestWatchSessionsDirectExpired(t *testing.T) {
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "SessionList"})
+	dyn.PrependWatchReactor("sessions", func(k8stesting.Action) (bool, watch.Interface, error) {
+		return true, nil, apierrors.NewResourceExpired("too old resource version")
+	})
+	h := &handlers{deps: &serverDeps{dyn: dyn, config: &ServerConfig{}, instanceID: "test"}}
+
+	ch := h.watchSessionsDirect(t.Context(), "team", "5")
+	if c, ok := <-ch; !ok || !c.Resync {
+		t.Fatalf("got %+v, %v; want a resync", c, ok)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("channel not closed after the resync")
+	}
+}
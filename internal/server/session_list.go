@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codespace-operator/common/common/pkg/common"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+const maxListLimit = 500
+
+// sessionListQuery holds the paging, sorting and filter parameters of the list endpoint.
+type sessionListQuery struct {
+	Limit    int64
+	Continue string
+
+	// Sort is "", "creation", "name" or "phase"; Desc reverses it ("-name").
+	Sort string
+	Desc bool
+
+	Phases   map[string]bool
+	IDEs     map[string]bool
+	Search   string
+	Selector labels.Selector
+}
+
+func parseSessionListQuery(r *http.Request) (*sessionListQuery, error) {
+	v := r.URL.Query()
+	lq := &sessionListQuery{Continue: v.Get("continue"), Search: strings.ToLower(v.Get("search"))}
+
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid limit %q", s)
+		}
+		lq.Limit = min(n, maxListLimit)
+	}
+
+	sortBy := v.Get("sort")
+	lq.Desc = strings.HasPrefix(sortBy, "-")
+	lq.Sort = strings.TrimPrefix(sortBy, "-")
+	switch lq.Sort {
+	case "", "creation", "name", "phase":
+	default:
+		return nil, fmt.Errorf("invalid sort %q (want creation, name or phase)", sortBy)
+	}
+
+	lq.Phases = csvSet(v.Get("phase"))
+	lq.IDEs = csvSet(v.Get("ide"))
+
+	sel, err := sessionSelector(v.Get("labelSelector"), v.Get("creator"))
+	if err != nil {
+		return nil, err
+	}
+	lq.Selector = sel
+	return lq, nil
+}
+
+// sessionSelector combines a label selector with the created-by label of creator.
+func sessionSelector(labelSelector, creator string) (labels.Selector, error) {
+	sel, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid labelSelector: %w", err)
+	}
+	if creator != "" {
+		req, err := labels.NewRequirement(common.LabelCreatedBy, selection.Equals, []string{common.SubjectToLabelID(creator)})
+		if err != nil {
+			return nil, fmt.Errorf("invalid creator: %w", err)
+		}
+		sel = sel.Add(*req)
+	}
+	return sel, nil
+}
+
+// inMemory reports whether the query needs the full result set: sorting and
+// non-label filters are applied by the server, so the API server's continue
+// tokens cannot be used and paging is done over the cached list instead.
+func (lq *sessionListQuery) inMemory() bool {
+	return lq.Sort != "" || len(lq.Phases) > 0 || len(lq.IDEs) > 0 || lq.Search != ""
+}
+
+// listOptions are the options passed to the API server or cache.
+func (lq *sessionListQuery) listOptions(namespace string, instanceSel labels.Selector) []client.ListOption {
+	sel := lq.Selector
+	if instanceSel != nil {
+		reqs, _ := instanceSel.Requirements()
+		sel = sel.Add(reqs...)
+	}
+	opts := []client.ListOption{client.MatchingLabelsSelector{Selector: sel}}
+	if namespace != "" {
+		opts = append(opts, client.InNamespace(namespace))
+	}
+	return opts
+}
+
+func (lq *sessionListQuery) matches(s *codespacev1.Session) bool {
+	if len(lq.Phases) > 0 && !lq.Phases[strings.ToLower(s.Status.Phase)] {
+		return false
+	}
+	if len(lq.IDEs) > 0 && !lq.IDEs[strings.ToLower(s.Spec.Profile.IDE)] {
+		return false
+	}
+	if lq.Search != "" && !strings.Contains(strings.ToLower(s.Name), lq.Search) {
+		return false
+	}
+	return true
+}
+
+// sortSessions orders items by the query's sort key, falling back to
+// namespace/name (the API server's order) for ties and the default.
+func (lq *sessionListQuery) sortSessions(items []codespacev1.Session) {
+	byName := func(a, b *codespacev1.Session) bool {
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := &items[i], &items[j]
+		if lq.Desc {
+			a, b = b, a
+		}
+		switch lq.Sort {
+		case "creation":
+			if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+				return a.CreationTimestamp.Before(&b.CreationTimestamp)
+			}
+		case "phase":
+			if a.Status.Phase != b.Status.Phase {
+				return a.Status.Phase < b.Status.Phase
+			}
+		}
+		return byName(a, b)
+	})
+}
+
+// page cuts one page out of a filtered, sorted list. Its continue token is the
+// offset of the next page; unlike API server tokens it is not tied to a
+// resourceVersion, so items changing between pages may shift the window.
+func (lq *sessionListQuery) page(items []codespacev1.Session) ([]codespacev1.Session, string, error) {
+	offset := 0
+	if lq.Continue != "" {
+		var err error
+		if offset, err = decodeOffsetToken(lq.Continue); err != nil {
+			return nil, "", err
+		}
+	}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	items = items[offset:]
+	if lq.Limit == 0 || int64(len(items)) <= lq.Limit {
+		return items, "", nil
+	}
+	return items[:lq.Limit], encodeOffsetToken(offset + int(lq.Limit)), nil
+}
+
+type offsetToken struct {
+	Offset int `json:"offset"`
+}
+
+func encodeOffsetToken(offset int) string {
+	b, _ := json.Marshal(offsetToken{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeOffsetToken(s string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid continue token")
+	}
+	var t offsetToken
+	if err := json.Unmarshal(b, &t); err != nil || t.Offset < 0 {
+		return 0, fmt.Errorf("invalid continue token")
+	}
+	return t.Offset, nil
+}
+
+// csvSet turns "a,B" into {"a", "b"}.
+func csvSet(s string) map[string]bool {
+	out := map[string]bool{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out[strings.ToLower(v)] = true
+		}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+func testSession(ns, name, phase, ide string, created time.Time) codespacev1.Session {
+	return codespacev1.Session{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, CreationTimestamp: metav1.NewTime(created)},
+		Spec:       codespacev1.SessionSpec{Profile: codespacev1.ProfileSpec{IDE: ide}},
+		Status:     codespacev1.SessionStatus{Phase: phase},
+	}
+}
+
+func names(items []codespacev1.Session) []string {
+	out := make([]string, 0, len(items))
+	for _, s := range items {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+func TestParseSessionListQuery_Errors(t *testing.T) {
+	for _, qs := range []string{
+		"limit=0",
+		"limit=abc",
+		"sort=size",
+		"labelSelector=a%20in",
+	} {
+		t.Run(qs, func(t *testing.T) {
+			if _, err := parseSessionListQuery(httptest.NewRequest("GET", "/api/v1/server/sessions?"+qs, nil)); err == nil {
+				t.Fatalf("expected error for %q", qs)
+			}
+		})
+	}
+}
+
+func TestSessionListQuery_FilterSortPage(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	all := []codespacev1.Session{
+		testSession("default", "beta", "Ready", "vscode", base.Add(2*time.Hour)),
+		testSession("default", "alpha", "Pending", "jupyter", base.Add(3*time.Hour)),
+		testSession("default", "gamma", "Ready", "vscode", base),
+		testSession("other", "alphabet", "Ready", "vscode", base.Add(time.Hour)),
+	}
+
+	cases := []struct {
+		name string
+		qs   string
+		want []string
+	}{
+		{"default order is namespace/name", "", []string{"alpha", "beta", "gamma", "alphabet"}},
+		{"newest first", "sort=-creation", []string{"alpha", "beta", "alphabet", "gamma"}},
+		{"phase filter is case-insensitive", "phase=ready&sort=name", []string{"beta", "gamma", "alphabet"}},
+		{"ide and search", "ide=vscode&search=ALPH", []string{"alphabet"}},
+		{"first page", "sort=creation&limit=2", []string{"gamma", "alphabet"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lq, err := parseSessionListQuery(httptest.NewRequest("GET", "/api/v1/server/sessions?"+tc.qs, nil))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			var matched []codespacev1.Session
+			for _, s := range all {
+				if lq.matches(&s) {
+					matched = append(matched, s)
+				}
+			}
+			lq.sortSessions(matched)
+			page, _, err := lq.page(matched)
+			if err != nil {
+				t.Fatalf("page: %v", err)
+			}
+			if got := names(page); !slices.Equal(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSessionListQuery_ContinueToken(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []codespacev1.Session{
+		testSession("default", "a", "Ready", "vscode", base),
+		testSession("default", "b", "Ready", "vscode", base),
+		testSession("default", "c", "Ready", "vscode", base),
+	}
+
+	lq := &sessionListQuery{Limit: 2}
+	first, token, err := lq.page(items)
+	if err != nil || token == "" || len(first) != 2 {
+		t.Fatalf("first page: items=%v token=%q err=%v", names(first), token, err)
+	}
+
+	lq.Continue = token
+	second, token, err := lq.page(items)
+	if err != nil || token != "" || !slices.Equal(names(second), []string{"c"}) {
+		t.Fatalf("second page: items=%v token=%q err=%v", names(second), token, err)
+	}
+
+	lq.Continue = "not-a-token"
+	if _, _, err := lq.page(items); err == nil {
+		t.Fatal("expected error for invalid continue token")
+	}
+}
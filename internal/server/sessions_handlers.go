@@ -4,16 +4,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	auth "github.com/codespace-operator/common/auth/pkg/auth"
 	common "github.com/codespace-operator/common/common/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
@@ -33,6 +35,12 @@ type SessionCreateRequest struct {
 	Scratch   *codespacev1.PVCSpec    `json:"scratch,omitempty"`
 	Network   *codespacev1.NetSpec    `json:"networking,omitempty"`
 	Replicas  *int32                  `json:"replicas,omitempty" example:"1"`
+	// ImagePullSecrets in the session namespace, for images in private registries
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Ports of the IDE container to expose besides the IDE, by path or subdomain
+	Ports []codespacev1.PortSpec `json:"ports,omitempty"`
+	// Sidecars to run next to the IDE, e.g. a database or Docker-in-Docker
+	Sidecars []codespacev1.SidecarSpec `json:"sidecars,omitempty"`
 }
 
 // SessionScaleRequest represents the request body for scaling a session
@@ -44,10 +52,26 @@ type SessionScaleRequest struct {
 // SessionListResponse wraps the session list with metadata
 // @Description Response containing list of sessions with metadata
 type SessionListResponse struct {
-	Items      []codespacev1.Session `json:"items"`
-	Total      int                   `json:"total" example:"5"`
-	Namespaces []string              `json:"namespaces,omitempty" example:"default,kube-system"`
-	Filtered   bool                  `json:"filtered,omitempty"`
+	Items []codespacev1.Session `json:"items"`
+	// Total counts every matching session, not just this page
+	Total int `json:"total" example:"5"`
+	// Continue fetches the next page; empty on the last page
+	Continue   string   `json:"continue,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty" example:"default,kube-system"`
+	Filtered   bool     `json:"filtered,omitempty"`
+}
+
+// SessionStreamSnapshot is the "snapshot" event of the session stream
+// @Description Full list of sessions a stream starts from
+type SessionStreamSnapshot struct {
+	Items           []codespacev1.Session `json:"items"`
+	ResourceVersion string                `json:"resourceVersion,omitempty" example:"123456"`
+}
+
+// SessionStreamResync is the "resync" event of the session stream
+// @Description Tells the client to discard its state; a snapshot follows
+type SessionStreamResync struct {
+	Reason string `json:"reason" example:"expired"`
 }
 
 // handleSessionOperations handles the main session endpoint operations
@@ -73,9 +97,40 @@ func (h *handlers) handleSessionOperationsWithPath(w http.ResponseWriter, r *htt
 		return
 	}
 
-	// Check if this is a scale operation
-	if len(parts) == 3 && parts[2] == "scale" {
-		h.handleScaleSession(w, r)
+	// Sub-resource operations
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "scale":
+			h.handleScaleSession(w, r)
+			return
+		case "restart":
+			h.handleRestartSession(w, r)
+			return
+		case "events":
+			h.handleSessionEvents(w, r)
+			return
+		case "logs":
+			h.handleSessionLogs(w, r)
+			return
+		case "exec":
+			h.handleSessionExec(w, r)
+			return
+		case "details":
+			h.handleSessionDetails(w, r)
+			return
+		case "clone":
+			h.handleCloneSession(w, r)
+			return
+		case "snapshots":
+			h.handleSessionSnapshots(w, r)
+			return
+		case "restore":
+			h.handleRestoreSession(w, r)
+			return
+		}
+	}
+	if len(parts) == 4 && parts[2] == "snapshots" {
+		h.handleSessionSnapshots(w, r)
 		return
 	}
 
@@ -96,7 +151,10 @@ func (h *handlers) handleSessionOperationsWithPath(w http.ResponseWriter, r *htt
 
 // @Summary List sessions
 // @ID listSessions
-// @Description Get a list of codespace sessions, optionally across all namespaces
+// @Description Get a list of codespace sessions, optionally across all namespaces.
+// @Description Plain limit/continue paging uses the API server's continue tokens. With a sort or a
+// @Description phase/IDE/search filter the server sorts and pages the full list itself; its continue tokens
+// @Description are only valid for the same query.
 // @Tags sessions
 // @Accept json
 // @Produce json
@@ -104,9 +162,19 @@ func (h *handlers) handleSessionOperationsWithPath(w http.ResponseWriter, r *htt
 // @Security CookieAuth
 // @Param namespace query string false "Target namespace" default(default)
 // @Param all query boolean false "List sessions across all namespaces"
+// @Param limit query int false "Maximum number of items to return (max 500)"
+// @Param continue query string false "Continue token from the previous page"
+// @Param sort query string false "Sort by creation, name or phase; prefix with - for descending" Enums(creation,-creation,name,-name,phase,-phase)
+// @Param phase query string false "Comma-separated phases to include, e.g. Ready,Pending"
+// @Param ide query string false "Comma-separated IDEs to include, e.g. vscode,jupyter"
+// @Param creator query string false "Only sessions created by this subject"
+// @Param labelSelector query string false "Kubernetes label selector"
+// @Param search query string false "Case-insensitive substring of the session name"
 // @Success 200 {object} SessionListResponse
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
 // @Router /api/v1/server/sessions [get]
 func (h *handlers) handleListSessions(w http.ResponseWriter, r *http.Request) {
 
@@ -127,47 +195,91 @@ func (h *handlers) handleListSessions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var sessions []codespacev1.Session
-	var namespaces []string
+	lq, err := parseSessionListQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	listNamespace := namespace
 	if allNamespaces {
-		var sl codespacev1.SessionList
-		opts := []client.ListOption{}
-		if !h.deps.config.ClusterScope {
-			opts = append(opts, client.MatchingLabels{common.InstanceIDLabel: h.deps.instanceID})
+		listNamespace = ""
+	}
+	var instanceSel labels.Selector
+	if !h.deps.config.ClusterScope {
+		instanceSel = labels.SelectorFromSet(labels.Set{common.InstanceIDLabel: h.deps.instanceID})
+	}
+	opts := lq.listOptions(listNamespace, instanceSel)
+
+	// keep RBAC namespace filter for non-admins
+	allowed := func(s *codespacev1.Session) bool {
+		if !allNamespaces {
+			return true
 		}
+		canAccess, err := h.deps.rbac.Enforce(pr.Subject, pr.Roles, SESSION_RESOURCE_STRING, "list", s.Namespace)
+		return err == nil && canAccess
+	}
+
+	var sessions []codespacev1.Session
+	var continueToken string
+	var total int
 
-		if err := h.deps.client.List(r.Context(), &sl, opts...); err != nil {
-			logger.Error("Failed to list sessions across all namespaces", "err", err, "user", pr.Subject)
+	if lq.inMemory() || (lq.Limit == 0 && lq.Continue == "") {
+		// Sorted/filtered views are built from the full (cached) list and paged here
+		var sl codespacev1.SessionList
+		if err := h.sessionReader().List(r.Context(), &sl, opts...); err != nil {
+			logger.Error("Failed to list sessions", "namespace", listNamespace, "err", err, "user", pr.Subject)
 			errJSON(w, fmt.Errorf("failed to list sessions: %w", err))
 			return
 		}
-		nsSet := make(map[string]struct{})
-		for _, s := range sl.Items {
-			// keep RBAC namespace filter for non-admins
-			if canAccess, err := h.deps.rbac.Enforce(pr.Subject, pr.Roles, SESSION_RESOURCE_STRING, "list", s.Namespace); err != nil || !canAccess {
-				continue
+		matched := sl.Items[:0]
+		for i := range sl.Items {
+			if allowed(&sl.Items[i]) && lq.matches(&sl.Items[i]) {
+				matched = append(matched, sl.Items[i])
 			}
-			sessions = append(sessions, s)
-			nsSet[s.Namespace] = struct{}{}
 		}
-		for ns := range nsSet {
-			namespaces = append(namespaces, ns)
+		lq.sortSessions(matched)
+		total = len(matched)
+		if sessions, continueToken, err = lq.page(matched); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 	} else {
-		var sessionList codespacev1.SessionList
-		opts := []client.ListOption{
-			client.InNamespace(namespace),
+		// Plain paging goes to the API server so continue tokens are consistent
+		var sl codespacev1.SessionList
+		pageOpts := append([]client.ListOption{client.Limit(lq.Limit), client.Continue(lq.Continue)}, opts...)
+		if err := h.deps.client.List(r.Context(), &sl, pageOpts...); err != nil {
+			switch {
+			case apierrors.IsResourceExpired(err):
+				http.Error(w, "continue token expired, restart the listing", http.StatusGone)
+			case apierrors.IsBadRequest(err):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				logger.Error("Failed to list sessions", "namespace", listNamespace, "err", err, "user", pr.Subject)
+				errJSON(w, fmt.Errorf("failed to list sessions: %w", err))
+			}
+			return
 		}
-		if !h.deps.config.ClusterScope {
-			opts = append(opts, client.MatchingLabels{common.InstanceIDLabel: h.deps.instanceID})
+		for i := range sl.Items {
+			if allowed(&sl.Items[i]) {
+				sessions = append(sessions, sl.Items[i])
+			}
 		}
-		if err := h.deps.client.List(r.Context(), &sessionList, opts...); err != nil {
-			logger.Error("Failed to list sessions", "namespace", namespace, "err", err, "user", pr.Subject)
-			errJSON(w, fmt.Errorf("failed to list sessions in namespace %s: %w", namespace, err))
-			return
+		continueToken = sl.Continue
+		total = h.countSessions(r, opts, allowed, len(sessions), sl.RemainingItemCount)
+	}
+
+	var namespaces []string
+	if allNamespaces {
+		nsSet := make(map[string]struct{})
+		for _, s := range sessions {
+			nsSet[s.Namespace] = struct{}{}
+		}
+		for ns := range nsSet {
+			namespaces = append(namespaces, ns)
 		}
-		sessions = sessionList.Items
+		sort.Strings(namespaces)
+	} else {
 		namespaces = []string{namespace}
 	}
 
@@ -209,10 +321,33 @@ func (h *handlers) handleListSessions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, SessionListResponse{
-		Items: sessions, Total: len(sessions), Namespaces: namespaces, Filtered: allNamespaces,
+		Items: sessions, Total: total, Continue: continueToken, Namespaces: namespaces, Filtered: allNamespaces,
 	})
 }
 
+// countSessions returns how many sessions match a paged listing in total. The
+// cache answers without another API call; before it has synced, the API
+// server's remainingItemCount is the best estimate.
+func (h *handlers) countSessions(r *http.Request, opts []client.ListOption, allowed func(*codespacev1.Session) bool, page int, remaining *int64) int {
+	if h.deps.sessions == nil || !h.deps.sessions.Synced() {
+		if remaining != nil {
+			return page + int(*remaining)
+		}
+		return page
+	}
+	var sl codespacev1.SessionList
+	if err := h.deps.sessions.Reader().List(r.Context(), &sl, opts...); err != nil {
+		return page
+	}
+	n := 0
+	for i := range sl.Items {
+		if allowed(&sl.Items[i]) {
+			n++
+		}
+	}
+	return n
+}
+
 // @Summary Create session
 // @Description Create a new codespace session
 // @Tags sessions
@@ -256,18 +391,29 @@ func (h *handlers) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "container image is required", http.StatusBadRequest)
 		return
 	}
+	for _, v := range []struct {
+		volume string
+		spec   *codespacev1.PVCSpec
+	}{{"home", req.Home}, {"scratch", req.Scratch}} {
+		if err := checkDataSource(v.volume, nil, v.spec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := checkPorts(req.Profile.IDE, req.Ports); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Check RBAC permissions for the target namespace
 	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "create", req.Namespace)
 	if !ok {
 		return
 	}
-
-	creatorID := common.SubjectToLabelID(pr.Subject)
-	ann := map[string]string{
-		"codespace.dev/created-at": time.Now().Format(time.RFC3339),
-		common.AnnotationCreatedBy: pr.Subject, // raw, reversible
+	if !h.canRunSidecars(w, pr, req.Namespace, req.Sidecars, nil) {
+		return
 	}
+
 	// Construct the session object
 	session := &codespacev1.Session{
 		TypeMeta: metav1.TypeMeta{
@@ -275,20 +421,10 @@ func (h *handlers) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 			Kind:       "Session",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      req.Name,
-			Namespace: req.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/name":       "codespace-session",
-				"app.kubernetes.io/instance":   req.Name,
-				"app.kubernetes.io/part-of":    h.deps.config.AppName,
-				"app.kubernetes.io/managed-by": h.deps.config.AppName,
-				common.LabelCreatedBy:          creatorID,
-				common.InstanceIDLabel:         h.deps.instanceID,
-				common.LabelManagerType:        h.deps.manager.Type,
-				common.LabelManagerNamespace:   h.deps.manager.Namespace,
-				common.LabelManagerName:        h.deps.manager.Name,
-			},
-			Annotations: ann,
+			Name:        req.Name,
+			Namespace:   req.Namespace,
+			Labels:      h.newSessionLabels(req.Name, pr.Subject),
+			Annotations: newSessionAnnotations(pr.Subject),
 		},
 		Spec: codespacev1.SessionSpec{
 			Profile:    req.Profile,
@@ -297,6 +433,10 @@ func (h *handlers) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 			Scratch:    req.Scratch,
 			Networking: req.Network,
 			Replicas:   req.Replicas,
+
+			ImagePullSecrets: req.ImagePullSecrets,
+			Ports:            req.Ports,
+			Sidecars:         req.Sidecars,
 		},
 	}
 
@@ -317,6 +457,28 @@ func (h *handlers) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, session)
 }
 
+// newSessionLabels are the labels the server puts on every session it creates.
+func (h *handlers) newSessionLabels(name, subject string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "codespace-session",
+		"app.kubernetes.io/instance":   name,
+		"app.kubernetes.io/part-of":    h.deps.config.AppName,
+		"app.kubernetes.io/managed-by": h.deps.config.AppName,
+		common.LabelCreatedBy:          common.SubjectToLabelID(subject),
+		common.InstanceIDLabel:         h.deps.instanceID,
+		common.LabelManagerType:        h.deps.manager.Type,
+		common.LabelManagerNamespace:   h.deps.manager.Namespace,
+		common.LabelManagerName:        h.deps.manager.Name,
+	}
+}
+
+func newSessionAnnotations(subject string) map[string]string {
+	return map[string]string{
+		"codespace.dev/created-at": time.Now().Format(time.RFC3339),
+		common.AnnotationCreatedBy: subject, // raw, reversible
+	}
+}
+
 // @Summary Get session
 // @Description Get details of a specific session
 // @Tags sessions
@@ -496,6 +658,73 @@ func (h *handlers) handleScaleSession(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, session)
 }
 
+// @Summary Restart session
+// @ID restartSession
+// @Description Restart the session's pods by rolling its Deployment. Sessions with ReadWriteOnce volumes are
+// @Description recreated, the others rolled one pod at a time; progress is reported in status.rollout.
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Success 202 {object} codespacev1.Session
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/restart [post]
+func (h *handlers) handleRestartSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := sessionPathParts(r)
+	if len(parts) < 3 || parts[2] != "restart" {
+		http.Error(w, "invalid path - expected /api/v1/server/sessions/{namespace}/{name}/restart", http.StatusBadRequest)
+		return
+	}
+	namespace, name := parts[0], parts[1]
+
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "restart", namespace)
+	if !ok {
+		return
+	}
+
+	session, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		writeSessionLookupError(w, err)
+		return
+	}
+	if session.Spec.Replicas != nil && *session.Spec.Replicas == 0 {
+		http.Error(w, "session is suspended", http.StatusConflict)
+		return
+	}
+	if session.Annotations[codespacev1.RestoreSnapshotAnnotation] != "" {
+		http.Error(w, "a restore is in progress", http.StatusConflict)
+		return
+	}
+
+	restartedAt := time.Now().UTC().Format(time.RFC3339)
+	patch := client.MergeFrom(session.DeepCopy())
+	if session.Annotations == nil {
+		session.Annotations = map[string]string{}
+	}
+	session.Annotations[codespacev1.RestartAnnotation] = restartedAt
+	if err := h.deps.client.Patch(r.Context(), session, patch); err != nil {
+		logger.Error("Failed to request restart", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		errJSON(w, fmt.Errorf("failed to request restart: %w", err))
+		return
+	}
+
+	h.audit(r, "session.restart", "namespace", namespace, "session", name)
+	h.recordSessionEvent(r.Context(), session, corev1.EventTypeNormal, "RestartRequested",
+		fmt.Sprintf("Restart requested by %s", pr.Subject))
+
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, session)
+}
+
 // @Summary Update session
 // @Description Update a session (full replacement)
 // @ID updateSession
@@ -556,6 +785,24 @@ func (h *handlers) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Volumes can grow but never shrink
+		for _, v := range []struct {
+			volume           string
+			current, updated *codespacev1.PVCSpec
+		}{{"home", session.Spec.Home, req.Home}, {"scratch", session.Spec.Scratch, req.Scratch}} {
+			if err := checkVolumeShrink(v.volume, v.current, v.updated); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := checkDataSource(v.volume, v.current, v.updated); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if !h.canRunSidecars(w, pr, namespace, req.Sidecars, session.Spec.Sidecars) {
+			return
+		}
+
 		// Preserve metadata but update spec
 		session.Spec = codespacev1.SessionSpec{
 			Profile:    req.Profile,
@@ -564,6 +811,10 @@ func (h *handlers) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 			Scratch:    req.Scratch,
 			Networking: req.Network,
 			Replicas:   req.Replicas,
+
+			ImagePullSecrets: req.ImagePullSecrets,
+			Ports:            req.Ports,
+			Sidecars:         req.Sidecars,
 		}
 
 		if req.Auth != nil {
@@ -594,6 +845,12 @@ func (h *handlers) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// A PATCH can change the IDE and with it the IDE's port
+	if err := checkPorts(session.Spec.Profile.IDE, session.Spec.Ports); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Add update metadata
 	if session.Annotations == nil {
 		session.Annotations = make(map[string]string)
@@ -614,8 +871,74 @@ func (h *handlers) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, session)
 }
 
+// checkVolumeShrink rejects an update that makes a volume smaller. Growing is
+// left to the controller, which checks whether the StorageClass allows it.
+func checkVolumeShrink(volume string, old, updated *codespacev1.PVCSpec) error {
+	if old == nil || updated == nil {
+		return nil
+	}
+	oldSize, err := resource.ParseQuantity(old.Size)
+	if err != nil {
+		return nil
+	}
+	newSize, err := resource.ParseQuantity(updated.Size)
+	if err != nil {
+		return fmt.Errorf("invalid %s size %q", volume, updated.Size)
+	}
+	if newSize.Cmp(oldSize) < 0 {
+		return fmt.Errorf("%s volume cannot shrink from %s to %s", volume, old.Size, updated.Size)
+	}
+	return nil
+}
+
+// checkPorts rejects extra ports the Session could not run with: the CRD
+// validates the same, but this returns a clear error before the request is sent.
+// Each port gets a containerPort on the IDE container and a Service port of the
+// same number, so it must not repeat the IDE's port, the "http" Service port 80,
+// another extra port or the auth proxy's ports.
+func checkPorts(ide string, ports []codespacev1.PortSpec) error {
+	seen := map[int32]string{}
+	for _, p := range ports {
+		switch {
+		case p.ContainerPort == 80:
+			return fmt.Errorf("port %s: 80 is the IDE's Service port", p.Name)
+		case p.ContainerPort == codespacev1.IDEPort(ide):
+			return fmt.Errorf("port %s: %d is the IDE's port", p.Name, p.ContainerPort)
+		case p.ContainerPort >= 4180 && p.ContainerPort <= 4199:
+			return fmt.Errorf("port %s: 4180-4199 are reserved for the auth proxy", p.Name)
+		}
+		if other, ok := seen[p.ContainerPort]; ok {
+			return fmt.Errorf("ports %s and %s both use containerPort %d", other, p.Name, p.ContainerPort)
+		}
+		seen[p.ContainerPort] = p.Name
+	}
+	return nil
+}
+
+// checkDataSource rejects a volume dataSource from the client: it reads
+// whatever PVC or snapshot it names, so only the clone handler sets it, after
+// checking access to the source. The current dataSource is carried over to
+// updated, so resending it is fine.
+func checkDataSource(volume string, current, updated *codespacev1.PVCSpec) error {
+	if updated == nil {
+		return nil
+	}
+	var ds *corev1.TypedLocalObjectReference
+	if current != nil {
+		ds = current.DataSource
+	}
+	if updated.DataSource != nil && !equality.Semantic.DeepEqual(updated.DataSource, ds) {
+		return fmt.Errorf("%s.dataSource cannot be set; clone the session instead", volume)
+	}
+	updated.DataSource = ds
+	return nil
+}
+
 // @Summary Stream sessions
-// @Description Stream real-time session updates via Server-Sent Events
+// @Description Stream real-time session updates via Server-Sent Events.
+// @Description A new stream starts with a "snapshot" event holding the full list. Every "message" event carries the
+// @Description Session's resourceVersion as its SSE id; reconnecting with Last-Event-ID replays the missed changes.
+// @Description If that position is too old, or the server's watch expired, a "resync" event is sent followed by a new snapshot.
 // @Tags sessions
 // @ID streamSessions
 // @Produce text/event-stream
@@ -623,6 +946,8 @@ func (h *handlers) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 // @Security CookieAuth
 // @Param namespace query string false "Target namespace" default(default)
 // @Param all query boolean false "Stream sessions from all namespaces"
+// @Param Last-Event-ID header string false "Resume after this event ID (resourceVersion)"
+// @Param lastEventId query string false "Same as the Last-Event-ID header, for clients that cannot set it"
 // @Success 200 {string} string "SSE stream"
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
@@ -655,34 +980,48 @@ func (h *handlers) handleStreamSessions(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Start watching
-	var watcher watch.Interface
-	var err error
-
-	opts := metav1.ListOptions{Watch: true}
-	if !h.deps.config.ClusterScope {
-		opts.LabelSelector = labels.Set{common.InstanceIDLabel: h.deps.instanceID}.AsSelector().String()
-	}
-
-	// then use 'opts' in the watch calls
+	subNamespace := namespace
 	if allNamespaces {
-		watcher, err = h.deps.dyn.Resource(gvr).Watch(r.Context(), opts)
-	} else {
-		watcher, err = h.deps.dyn.Resource(gvr).Namespace(namespace).Watch(r.Context(), opts)
-	}
-
-	if err != nil {
-		logger.Error("Failed to start session watch", "namespace", namespace, "all", allNamespaces, "err", err, "user", pr.Subject)
-		errJSON(w, fmt.Errorf("failed to start watch: %w", err))
+		subNamespace = ""
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	// All streams share the server's single Session watch. Without it, each
+	// stream watches the API server itself, resuming from the resourceVersion
+	// the client last saw.
+	hub := h.deps.sessions
+	var (
+		reader     client.Reader = hub.Reader()
+		events     <-chan sessionChange
+		replay     []sessionChange
+		resumed    bool
+		snapshotID string
+		direct     = !hub.WaitForSync(r.Context())
+	)
+	if r.Context().Err() != nil {
 		return
 	}
-	defer watcher.Stop()
+	if direct {
+		reader = h.deps.client
+		if lastEventID != "" {
+			events, resumed = h.watchSessionsDirect(r.Context(), subNamespace, lastEventID), true
+		}
+	} else {
+		var sub *sessionSubscriber
+		sub, replay, resumed, snapshotID = hub.Subscribe(subNamespace, lastEventID)
+		defer hub.Unsubscribe(sub)
+		events = sub.C()
+	}
 
 	// Send initial ping
 	writeSSE(w, "ping", map[string]string{"status": "connected"})
 	flusher.Flush()
 
-	logger.Info("Started session stream", "namespace", namespace, "all", allNamespaces, "user", pr.Subject)
+	logger.Info("Started session stream", "namespace", namespace, "all", allNamespaces, "resumed", resumed, "direct", direct, "user", pr.Subject)
+	defer trackStream("sessions")()
 
 	// Optional enrichment index for cluster-scope
 	var idx map[string]common.AnchorMeta
@@ -690,6 +1029,86 @@ func (h *handlers) handleStreamSessions(w http.ResponseWriter, r *http.Request)
 		idx = common.BuildInstanceMetaIndex(r.Context(), h.deps.client, h.deps.config.AppName)
 	}
 
+	visible := func(session *codespacev1.Session) bool {
+		// Apply namespace-level filtering for cross-namespace watches
+		if allNamespaces {
+			if canAccess, err := h.deps.rbac.Enforce(pr.Subject, pr.Roles, "session", "list", session.Namespace); err != nil || !canAccess {
+				return false
+			}
+		}
+
+		// Enrich labels on the fly (cluster-scope)
+		if h.deps.config.ClusterScope {
+			if session.Labels == nil {
+				session.Labels = map[string]string{}
+			}
+			if meta, ok := idx[session.Labels[common.InstanceIDLabel]]; ok {
+				if session.Labels[common.LabelManagerType] == "" {
+					session.Labels[common.LabelManagerType] = meta.Type
+				}
+				if session.Labels[common.LabelManagerNamespace] == "" {
+					session.Labels[common.LabelManagerNamespace] = meta.Namespace
+				}
+				if session.Labels[common.LabelManagerName] == "" && meta.Name != "" {
+					session.Labels[common.LabelManagerName] = meta.Name
+				}
+			}
+		}
+		return true
+	}
+
+	send := func(ev sessionChange) {
+		if !visible(ev.Session) {
+			return
+		}
+		payload := map[string]interface{}{
+			"type":   string(ev.Type),
+			"object": ev.Session,
+		}
+		writeSSEWithID(w, ev.ID, "message", payload)
+	}
+
+	// snapshot sends the full current state; clients replace what they hold.
+	// Direct streams tag it with the list's resourceVersion and watch from there.
+	snapshot := func(id string) {
+		var existing codespacev1.SessionList
+		listOpts := []client.ListOption{}
+		if !allNamespaces {
+			listOpts = append(listOpts, client.InNamespace(namespace))
+		}
+		if direct && !h.deps.config.ClusterScope {
+			listOpts = append(listOpts, client.MatchingLabels{common.InstanceIDLabel: h.deps.instanceID})
+		}
+		if err := reader.List(r.Context(), &existing, listOpts...); err != nil {
+			logger.Error("Failed to list sessions for stream", "namespace", namespace, "err", err, "user", pr.Subject)
+		}
+		if direct {
+			id = existing.ResourceVersion
+			events = h.watchSessionsDirect(r.Context(), subNamespace, id)
+		}
+		items := make([]codespacev1.Session, 0, len(existing.Items))
+		for i := range existing.Items {
+			if visible(&existing.Items[i]) {
+				items = append(items, existing.Items[i])
+			}
+		}
+		writeSSEWithID(w, id, "snapshot", SessionStreamSnapshot{Items: items, ResourceVersion: id})
+	}
+
+	switch {
+	case resumed:
+		for _, ev := range replay {
+			send(ev)
+		}
+	case lastEventID != "":
+		// The client's position is no longer in the history
+		writeSSE(w, "resync", SessionStreamResync{Reason: "expired"})
+		snapshot(snapshotID)
+	default:
+		snapshot(snapshotID)
+	}
+	flusher.Flush()
+
 	// Keep-alive
 	ticker := time.NewTicker(25 * time.Second)
 	defer ticker.Stop()
@@ -702,59 +1121,17 @@ func (h *handlers) handleStreamSessions(w http.ResponseWriter, r *http.Request)
 		case <-ticker.C:
 			writeSSE(w, "ping", map[string]string{"timestamp": time.Now().Format(time.RFC3339)})
 			flusher.Flush()
-		case ev, ok := <-watcher.ResultChan():
+		case ev, ok := <-events:
 			if !ok {
 				logger.Debug("Session stream ended by server", "user", pr.Subject)
 				return
 			}
-
-			if ev.Type == watch.Error {
-				logger.Error("Watch error in session stream", "err", ev.Object, "user", pr.Subject)
-				continue
-			}
-
-			u, ok := ev.Object.(*unstructured.Unstructured)
-			if !ok {
-				continue
-			}
-
-			// Convert to typed session
-			var session codespacev1.Session
-			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &session); err != nil {
-				logger.Warn("Failed to convert session in stream", "name", u.GetName(), "err", err)
-				continue
-			}
-
-			// Apply namespace-level filtering for cross-namespace watches
-			if allNamespaces {
-				if canAccess, err := h.deps.rbac.Enforce(pr.Subject, pr.Roles, "session", "list", session.Namespace); err != nil || !canAccess {
-					continue
-				}
-			}
-
-			// Enrich labels on the fly (cluster-scope)
-			if h.deps.config.ClusterScope {
-				if session.Labels == nil {
-					session.Labels = map[string]string{}
-				}
-				if meta, ok := idx[session.Labels[common.InstanceIDLabel]]; ok {
-					if session.Labels[common.LabelManagerType] == "" {
-						session.Labels[common.LabelManagerType] = meta.Type
-					}
-					if session.Labels[common.LabelManagerNamespace] == "" {
-						session.Labels[common.LabelManagerNamespace] = meta.Namespace
-					}
-					if session.Labels[common.LabelManagerName] == "" && meta.Name != "" {
-						session.Labels[common.LabelManagerName] = meta.Name
-					}
-				}
-			}
-
-			payload := map[string]interface{}{
-				"type":   string(ev.Type),
-				"object": session,
+			if ev.Resync {
+				writeSSE(w, "resync", SessionStreamResync{Reason: "watch expired"})
+				snapshot(hub.LastID())
+			} else {
+				send(ev)
 			}
-			writeSSE(w, "message", payload)
 			flusher.Flush()
 		}
 	}
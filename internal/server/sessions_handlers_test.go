@@ -0,0 +1,87 @@
+package server
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+func TestCheckDataSource(t *testing.T) {
+	pvc := func(name string) *corev1.TypedLocalObjectReference {
+		return &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: name}
+	}
+	for _, tc := range []struct {
+		name             string
+		current, updated *codespacev1.PVCSpec
+		wantErr          bool
+		want             *corev1.TypedLocalObjectReference
+	}{
+		{"no volume", nil, nil, false, nil},
+		{"create without", nil, &codespacev1.PVCSpec{Size: "1Gi"}, false, nil},
+		{"create with", nil, &codespacev1.PVCSpec{Size: "1Gi", DataSource: pvc("other-home")}, true, nil},
+		{"update keeps", &codespacev1.PVCSpec{DataSource: pvc("src")}, &codespacev1.PVCSpec{Size: "2Gi"}, false, pvc("src")},
+		{"update resends", &codespacev1.PVCSpec{DataSource: pvc("src")}, &codespacev1.PVCSpec{DataSource: pvc("src")}, false, pvc("src")},
+		{"update changes", &codespacev1.PVCSpec{DataSource: pvc("src")}, &codespacev1.PVCSpec{DataSource: pvc("other-home")}, true, nil},
+		{"update adds", &codespacev1.PVCSpec{}, &codespacev1.PVCSpec{DataSource: pvc("other-home")}, true, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkDataSource("home", tc.current, tc.updated)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("checkDataSource() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && tc.updated != nil && !equality.Semantic.DeepEqual(tc.updated.DataSource, tc.want) {
+				t.Fatalf("dataSource = %v, want %v", tc.updated.DataSource, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckVolumeShrink(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		old, updated *codespacev1.PVCSpec
+		wantErr      bool
+	}{
+		{"no volume before", nil, &codespacev1.PVCSpec{Size: "1Gi"}, false},
+		{"volume removed", &codespacev1.PVCSpec{Size: "1Gi"}, nil, false},
+		{"same size", &codespacev1.PVCSpec{Size: "1Gi"}, &codespacev1.PVCSpec{Size: "1Gi"}, false},
+		{"same size in other units", &codespacev1.PVCSpec{Size: "1Gi"}, &codespacev1.PVCSpec{Size: "1024Mi"}, false},
+		{"grow", &codespacev1.PVCSpec{Size: "1Gi"}, &codespacev1.PVCSpec{Size: "2Gi"}, false},
+		{"shrink", &codespacev1.PVCSpec{Size: "2Gi"}, &codespacev1.PVCSpec{Size: "1Gi"}, true},
+		{"invalid new size", &codespacev1.PVCSpec{Size: "1Gi"}, &codespacev1.PVCSpec{Size: "big"}, true},
+		{"invalid old size", &codespacev1.PVCSpec{Size: "big"}, &codespacev1.PVCSpec{Size: "1Gi"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := checkVolumeShrink("home", tc.old, tc.updated); (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckPorts(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		ide     string
+		ports   []codespacev1.PortSpec
+		wantErr bool
+	}{
+		{"no ports", "jupyterlab", nil, false},
+		{"distinct ports", "jupyterlab", []codespacev1.PortSpec{{Name: "web", ContainerPort: 3000}, {Name: "tb", ContainerPort: 6006}}, false},
+		{"service port", "jupyterlab", []codespacev1.PortSpec{{Name: "web", ContainerPort: 80}}, true},
+		{"jupyter port", "jupyterlab", []codespacev1.PortSpec{{Name: "web", ContainerPort: 8888}}, true},
+		{"vscode port", "vscode", []codespacev1.PortSpec{{Name: "web", ContainerPort: 8080}}, true},
+		{"other IDE's port", "vscode", []codespacev1.PortSpec{{Name: "web", ContainerPort: 8888}}, false},
+		{"auth proxy port", "vscode", []codespacev1.PortSpec{{Name: "web", ContainerPort: 4181}}, true},
+		{"duplicate", "jupyterlab", []codespacev1.PortSpec{{Name: "web", ContainerPort: 3000}, {Name: "api", ContainerPort: 3000}}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := checkPorts(tc.ide, tc.ports); (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
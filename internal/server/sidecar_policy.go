@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	rbac "github.com/codespace-operator/common/rbac/pkg/rbac"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+// privilegedAction is the RBAC action needed to give sidecars more than a
+// regular container has. Only admins have it by default.
+const privilegedAction = "privileged"
+
+// sidecarPrivilege returns what the sidecar asks for beyond a regular
+// container, or "" if nothing: privileged mode, privilege escalation, added
+// capabilities, unconfined profiles, host ports or a Windows host process.
+func sidecarPrivilege(s codespacev1.SidecarSpec) string {
+	for _, p := range s.Ports {
+		if p.HostPort != 0 {
+			return "hostPort"
+		}
+	}
+	sc := s.SecurityContext
+	if sc == nil {
+		return ""
+	}
+	switch {
+	case sc.Privileged != nil && *sc.Privileged:
+		return "privileged"
+	case sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation:
+		return "allowPrivilegeEscalation"
+	case sc.Capabilities != nil && len(sc.Capabilities.Add) > 0:
+		return "capabilities.add"
+	case sc.ProcMount != nil && *sc.ProcMount == corev1.UnmaskedProcMount:
+		return "procMount"
+	case sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined:
+		return "seccompProfile"
+	case sc.AppArmorProfile != nil && sc.AppArmorProfile.Type == corev1.AppArmorProfileTypeUnconfined:
+		return "appArmorProfile"
+	case sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess:
+		return "windowsOptions.hostProcess"
+	}
+	return ""
+}
+
+// canRunSidecars checks that pr may run the privileged sidecars among
+// sidecars, writing a 403 if not. Sidecars identical to one in current, i.e.
+// already on the session, pass, so an update does not need the permission of
+// whoever added them.
+func (h *handlers) canRunSidecars(w http.ResponseWriter, pr *rbac.Principal, namespace string, sidecars, current []codespacev1.SidecarSpec) bool {
+	for _, s := range sidecars {
+		what := sidecarPrivilege(s)
+		if what == "" || hasSidecar(current, s) {
+			continue
+		}
+		// The permission is per namespace, so one check covers all sidecars
+		ok, err := h.deps.rbac.Enforce(pr.Subject, pr.Roles, SESSION_RESOURCE_STRING, privilegedAction, namespace)
+		if err != nil {
+			logger.Error("RBAC enforcement error", "err", err, "user", pr.Subject)
+		}
+		if err == nil && ok {
+			return true
+		}
+		rbacDenialsTotal.WithLabelValues(SESSION_RESOURCE_STRING, privilegedAction).Inc()
+		http.Error(w, fmt.Sprintf("sidecar %s: %s requires the %q permission", s.Name, what, privilegedAction), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func hasSidecar(sidecars []codespacev1.SidecarSpec, s codespacev1.SidecarSpec) bool {
+	for i := range sidecars {
+		if equality.Semantic.DeepEqual(sidecars[i], s) {
+			return true
+		}
+	}
+	return false
+}
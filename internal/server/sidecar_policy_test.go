@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rbac "github.com/codespace-operator/common/rbac/pkg/rbac"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+func TestSidecarPrivilege(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		sc   *corev1.SecurityContext
+		port int32
+		want string
+	}{
+		{"none", nil, 0, ""},
+		{"restricted", &corev1.SecurityContext{
+			AllowPrivilegeEscalation: ptr.To(false),
+			RunAsNonRoot:             ptr.To(true),
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		}, 0, ""},
+		{"privileged", &corev1.SecurityContext{Privileged: ptr.To(true)}, 0, "privileged"},
+		{"escalation", &corev1.SecurityContext{AllowPrivilegeEscalation: ptr.To(true)}, 0, "allowPrivilegeEscalation"},
+		{"capabilities", &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_ADMIN"}}}, 0, "capabilities.add"},
+		{"unmasked proc", &corev1.SecurityContext{ProcMount: ptr.To(corev1.UnmaskedProcMount)}, 0, "procMount"},
+		{"unconfined seccomp", &corev1.SecurityContext{SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}}, 0, "seccompProfile"},
+		{"host port", nil, 8080, "hostPort"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := codespacev1.SidecarSpec{Name: "side", Image: "busybox", SecurityContext: tc.sc}
+			if tc.port != 0 {
+				s.Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: tc.port}}
+			}
+			if got := sidecarPrivilege(s); got != tc.want {
+				t.Fatalf("sidecarPrivilege() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCanRunSidecars(t *testing.T) {
+	policy := filepath.Join(t.TempDir(), "policy.csv")
+	if err := os.WriteFile(policy, []byte(`
+p, admin, *, *, *, allow
+p, editor, session, create, *, allow
+p, local:bob, session, privileged, team, allow
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	enf, err := rbac.NewRBAC(context.Background(), rbac.RBACConfig{
+		ModelPath:  "../../cfg/rbac-casbin/model.conf",
+		PolicyPath: policy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &handlers{deps: &serverDeps{rbac: enf}}
+
+	plain := codespacev1.SidecarSpec{Name: "db", Image: "postgres"}
+	dind := codespacev1.SidecarSpec{Name: "dind", Image: "docker:dind", SecurityContext: &corev1.SecurityContext{Privileged: ptr.To(true)}}
+	editor := rbac.Principal{Subject: "local:carol", Roles: []string{"editor"}}
+	cases := []struct {
+		name     string
+		pr       rbac.Principal
+		ns       string
+		sidecars []codespacev1.SidecarSpec
+		current  []codespacev1.SidecarSpec
+		want     bool
+	}{
+		{"plain", editor, "team", []codespacev1.SidecarSpec{plain}, nil, true},
+		{"privileged", editor, "team", []codespacev1.SidecarSpec{plain, dind}, nil, false},
+		{"already on the session", editor, "team", []codespacev1.SidecarSpec{dind}, []codespacev1.SidecarSpec{dind}, true},
+		{"granted", rbac.Principal{Subject: "local:bob"}, "team", []codespacev1.SidecarSpec{dind}, nil, true},
+		{"granted per namespace", rbac.Principal{Subject: "local:bob"}, "other", []codespacev1.SidecarSpec{dind}, nil, false},
+		{"admin", rbac.Principal{Subject: "local:root", Roles: []string{"admin"}}, "team", []codespacev1.SidecarSpec{dind}, nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if got := h.canRunSidecars(rec, &tc.pr, tc.ns, tc.sidecars, tc.current); got != tc.want {
+				t.Fatalf("canRunSidecars() = %v, want %v", got, tc.want)
+			}
+			if !tc.want && rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want 403", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+var errNoHomeVolume = errors.New("session has no home volume")
+
+// homePVCName finds the claim the controller created for the session's home volume.
+func (h *handlers) homePVCName(ctx context.Context, sess *codespacev1.Session) (string, error) {
+	if sess.Spec.Home == nil {
+		return "", errNoHomeVolume
+	}
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := h.deps.client.List(ctx, &pvcs,
+		client.InNamespace(sess.Namespace),
+		client.MatchingLabels{codespacev1.SessionNameLabel: sess.Name},
+	); err != nil {
+		return "", err
+	}
+	for _, pvc := range pvcs.Items {
+		if strings.HasSuffix(pvc.Name, "-home") {
+			return pvc.Name, nil
+		}
+	}
+	return "", errNoHomeVolume
+}
+
+// SessionSnapshot is a VolumeSnapshot of a session volume
+// @Description VolumeSnapshot of a session's home volume
+type SessionSnapshot struct {
+	Name          string     `json:"name" example:"my-session-home-20250101-030000"`
+	Volume        string     `json:"volume" example:"home"`
+	Trigger       string     `json:"trigger" example:"manual" enums:"manual,scheduled,clone"`
+	Source        string     `json:"source" example:"cs-my-session-home"`
+	SnapshotClass string     `json:"snapshotClass,omitempty" example:"csi-hostpath-snapclass"`
+	ReadyToUse    bool       `json:"readyToUse" example:"true"`
+	RestoreSize   string     `json:"restoreSize,omitempty" example:"10Gi"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// SessionSnapshotRequest represents the request body for taking a snapshot
+// @Description Request body for snapshotting a session's home volume
+type SessionSnapshotRequest struct {
+	// Name of the snapshot; defaults to <session>-home-<timestamp>
+	Name                    string `json:"name,omitempty" example:"before-upgrade"`
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// SessionRestoreRequest represents the request body for restoring a snapshot
+// @Description Request body for restoring a session's home volume
+type SessionRestoreRequest struct {
+	Snapshot string `json:"snapshot" validate:"required" example:"my-session-home-20250101-030000"`
+}
+
+// handleSessionSnapshots serves /snapshots (GET, POST) and /snapshots/{snapshot} (DELETE).
+func (h *handlers) handleSessionSnapshots(w http.ResponseWriter, r *http.Request) {
+	parts := sessionPathParts(r)
+	switch {
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		h.handleListSnapshots(w, r, parts[0], parts[1])
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		h.handleCreateSnapshot(w, r, parts[0], parts[1])
+	case len(parts) == 4 && r.Method == http.MethodDelete:
+		h.handleDeleteSnapshot(w, r, parts[0], parts[1], parts[3])
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary List session snapshots
+// @ID listSessionSnapshots
+// @Description List the VolumeSnapshots taken of a session's volumes, newest first
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Success 200 {array} SessionSnapshot
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/snapshots [get]
+func (h *handlers) handleListSnapshots(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "get", namespace)
+	if !ok {
+		return
+	}
+	if _, err := h.getScopedSession(r.Context(), namespace, name); err != nil {
+		writeSessionLookupError(w, err)
+		return
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(codespacev1.VolumeSnapshotGVK.GroupVersion().WithKind("VolumeSnapshotList"))
+	if err := h.deps.client.List(r.Context(), list,
+		client.InNamespace(namespace),
+		client.MatchingLabels{codespacev1.SessionNameLabel: name},
+	); err != nil {
+		logger.Error("Failed to list snapshots", "name", name, "namespace", namespace, "err", err, "user", pr.Subject)
+		writeSnapshotError(w, err)
+		return
+	}
+
+	out := make([]SessionSnapshot, 0, len(list.Items))
+	for i := range list.Items {
+		out = append(out, toSessionSnapshot(&list.Items[i]))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt == nil || out[j].CreatedAt == nil {
+			return out[i].Name > out[j].Name
+		}
+		return out[i].CreatedAt.After(*out[j].CreatedAt)
+	})
+	writeJSON(w, out)
+}
+
+// @Summary Snapshot session home
+// @ID createSessionSnapshot
+// @Description Take a VolumeSnapshot of the session's home volume. The snapshot is deleted with the session.
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Param request body SessionSnapshotRequest false "Snapshot request"
+// @Success 201 {object} SessionSnapshot
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/snapshots [post]
+func (h *handlers) handleCreateSnapshot(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "snapshot", namespace)
+	if !ok {
+		return
+	}
+
+	var req SessionSnapshotRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	session, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		writeSessionLookupError(w, err)
+		return
+	}
+	pvcName, err := h.homePVCName(r.Context(), session)
+	if err != nil {
+		if errors.Is(err, errNoHomeVolume) {
+			http.Error(w, "session has no home volume", http.StatusBadRequest)
+			return
+		}
+		errJSON(w, fmt.Errorf("failed to find home volume: %w", err))
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("%s-home-%s", name, time.Now().UTC().Format("20060102-150405"))
+	}
+	snap := codespacev1.NewVolumeSnapshot(namespace, req.Name, pvcName, req.VolumeSnapshotClassName, map[string]string{
+		codespacev1.SessionNameLabel:     name,
+		codespacev1.SnapshotVolumeLabel:  "home",
+		codespacev1.SnapshotTriggerLabel: codespacev1.SnapshotTriggerManual,
+	})
+	snap.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: codespacev1.GroupVersion.String(),
+		Kind:       "Session",
+		Name:       session.Name,
+		UID:        session.UID,
+	}})
+	if err := h.deps.client.Create(r.Context(), snap); err != nil {
+		logger.Error("Failed to create snapshot", "name", name, "namespace", namespace, "snapshot", req.Name, "err", err, "user", pr.Subject)
+		writeSnapshotError(w, err)
+		return
+	}
+
+	h.audit(r, "session.snapshot.create", "namespace", namespace, "session", name, "snapshot", req.Name)
+	h.recordSessionEvent(r.Context(), session, corev1.EventTypeNormal, "SnapshotRequested",
+		fmt.Sprintf("Snapshot %s of the home volume requested by %s", req.Name, pr.Subject))
+
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, toSessionSnapshot(snap))
+}
+
+// @Summary Delete session snapshot
+// @ID deleteSessionSnapshot
+// @Description Delete a VolumeSnapshot taken of a session's volume
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Param snapshot path string true "Snapshot name"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/snapshots/{snapshot} [delete]
+func (h *handlers) handleDeleteSnapshot(w http.ResponseWriter, r *http.Request, namespace, name, snapName string) {
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "snapshot", namespace)
+	if !ok {
+		return
+	}
+	if _, err := h.getScopedSession(r.Context(), namespace, name); err != nil {
+		writeSessionLookupError(w, err)
+		return
+	}
+	snap, err := h.getSessionSnapshot(r.Context(), namespace, name, snapName)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	if err := h.deps.client.Delete(r.Context(), snap); err != nil {
+		logger.Error("Failed to delete snapshot", "namespace", namespace, "snapshot", snapName, "err", err, "user", pr.Subject)
+		writeSnapshotError(w, err)
+		return
+	}
+
+	h.audit(r, "session.snapshot.delete", "namespace", namespace, "session", name, "snapshot", snapName)
+	writeJSON(w, map[string]string{"status": "deleted", "name": snapName})
+}
+
+// @Summary Restore session home
+// @ID restoreSession
+// @Description Restore the session's home volume from one of its snapshots. The controller suspends the session,
+// @Description recreates the home volume from the snapshot and resumes it; progress is reported in status.restore.
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security CookieAuth
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Session name"
+// @Param request body SessionRestoreRequest true "Restore request"
+// @Success 202 {object} codespacev1.Session
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/server/sessions/{namespace}/{name}/restore [post]
+func (h *handlers) handleRestoreSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := sessionPathParts(r)
+	if len(parts) < 3 || parts[2] != "restore" {
+		http.Error(w, "invalid path - expected /api/v1/server/sessions/{namespace}/{name}/restore", http.StatusBadRequest)
+		return
+	}
+	namespace, name := parts[0], parts[1]
+
+	pr, ok := h.deps.rbacMw.MustCan(w, r, SESSION_RESOURCE_STRING, "restore", namespace)
+	if !ok {
+		return
+	}
+
+	var req SessionRestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Snapshot == "" {
+		http.Error(w, "snapshot is required", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.getScopedSession(r.Context(), namespace, name)
+	if err != nil {
+		writeSessionLookupError(w, err)
+		return
+	}
+	if session.Spec.Home == nil {
+		http.Error(w, "session has no home volume", http.StatusBadRequest)
+		return
+	}
+	if session.Annotations[codespacev1.RestoreSnapshotAnnotation] != "" {
+		http.Error(w, "a restore is already in progress", http.StatusConflict)
+		return
+	}
+	if _, err := h.getSessionSnapshot(r.Context(), namespace, name, req.Snapshot); err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	patch := client.MergeFrom(session.DeepCopy())
+	if session.Annotations == nil {
+		session.Annotations = map[string]string{}
+	}
+	session.Annotations[codespacev1.RestoreSnapshotAnnotation] = req.Snapshot
+	session.Annotations[codespacev1.RestoreRequestAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	if err := h.deps.client.Patch(r.Context(), session, patch); err != nil {
+		logger.Error("Failed to request restore", "name", name, "namespace", namespace, "snapshot", req.Snapshot, "err", err, "user", pr.Subject)
+		errJSON(w, fmt.Errorf("failed to request restore: %w", err))
+		return
+	}
+
+	h.audit(r, "session.restore", "namespace", namespace, "session", name, "snapshot", req.Snapshot)
+	h.recordSessionEvent(r.Context(), session, corev1.EventTypeNormal, "RestoreRequested",
+		fmt.Sprintf("Restore from snapshot %s requested by %s", req.Snapshot, pr.Subject))
+
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, session)
+}
+
+// getSessionSnapshot fetches a snapshot and reports it as not found unless it
+// was taken of the given session.
+func (h *handlers) getSessionSnapshot(ctx context.Context, namespace, session, name string) (*unstructured.Unstructured, error) {
+	snap := &unstructured.Unstructured{}
+	snap.SetGroupVersionKind(codespacev1.VolumeSnapshotGVK)
+	if err := h.deps.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, snap); err != nil {
+		return nil, err
+	}
+	if snap.GetLabels()[codespacev1.SessionNameLabel] != session {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: codespacev1.VolumeSnapshotGroup, Resource: "volumesnapshots"}, name)
+	}
+	return snap, nil
+}
+
+// writeSnapshotError maps VolumeSnapshot API errors onto HTTP responses.
+func writeSnapshotError(w http.ResponseWriter, err error) {
+	switch {
+	case apierrors.IsNotFound(err):
+		http.Error(w, "snapshot not found", http.StatusNotFound)
+	case apierrors.IsAlreadyExists(err):
+		http.Error(w, "snapshot already exists", http.StatusConflict)
+	case meta.IsNoMatchError(err):
+		http.Error(w, "volume snapshots are not supported by this cluster", http.StatusNotImplemented)
+	default:
+		errJSON(w, err)
+	}
+}
+
+func toSessionSnapshot(u *unstructured.Unstructured) SessionSnapshot {
+	s := SessionSnapshot{
+		Name:    u.GetName(),
+		Volume:  u.GetLabels()[codespacev1.SnapshotVolumeLabel],
+		Trigger: u.GetLabels()[codespacev1.SnapshotTriggerLabel],
+	}
+	s.Source, _, _ = unstructured.NestedString(u.Object, "spec", "source", "persistentVolumeClaimName")
+	s.SnapshotClass, _, _ = unstructured.NestedString(u.Object, "spec", "volumeSnapshotClassName")
+	s.ReadyToUse, _, _ = unstructured.NestedBool(u.Object, "status", "readyToUse")
+	s.RestoreSize, _, _ = unstructured.NestedString(u.Object, "status", "restoreSize")
+	s.Error, _, _ = unstructured.NestedString(u.Object, "status", "error", "message")
+	if ts := u.GetCreationTimestamp(); !ts.IsZero() {
+		s.CreatedAt = &ts.Time
+	}
+	return s
+}
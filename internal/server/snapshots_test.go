@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	codespacev1 "github.com/codespace-operator/codespace-operator/api/v1"
+)
+
+func testSnapshot(ns, name, session string) *unstructured.Unstructured {
+	return codespacev1.NewVolumeSnapshot(ns, name, "cs-"+session+"-home", "", map[string]string{
+		codespacev1.SessionNameLabel:     session,
+		codespacev1.SnapshotVolumeLabel:  "home",
+		codespacev1.SnapshotTriggerLabel: codespacev1.SnapshotTriggerManual,
+	})
+}
+
+func TestToSessionSnapshot(t *testing.T) {
+	created := time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC)
+	ready := testSnapshot("team", "snap-ready", "nb")
+	ready.SetCreationTimestamp(metav1.NewTime(created))
+	_ = unstructured.SetNestedField(ready.Object, "csi-snapclass", "spec", "volumeSnapshotClassName")
+	_ = unstructured.SetNestedField(ready.Object, map[string]any{"readyToUse": true, "restoreSize": "10Gi"}, "status")
+
+	failed := testSnapshot("team", "snap-failed", "nb")
+	_ = unstructured.SetNestedField(failed.Object, map[string]any{
+		"readyToUse": false,
+		"error":      map[string]any{"message": "driver refused"},
+	}, "status")
+
+	pending := testSnapshot("team", "snap-pending", "nb")
+	pending.SetLabels(nil)
+
+	for _, tc := range []struct {
+		name string
+		in   *unstructured.Unstructured
+		want SessionSnapshot
+	}{
+		{"ready", ready, SessionSnapshot{
+			Name: "snap-ready", Volume: "home", Trigger: codespacev1.SnapshotTriggerManual, Source: "cs-nb-home",
+			SnapshotClass: "csi-snapclass", ReadyToUse: true, RestoreSize: "10Gi", CreatedAt: &created,
+		}},
+		{"failed", failed, SessionSnapshot{
+			Name: "snap-failed", Volume: "home", Trigger: codespacev1.SnapshotTriggerManual, Source: "cs-nb-home",
+			Error: "driver refused",
+		}},
+		{"no labels or status", pending, SessionSnapshot{Name: "snap-pending", Source: "cs-nb-home"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := toSessionSnapshot(tc.in)
+			if got.CreatedAt != nil && tc.want.CreatedAt != nil && got.CreatedAt.Equal(*tc.want.CreatedAt) {
+				got.CreatedAt = tc.want.CreatedAt
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("toSessionSnapshot() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetSessionSnapshot(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(codespacev1.VolumeSnapshotGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(codespacev1.VolumeSnapshotGVK.GroupVersion().WithKind("VolumeSnapshotList"), &unstructured.UnstructuredList{})
+	h := &handlers{deps: &serverDeps{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		testSnapshot("team", "nb-snap", "nb"),
+		testSnapshot("team", "other-snap", "other"),
+		testSnapshot("elsewhere", "nb-snap-2", "nb"),
+	).Build()}}
+
+	for _, tc := range []struct {
+		name, namespace, session, snapshot string
+		found                              bool
+	}{
+		{"own snapshot", "team", "nb", "nb-snap", true},
+		{"other session's snapshot", "team", "nb", "other-snap", false},
+		{"same session name, other namespace", "team", "nb", "nb-snap-2", false},
+		{"missing", "team", "nb", "nope", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			snap, err := h.getSessionSnapshot(context.Background(), tc.namespace, tc.session, tc.snapshot)
+			if tc.found {
+				if err != nil || snap.GetName() != tc.snapshot {
+					t.Fatalf("getSessionSnapshot() = %v, %v; want %s", snap, err, tc.snapshot)
+				}
+				return
+			}
+			if !apierrors.IsNotFound(err) {
+				t.Fatalf("getSessionSnapshot() error = %v, want NotFound", err)
+			}
+		})
+	}
+}
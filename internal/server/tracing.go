@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/codespace-operator/common/common/pkg/common"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// setupTracing installs the W3C trace-context propagator and, when enabled, an
+// OTLP/gRPC exporter. The returned function flushes pending spans on shutdown.
+func setupTracing(ctx context.Context, cfg *ServerConfig, logger *slog.Logger) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.TracingEnabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint)}
+	if cfg.OTLPInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", cfg.AppName),
+		attribute.String("service.version", common.GetBuildInfo()["version"]),
+	)
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+
+	logger.Info("OpenTelemetry tracing enabled",
+		"endpoint", cfg.OTLPEndpoint,
+		"insecure", cfg.OTLPInsecure,
+		"sampleRatio", cfg.TracingSampleRatio)
+
+	return tp.Shutdown, nil
+}
+
+// tracingMiddleware starts a server span per request, continuing any incoming
+// traceparent. requestLoggingMiddleware ties the span to the request's X-Request-Id.
+func tracingMiddleware(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, "http.server",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + routeLabel(mux, r)
+			}),
+			otelhttp.WithFilter(func(r *http.Request) bool {
+				return !isKubeProbe(r) && r.URL.Path != "/metrics"
+			}),
+		)
+	}
+}
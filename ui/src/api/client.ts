@@ -135,14 +135,21 @@ export const api = {
     return normalizeObject<APISession>(await r.json()) as unknown as UISession;
   },
 
-  // Live updates via SSE — cookies only, no query token leakage
-  watch(ns: string, onEvent: (ev: MessageEvent) => void): EventSource {
+  // Live updates via SSE — cookies only, no query token leakage.
+  // The stream starts with a "snapshot" (and repeats it after a "resync");
+  // EventSource resumes from Last-Event-ID on its own when it reconnects.
+  watch(
+    ns: string,
+    onEvent: (ev: MessageEvent) => void,
+    onSnapshot?: (ev: MessageEvent) => void,
+  ): EventSource {
     const baseUrl = `${base}/api/v1/stream/sessions`;
     const query =
       ns === "All" ? `?all=true` : `?namespace=${encodeURIComponent(ns)}`;
     const url = baseUrl + query;
     const es = new EventSource(url, { withCredentials: true as any });
     es.onmessage = onEvent;
+    if (onSnapshot) es.addEventListener("snapshot", onSnapshot);
     return es;
   },
 };
@@ -66,30 +66,39 @@ export function useSessions(
   useEffect(() => {
     if (!enabled) return;
     if (esRef.current) esRef.current.close();
-    const es = api.watch(effectiveNs, (m) => {
-      try {
-        const ev = JSON.parse(m.data) as SessionEvent;
-        setRows((list) => {
-          if (ev.type === "DELETED")
-            return list.filter(
-              (x) => x.metadata.name !== ev.object.metadata.name,
+    const es = api.watch(
+      effectiveNs,
+      (m) => {
+        try {
+          const ev = JSON.parse(m.data) as SessionEvent;
+          setRows((list) => {
+            if (ev.type === "DELETED")
+              return list.filter(
+                (x) => x.metadata.name !== ev.object.metadata.name,
+              );
+            const ix = list.findIndex(
+              (x) => x.metadata.name === ev.object.metadata.name,
             );
-          const ix = list.findIndex(
-            (x) => x.metadata.name === ev.object.metadata.name,
-          );
-          if (ix === -1) return [ev.object, ...list];
-          const next = [...list];
-          next[ix] = ev.object;
-          return next;
-        });
-        setPendingTargets((p) => {
-          const k = `${ev.object.metadata.namespace}/${ev.object.metadata.name}`;
-          if (!(k in p)) return p;
-          const { [k]: _, ...rest } = p;
-          return rest;
-        });
-      } catch {}
-    });
+            if (ix === -1) return [ev.object, ...list];
+            const next = [...list];
+            next[ix] = ev.object;
+            return next;
+          });
+          setPendingTargets((p) => {
+            const k = `${ev.object.metadata.namespace}/${ev.object.metadata.name}`;
+            if (!(k in p)) return p;
+            const { [k]: _, ...rest } = p;
+            return rest;
+          });
+        } catch {}
+      },
+      (m) => {
+        try {
+          const snap = JSON.parse(m.data) as { items: UISession[] };
+          setRows(snap.items ?? []);
+        } catch {}
+      },
+    );
     es.onerror = () => {};
     esRef.current = es;
     return () => es.close();